@@ -4,13 +4,13 @@ out vec4 FragColor;
 in vec2 TexCoords;
 in vec3 FragPos;  
 in vec3 Normal;
+in float ViewDepth;
 
 // Ambient Light Uniform
 struct AmbientLight {
     vec3 color;
     float intensity;
 };
-uniform AmbientLight ambientLight;
 
 // Directional light uniforms
 struct DirectionalLight {
@@ -18,36 +18,52 @@ struct DirectionalLight {
     vec3 color;
     float intensity;
 };
-uniform DirectionalLight directionalLight;
 
 // Point light uniform
 struct PointLight {
     vec3 position;
+    float range; // Attenuation is below 1/256 beyond this
     vec3 color;
     float intensity;
     float constant;
     float linear;
     float quadratic;
+
+    int shadowSlot; // Index of pointShadowMaps, -1 without shadows
 };
-#define MAX_POINT_LIGHTS 10
-uniform int pointLightsCount;
-uniform PointLight pointLights[MAX_POINT_LIGHTS];
 
 // Spot light uniform
 struct SpotLight {
     vec3 position;
+    float range;
     vec3 color;
     vec3 direction;
     float cutOff;
     float outerCutOff;
-    float intensity;
     float constant;
     float linear;
     float quadratic;
+
+    int shadowLayer; // Layer of spotShadowMaps, -1 without shadows
 };
-#define MAX_SPOT_LIGHTS 10
-uniform int spotLightsCount;
-uniform SpotLight spotLights[MAX_SPOT_LIGHTS];
+
+// Lights in the frame, every light and cluster is in a texture buffer
+layout (std140) uniform Lights {
+    vec4 ambientLight;         // rgb color, a intensity
+    vec4 directionalDirection;
+    vec4 directionalColor;     // rgb color, a intensity
+    vec4 clusterScale;         // Tiles per pixel in x and y, then slice = log(ViewDepth) * z - w
+};
+uniform samplerBuffer pointLightData; // 3 texels per light
+uniform samplerBuffer spotLightData;  // 4 texels per light
+
+// The view is split into clusters, each with an offset into clusterLights and
+// counts of its point lights then spot lights
+#define CLUSTER_TILES_X 16
+#define CLUSTER_TILES_Y 9
+#define CLUSTER_SLICES 24
+uniform usamplerBuffer clusterGrid;
+uniform usamplerBuffer clusterLights;
 
 // Material uniform
 struct Material { 
@@ -60,6 +76,130 @@ uniform Material material;
 // View pos
 uniform vec3 viewPos;
 
+// Shadows
+#define MAX_SHADOW_CASCADES 4
+#define MAX_SPOT_SHADOWS 4
+#define MAX_POINT_SHADOWS 4
+layout (std140) uniform Shadows {
+    mat4 directionalLightSpaceMatrices[MAX_SHADOW_CASCADES];
+    vec4 directionalSplits; // View space distance each cascade reaches
+    vec4 directionalShadow; // enabled, cascades, bias, pcfRadius
+
+    mat4 spotLightSpaceMatrices[MAX_SPOT_SHADOWS];
+    vec4 spotShadows[MAX_SPOT_SHADOWS]; // bias, pcfRadius
+
+    vec4 pointShadowPositions[MAX_POINT_SHADOWS]; // Where the light was when its map was drawn, w far plane
+    vec4 pointShadows[MAX_POINT_SHADOWS];         // bias, resolution, pcfRadius
+};
+uniform sampler2DArray directionalShadowMap; // A layer per cascade
+uniform sampler2DArray spotShadowMaps;
+uniform samplerCube pointShadowMaps[MAX_POINT_SHADOWS]; // Distance from the light over its far plane
+uniform int receiveShadows;
+
+PointLight fetchPointLight(int index) {
+    vec4 positionRange = texelFetch(pointLightData, index * 3);
+    vec4 colorIntensity = texelFetch(pointLightData, index * 3 + 1);
+    vec4 attenuation = texelFetch(pointLightData, index * 3 + 2);
+    return PointLight(positionRange.xyz, positionRange.w, colorIntensity.rgb, colorIntensity.a,
+        attenuation.x, attenuation.y, attenuation.z, int(attenuation.w));
+}
+
+SpotLight fetchSpotLight(int index) {
+    vec4 positionRange = texelFetch(spotLightData, index * 4);
+    vec4 directionCutOff = texelFetch(spotLightData, index * 4 + 1);
+    vec4 colorOuterCutOff = texelFetch(spotLightData, index * 4 + 2);
+    vec4 attenuation = texelFetch(spotLightData, index * 4 + 3);
+    return SpotLight(positionRange.xyz, positionRange.w, colorOuterCutOff.rgb, directionCutOff.xyz,
+        directionCutOff.w, colorOuterCutOff.a, attenuation.x, attenuation.y, attenuation.z, int(attenuation.w));
+}
+
+int clusterIndex() {
+    ivec2 tile = clamp(ivec2(gl_FragCoord.xy * clusterScale.xy), ivec2(0), ivec2(CLUSTER_TILES_X - 1, CLUSTER_TILES_Y - 1));
+    int slice = clamp(int(log(max(ViewDepth, 1e-4)) * clusterScale.z - clusterScale.w), 0, CLUSTER_SLICES - 1);
+    return (slice * CLUSTER_TILES_Y + tile.y) * CLUSTER_TILES_X + tile.x;
+}
+
+// How much of a light is blocked, filtered over the texels around the fragment
+float calculateShadow(sampler2DArray shadowMap, int layer, mat4 lightSpaceMatrix, float bias, int pcfRadius) {
+    vec4 lightSpacePos = lightSpaceMatrix * vec4(FragPos, 1.0);
+    vec3 projCoords = lightSpacePos.xyz / lightSpacePos.w * 0.5 + 0.5;
+    if (projCoords.z > 1.0)
+        return 0.0;
+
+    vec2 texelSize = 1.0 / vec2(textureSize(shadowMap, 0).xy);
+    float blocked = 0.0;
+    for (int x = -pcfRadius; x <= pcfRadius; x++) {
+        for (int y = -pcfRadius; y <= pcfRadius; y++) {
+            float closest = texture(shadowMap, vec3(projCoords.xy + vec2(x, y) * texelSize, layer)).r;
+            blocked += projCoords.z - bias > closest ? 1.0 : 0.0;
+        }
+    }
+
+    float samples = float((2 * pcfRadius + 1) * (2 * pcfRadius + 1));
+    return blocked / samples;
+}
+
+// Surfaces at a steep angle to the light need more bias
+float slopeBias(float bias, vec3 normal, vec3 lightDir) {
+    return max(bias * 10.0 * (1.0 - dot(normal, lightDir)), bias);
+}
+
+float calculateDirectionalShadow(vec3 norm, vec3 lightDir) {
+    if (directionalShadow.x == 0.0 || receiveShadows == 0)
+        return 0.0;
+
+    for (int i = 0; i < int(directionalShadow.y); i++) {
+        if (ViewDepth < directionalSplits[i]) {
+            float bias = slopeBias(directionalShadow.z, norm, lightDir);
+            return calculateShadow(directionalShadowMap, i, directionalLightSpaceMatrices[i], bias, int(directionalShadow.w));
+        }
+    }
+    return 0.0; // Beyond the shadow distance
+}
+
+// Sampler arrays can only be indexed by constants
+float samplePointShadowMap(int slot, vec3 direction) {
+    switch (slot) {
+    case 0: return texture(pointShadowMaps[0], direction).r;
+    case 1: return texture(pointShadowMaps[1], direction).r;
+    case 2: return texture(pointShadowMaps[2], direction).r;
+    case 3: return texture(pointShadowMaps[3], direction).r;
+    }
+    return 1.0;
+}
+
+float calculatePointShadow(PointLight light, vec3 norm, vec3 lightDir) {
+    if (light.shadowSlot < 0 || receiveShadows == 0)
+        return 0.0;
+
+    vec4 shadowPosition = pointShadowPositions[light.shadowSlot];
+    vec4 shadow = pointShadows[light.shadowSlot];
+    int pcfRadius = int(shadow.z);
+
+    vec3 toFrag = FragPos - shadowPosition.xyz;
+    float dist = length(toFrag);
+    if (dist > shadowPosition.w)
+        return 0.0;
+
+    // Bias is in world units here, as the map holds distances
+    float current = (dist - slopeBias(shadow.x, norm, lightDir)) / shadowPosition.w;
+
+    // A texel of a cube face is about this wide at the fragment
+    float texelSize = 2.0 * dist / shadow.y;
+    float blocked = 0.0;
+    for (int x = -pcfRadius; x <= pcfRadius; x++) {
+        for (int y = -pcfRadius; y <= pcfRadius; y++) {
+            for (int z = -pcfRadius; z <= pcfRadius; z++) {
+                float closest = samplePointShadowMap(light.shadowSlot, toFrag + vec3(x, y, z) * texelSize);
+                blocked += current > closest ? 1.0 : 0.0;
+            }
+        }
+    }
+
+    float samples = float((2 * pcfRadius + 1) * (2 * pcfRadius + 1) * (2 * pcfRadius + 1));
+    return blocked / samples;
+}
+
 vec3 calculateAmbientLight(AmbientLight light, Material material) {
     vec3 ambient = light.color * vec3(texture(material.diffuseMap, TexCoords)) * light.intensity;
     
@@ -71,18 +211,20 @@ vec3 calculateDirectionalLight(DirectionalLight light, vec3 norm, Material mater
     float diff = max(dot(norm, lightDir), 0.0);
     vec3 diffuse = diff * vec3(texture(material.diffuseMap, TexCoords)) * light.color * light.intensity;
 
-    vec3 reflectDir = reflect(-lightDir, norm);
+    vec3 reflectDir = reflect(-lightDir, norm);  
     vec3 viewDir = normalize(viewPos - FragPos);
     float spec = pow(max(dot(viewDir, reflectDir), 0.0), material.shininess);
 
     vec3 specular = spec * vec3(texture(material.specularMap, TexCoords));
 
-    return diffuse + specular;
+    return (diffuse + specular) * (1.0 - calculateDirectionalShadow(norm, lightDir));
 }
 
 vec3 calculatePointLight(PointLight light, vec3 fragPos, vec3 normal, Material material) {
     vec3 lightDir = normalize(light.position - fragPos);
     float dist = length(light.position - fragPos);
+    if (dist > light.range)
+        return vec3(0.0);
     float attenuation = 1.0 / (light.constant + light.linear * dist + light.quadratic * (dist * dist));
 
     float diff = max(dot(normal, lightDir), 0.0);
@@ -96,10 +238,13 @@ vec3 calculatePointLight(PointLight light, vec3 fragPos, vec3 normal, Material m
 
     vec3 specular = spec * vec3(texture(material.specularMap, TexCoords)) * light.color;
 
-    return diffuse + specular;
+    return (diffuse + specular) * (1.0 - calculatePointShadow(light, normal, lightDir));
 }
 
 vec3 calculateSpotLight(SpotLight light, vec3 fragPos, vec3 normal, Material material) {
+    if (length(light.position - FragPos) > light.range)
+        return vec3(0.0);
+
     // diffuse 
     vec3 norm = normalize(Normal);
     vec3 lightDir = normalize(light.position - FragPos);
@@ -126,27 +271,41 @@ vec3 calculateSpotLight(SpotLight light, vec3 fragPos, vec3 normal, Material mat
     diffuse   *= attenuation;
     specular *= attenuation;
 
+    // shadow
+    if (light.shadowLayer >= 0 && receiveShadows != 0) {
+        vec4 shadow = spotShadows[light.shadowLayer];
+        float bias = slopeBias(shadow.x, norm, lightDir);
+        float blocked = calculateShadow(spotShadowMaps, light.shadowLayer, spotLightSpaceMatrices[light.shadowLayer], bias, int(shadow.y));
+        diffuse  *= 1.0 - blocked;
+        specular *= 1.0 - blocked;
+    }
+
     return diffuse + specular;
 }
 
 
 void main() {
     // Calculate ambient light
-    vec3 result = calculateAmbientLight(ambientLight, material);
+    vec3 result = calculateAmbientLight(AmbientLight(ambientLight.rgb, ambientLight.a), material);
 
     vec3 norm = normalize(Normal);
 
     // Directional lighting
-    result += calculateDirectionalLight(directionalLight, norm, material);
+    result += calculateDirectionalLight(DirectionalLight(directionalDirection.xyz, directionalColor.rgb, directionalColor.a), norm, material);
+
+    // Only the lights that reach this fragment's cluster
+    uvec4 cluster = texelFetch(clusterGrid, clusterIndex());
+    int offset = int(cluster.x);
+    int pointCount = int(cluster.y);
+    int spotCount = int(cluster.z);
 
     // Calculate point lights
-    for(int i = 0; i < pointLightsCount; i++)
-        result += calculatePointLight(pointLights[i], FragPos, norm, material);
+    for(int i = 0; i < pointCount; i++)
+        result += calculatePointLight(fetchPointLight(int(texelFetch(clusterLights, offset + i).r)), FragPos, norm, material);
 
     // Calculate spot lights
-    for(int i = 0; i < spotLightsCount; i++)
-        result += calculateSpotLight(spotLights[i], FragPos, norm, material);
+    for(int i = 0; i < spotCount; i++)
+        result += calculateSpotLight(fetchSpotLight(int(texelFetch(clusterLights, offset + pointCount + i).r)), FragPos, norm, material);
 
     FragColor = vec4(result, 1.0);
 }
-
@@ -0,0 +1,10 @@
+#version 330 core
+in vec3 FragPos;
+
+uniform vec3 lightPosition;
+uniform float farPlane;
+
+// Distance from the light rather than depth, so every face is sampled alike
+void main() {
+    gl_FragDepth = length(FragPos - lightPosition) / farPlane;
+}
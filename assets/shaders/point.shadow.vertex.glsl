@@ -0,0 +1,12 @@
+#version 330 core
+layout (location = 0) in vec3 aPos;
+
+out vec3 FragPos;
+
+uniform mat4 model;
+uniform mat4 lightSpaceMatrix; // Of the cube face being drawn
+
+void main() {
+    FragPos = vec3(model * vec4(aPos, 1.0));
+    gl_Position = lightSpaceMatrix * vec4(FragPos, 1.0);
+}
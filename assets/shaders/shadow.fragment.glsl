@@ -0,0 +1,5 @@
+#version 330 core
+
+// Only depth is written
+void main() {
+}
@@ -6,6 +6,7 @@ layout (location = 2) in vec3 aNormal;
 out vec2 TexCoords;                        
 out vec3 FragPos;                    
 out vec3 Normal;                         
+out float ViewDepth;
 
 uniform mat4 model;
 uniform mat4 view;
@@ -16,6 +17,7 @@ void main() {
     TexCoords = aTexCoords;
 
     FragPos = vec3(model * vec4(aPos, 1.0));
+    ViewDepth = -(view * vec4(FragPos, 1.0)).z;
 
     Normal = mat3(transpose(inverse(model))) * aNormal;
 }
@@ -1,42 +1,64 @@
 package components
 
 import (
-	"github.com/go-gl/gl/v4.3-core/gl"
+	"0xKowalski/game/graphics"
 )
 
-type BufferComponent struct {
-	VAO uint32
-	VBO uint32
-	EBO uint32
+// Interleaved layout of Vertex: position, texture coordinates, normal
+var vertexLayout = graphics.VertexLayout{
+	Stride: 8 * 4,
+	Attributes: []graphics.VertexAttribute{
+		{Location: 0, Components: 3, Offset: 0},     // Position attribute
+		{Location: 1, Components: 2, Offset: 3 * 4}, // Texture coordinates
+		{Location: 2, Components: 3, Offset: 5 * 4}, // Normals
+	},
 }
 
-func NewBufferComponent(vertices []Vertex, indices []uint32) *BufferComponent {
-	var vao, vbo, ebo uint32
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
-
-	gl.GenBuffers(1, &vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*8*4, gl.Ptr(vertices), gl.STATIC_DRAW)
+// BufferComponent holds a mesh's GPU buffers. It is created without touching the
+// device, the render system uploads it the first time it is drawn.
+type BufferComponent struct {
+	VertexArray graphics.VertexArray
+	Vertices    graphics.Buffer
+	Indices     graphics.Buffer
+	IndexCount  int
 
-	gl.GenBuffers(1, &ebo)
-	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ebo)
-	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+	vertices []Vertex
+	indices  []uint32
+	device   graphics.Device
+}
 
-	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 8*4, gl.Ptr(nil)) // Position attribute
-	gl.EnableVertexAttribArray(0)
+func NewBufferComponent(vertices []Vertex, indices []uint32) *BufferComponent {
+	return &BufferComponent{
+		IndexCount: len(indices),
+		vertices:   vertices,
+		indices:    indices,
+	}
+}
 
-	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 8*4, gl.Ptr(uintptr(3*4))) // Texture coordinates
-	gl.EnableVertexAttribArray(1)
+func (bc *BufferComponent) Uploaded() bool {
+	return bc.device != nil
+}
 
-	gl.VertexAttribPointer(2, 3, gl.FLOAT, false, 8*4, gl.Ptr(uintptr(5*4))) // Normals
-	gl.EnableVertexAttribArray(2)
+func (bc *BufferComponent) Upload(device graphics.Device) {
+	if bc.Uploaded() {
+		return
+	}
 
-	gl.BindVertexArray(0)
+	bc.Vertices = device.CreateBuffer(graphics.VertexBuffer, bc.vertices)
+	bc.Indices = device.CreateBuffer(graphics.IndexBuffer, bc.indices)
+	bc.VertexArray = device.CreateVertexArray(bc.Vertices, bc.Indices, vertexLayout)
+	bc.device = device
+}
 
-	return &BufferComponent{
-		VAO: vao,
-		VBO: vbo,
-		EBO: ebo,
+// Delete releases the GPU buffers, it does nothing if they were never uploaded
+func (bc *BufferComponent) Delete() {
+	if !bc.Uploaded() {
+		return
 	}
+
+	bc.device.DeleteVertexArray(bc.VertexArray)
+	bc.device.DeleteBuffer(bc.Vertices)
+	bc.device.DeleteBuffer(bc.Indices)
+	bc.VertexArray, bc.Vertices, bc.Indices = 0, 0, 0
+	bc.device = nil
 }
@@ -12,7 +12,7 @@ type CameraComponent struct {
 	Yaw         float32
 	Pitch       float32
 	FieldOfView float32
-	AspectRatio float32
+	AspectRatio float32 // Kept matching the window by the camera system
 	NearClip    float32
 	FarClip     float32
 }
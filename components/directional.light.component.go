@@ -6,6 +6,9 @@ type DirectionalLightComponent struct {
 	Direction mgl32.Vec3
 	Color     mgl32.Vec3
 	Intensity float32
+
+	CastShadows bool
+	Shadow      ShadowSettings
 }
 
 func NewDirectionalLightComponent(direction mgl32.Vec3, color mgl32.Vec3, intensity float32) *DirectionalLightComponent {
@@ -13,5 +16,6 @@ func NewDirectionalLightComponent(direction mgl32.Vec3, color mgl32.Vec3, intens
 		Direction: direction,
 		Color:     color,
 		Intensity: intensity,
+		Shadow:    DefaultShadowSettings(),
 	}
 }
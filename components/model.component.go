@@ -1,6 +1,7 @@
 package components
 
 import (
+	"0xKowalski/game/resources"
 	"fmt"
 	"log"
 	"path/filepath"
@@ -12,10 +13,33 @@ import (
 type ModelComponent struct {
 	MeshComponents     []*MeshComponent
 	MaterialComponents []*MaterialComponent
-	BufferComponents   []*BufferComponent
+	BufferComponents   []*BufferComponent // Created by the render system when the model is added, uploaded when first drawn
+
+	Source ModelSource
+}
+
+// ModelSource records what a model was built from so it can be rebuilt, either
+// an obj/mtl pair or one of the generated primitives.
+type ModelSource struct {
+	ObjPath string `json:",omitempty"`
+	MtlPath string `json:",omitempty"`
+
+	Primitive string  `json:",omitempty"` // "cube", "plane" or "sphere"
+	Size      float32 `json:",omitempty"` // Cube size or sphere radius
+	Segments  int     `json:",omitempty"`
+	Rings     int     `json:",omitempty"`
 }
 
 func NewModelComponent(objPath, mtlPath string) *ModelComponent {
+	modelComponent, err := LoadModelComponent(objPath, mtlPath)
+	if err != nil {
+		panic(err)
+	}
+
+	return modelComponent
+}
+
+func LoadModelComponent(objPath, mtlPath string) (*ModelComponent, error) {
 	mtlDirPath := filepath.Dir(mtlPath)
 
 	options := &gwob.ObjParserOptions{
@@ -23,29 +47,28 @@ func NewModelComponent(objPath, mtlPath string) *ModelComponent {
 		Logger:   func(msg string) {},
 	}
 
-	obj, err := gwob.NewObjFromFile(objPath, options)
+	obj, err := gwob.NewObjFromFile(resources.Path(objPath), options)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	lib, err := gwob.ReadMaterialLibFromFile(mtlPath, options)
+	lib, err := gwob.ReadMaterialLibFromFile(resources.Path(mtlPath), options)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	meshComponents, materialComponents, bufferComponents := ConvertObjToMeshComponents(obj, &lib, mtlDirPath)
+	meshComponents, materialComponents := ConvertObjToMeshComponents(obj, &lib, mtlDirPath)
 
 	return &ModelComponent{
 		MeshComponents:     meshComponents,
 		MaterialComponents: materialComponents,
-		BufferComponents:   bufferComponents,
-	}
+		Source:             ModelSource{ObjPath: objPath, MtlPath: mtlPath},
+	}, nil
 }
 
-func ConvertObjToMeshComponents(obj *gwob.Obj, lib *gwob.MaterialLib, mtlDirPath string) ([]*MeshComponent, []*MaterialComponent, []*BufferComponent) {
+func ConvertObjToMeshComponents(obj *gwob.Obj, lib *gwob.MaterialLib, mtlDirPath string) ([]*MeshComponent, []*MaterialComponent) {
 	var meshComponents []*MeshComponent
 	var materialComponents []*MaterialComponent
-	var bufferComponents []*BufferComponent
 
 	for _, g := range obj.Groups {
 		var vertices []Vertex
@@ -86,9 +109,7 @@ func ConvertObjToMeshComponents(obj *gwob.Obj, lib *gwob.MaterialLib, mtlDirPath
 		materialComponent := NewMaterialComponent(fmt.Sprintf("%s/%s", mtlDirPath, mtl.MapKd), fmt.Sprintf("%s/%s", mtlDirPath, mtl.MapKs), mtl.Ns)
 
 		materialComponents = append(materialComponents, materialComponent)
-
-		bufferComponents = append(bufferComponents, NewBufferComponent(vertices, indices))
 	}
 
-	return meshComponents, materialComponents, bufferComponents
+	return meshComponents, materialComponents
 }
@@ -9,6 +9,9 @@ type PointLightComponent struct {
 	Constant  float32
 	Linear    float32
 	Quadratic float32
+
+	CastShadows bool
+	Shadow      ShadowSettings // Resolution is per cube face, Cascades and Distance are unused
 }
 
 func NewPointLightComponent(position, color mgl32.Vec3, intensity, constant, linear, quadratic float32) *PointLightComponent {
@@ -19,5 +22,6 @@ func NewPointLightComponent(position, color mgl32.Vec3, intensity, constant, lin
 		Constant:  constant,
 		Linear:    linear,
 		Quadratic: quadratic,
+		Shadow:    DefaultPointShadowSettings(),
 	}
 }
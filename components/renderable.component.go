@@ -3,6 +3,9 @@ package components
 type RenderableComponent struct {
 	TransformComponent *TransformComponent
 	ModelComponent     *ModelComponent
+
+	CastShadows    bool
+	ReceiveShadows bool
 }
 
 func NewRenderableComponent(transformComponent *TransformComponent, modelComponent *ModelComponent) *RenderableComponent {
@@ -10,5 +13,7 @@ func NewRenderableComponent(transformComponent *TransformComponent, modelCompone
 	return &RenderableComponent{
 		TransformComponent: transformComponent,
 		ModelComponent:     modelComponent,
+		CastShadows:        true,
+		ReceiveShadows:     true,
 	}
 }
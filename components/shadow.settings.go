@@ -0,0 +1,32 @@
+package components
+
+// ShadowSettings configure the shadow map of a light with CastShadows set
+type ShadowSettings struct {
+	Resolution int     // Width and height of the shadow map in texels
+	Bias       float32 // Depth bias against shadow acne, scaled up on surfaces angled away from the light. In world units for point lights
+	PCFRadius  int     // Texels filtered either side of each sample, 0 for hard edges
+
+	// Directional lights only: the view is split into up to 4 cascades, each
+	// with its own map, covering the camera's view up to Distance.
+	Cascades int
+	Distance float32
+}
+
+func DefaultShadowSettings() ShadowSettings {
+	return ShadowSettings{
+		Resolution: 2048,
+		Bias:       0.005,
+		PCFRadius:  1,
+		Cascades:   4,
+		Distance:   50,
+	}
+}
+
+// DefaultPointShadowSettings are smaller than the others, as a point light draws six maps
+func DefaultPointShadowSettings() ShadowSettings {
+	return ShadowSettings{
+		Resolution: 512,
+		Bias:       0.05,
+		PCFRadius:  1,
+	}
+}
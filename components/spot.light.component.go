@@ -12,6 +12,9 @@ type SpotLightComponent struct {
 	Constant    float32
 	Linear      float32
 	Quadratic   float32
+
+	CastShadows bool
+	Shadow      ShadowSettings // Cascades and Distance are unused
 }
 
 func NewSpotLightComponent(position, color, direction mgl32.Vec3, cutOff, outerCutOff, intensity, constant, linear, quadratic float32) *SpotLightComponent {
@@ -25,5 +28,6 @@ func NewSpotLightComponent(position, color, direction mgl32.Vec3, cutOff, outerC
 		Constant:    constant,
 		Linear:      linear,
 		Quadratic:   quadratic,
+		Shadow:      DefaultShadowSettings(),
 	}
 }
@@ -4,10 +4,31 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 )
 
+// TransformComponent holds a transform relative to the entity's parent, or to
+// the world when it has none.
 type TransformComponent struct {
 	Position mgl32.Vec3
 	Rotation mgl32.Quat
 	Scale    mgl32.Vec3
+
+	// World matrix of the parent, kept up to date by the transform system.
+	// Identity until set, so transforms built as struct literals work too.
+	parentMatrix    mgl32.Mat4
+	hasParentMatrix bool
+
+	// World matrix to draw with this frame, interpolated between the previous
+	// and current fixed step by the transform system
+	renderMatrix    mgl32.Mat4
+	hasRenderMatrix bool
+
+	previous    transformState
+	hasPrevious bool
+}
+
+type transformState struct {
+	position mgl32.Vec3
+	rotation mgl32.Quat
+	scale    mgl32.Vec3
 }
 
 func NewTransformComponent(position mgl32.Vec3) *TransformComponent {
@@ -37,3 +58,105 @@ func (t *TransformComponent) GetModelMatrix() mgl32.Mat4 {
 
 	return translateMat.Mul4(rotateMat).Mul4(scaleMat)
 }
+
+// StorePrevious records the current local transform as the state interpolation
+// starts from. It's called before every fixed step, calling it after teleporting
+// an entity stops it being drawn sliding to its new position.
+func (t *TransformComponent) StorePrevious() {
+	t.previous = transformState{position: t.Position, rotation: t.Rotation, scale: t.Scale}
+	t.hasPrevious = true
+}
+
+// GetInterpolatedMatrix returns the local model matrix alpha of the way from the
+// previous fixed step to the current one.
+func (t *TransformComponent) GetInterpolatedMatrix(alpha float32) mgl32.Mat4 {
+	if !t.hasPrevious {
+		return t.GetModelMatrix()
+	}
+
+	position := t.previous.position.Add(t.Position.Sub(t.previous.position).Mul(alpha))
+	rotation := mgl32.QuatSlerp(t.previous.rotation, t.Rotation, alpha)
+	scale := t.previous.scale.Add(t.Scale.Sub(t.previous.scale).Mul(alpha))
+
+	translateMat := mgl32.Translate3D(position.X(), position.Y(), position.Z())
+	scaleMat := mgl32.Scale3D(scale.X(), scale.Y(), scale.Z())
+
+	return translateMat.Mul4(rotation.Mat4()).Mul4(scaleMat)
+}
+
+// SetParentMatrix sets the world matrix of the entity's parent
+func (t *TransformComponent) SetParentMatrix(matrix mgl32.Mat4) {
+	t.parentMatrix = matrix
+	t.hasParentMatrix = true
+}
+
+// GetParentMatrix returns the world matrix of the entity's parent, identity
+// when it has none
+func (t *TransformComponent) GetParentMatrix() mgl32.Mat4 {
+	if !t.hasParentMatrix {
+		return mgl32.Ident4()
+	}
+	return t.parentMatrix
+}
+
+// SetRenderMatrix sets the world matrix to draw with this frame
+func (t *TransformComponent) SetRenderMatrix(matrix mgl32.Mat4) {
+	t.renderMatrix = matrix
+	t.hasRenderMatrix = true
+}
+
+// GetRenderMatrix returns the matrix set with SetRenderMatrix, or the world
+// matrix until the transform system has set one
+func (t *TransformComponent) GetRenderMatrix() mgl32.Mat4 {
+	if !t.hasRenderMatrix {
+		return t.GetWorldMatrix()
+	}
+	return t.renderMatrix
+}
+
+func (t *TransformComponent) GetWorldMatrix() mgl32.Mat4 {
+	return t.GetParentMatrix().Mul4(t.GetModelMatrix())
+}
+
+func (t *TransformComponent) GetWorldPosition() mgl32.Vec3 {
+	return t.GetParentMatrix().Mul4x1(t.Position.Vec4(1)).Vec3()
+}
+
+func (t *TransformComponent) GetWorldRotation() mgl32.Quat {
+	return matrixRotation(t.GetParentMatrix()).Mul(t.Rotation).Normalize()
+}
+
+func (t *TransformComponent) GetWorldScale() mgl32.Vec3 {
+	parentScale := matrixScale(t.GetParentMatrix())
+	return mgl32.Vec3{parentScale.X() * t.Scale.X(), parentScale.Y() * t.Scale.Y(), parentScale.Z() * t.Scale.Z()}
+}
+
+// TranslateWorld moves the transform by a world space offset
+func (t *TransformComponent) TranslateWorld(offset mgl32.Vec3) {
+	t.Position = t.Position.Add(t.GetParentMatrix().Inv().Mul4x1(offset.Vec4(0)).Vec3())
+}
+
+// SetWorldTransform sets the local transform so the entity ends up at the given world transform
+func (t *TransformComponent) SetWorldTransform(position mgl32.Vec3, rotation mgl32.Quat, scale mgl32.Vec3) {
+	parentInverse := t.GetParentMatrix().Inv()
+	parentRotation := matrixRotation(t.GetParentMatrix())
+	parentScale := matrixScale(t.GetParentMatrix())
+
+	t.Position = parentInverse.Mul4x1(position.Vec4(1)).Vec3()
+	t.Rotation = parentRotation.Inverse().Mul(rotation).Normalize()
+	t.Scale = mgl32.Vec3{scale.X() / parentScale.X(), scale.Y() / parentScale.Y(), scale.Z() / parentScale.Z()}
+}
+
+func matrixScale(m mgl32.Mat4) mgl32.Vec3 {
+	return mgl32.Vec3{m.Col(0).Vec3().Len(), m.Col(1).Vec3().Len(), m.Col(2).Vec3().Len()}
+}
+
+func matrixRotation(m mgl32.Mat4) mgl32.Quat {
+	scale := matrixScale(m)
+	rotation := mgl32.Mat3FromCols(
+		m.Col(0).Vec3().Mul(1/scale.X()),
+		m.Col(1).Vec3().Mul(1/scale.Y()),
+		m.Col(2).Vec3().Mul(1/scale.Z()),
+	)
+	return mgl32.Mat4ToQuat(rotation.Mat4())
+}
@@ -0,0 +1,79 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// A transform built as a struct literal has no parent until one is set
+func TestTransformLiteralHasNoParent(t *testing.T) {
+	transform := &TransformComponent{
+		Position: mgl32.Vec3{1, 2, 3},
+		Rotation: mgl32.QuatIdent(),
+		Scale:    mgl32.Vec3{1, 1, 1},
+	}
+
+	if position := transform.GetWorldPosition(); position != (mgl32.Vec3{1, 2, 3}) {
+		t.Errorf("world position %v", position)
+	}
+	if matrix := transform.GetRenderMatrix(); matrix != mgl32.Translate3D(1, 2, 3) {
+		t.Errorf("render matrix %v", matrix)
+	}
+
+	transform.TranslateWorld(mgl32.Vec3{1, 0, 0})
+	if transform.Position != (mgl32.Vec3{2, 2, 3}) {
+		t.Errorf("translated to %v", transform.Position)
+	}
+
+	transform.SetWorldTransform(mgl32.Vec3{0, 1, 0}, mgl32.QuatIdent(), mgl32.Vec3{2, 2, 2})
+	if transform.Position != (mgl32.Vec3{0, 1, 0}) || transform.Scale != (mgl32.Vec3{2, 2, 2}) {
+		t.Errorf("world transform set to %v, %v", transform.Position, transform.Scale)
+	}
+}
+
+func TestTransformWorldThroughParent(t *testing.T) {
+	transform := NewTransformComponent(mgl32.Vec3{1, 0, 0})
+	transform.SetParentMatrix(mgl32.Translate3D(0, 5, 0).Mul4(mgl32.Scale3D(2, 2, 2)))
+
+	if position := transform.GetWorldPosition(); !position.ApproxEqual(mgl32.Vec3{2, 5, 0}) {
+		t.Errorf("world position %v", position)
+	}
+
+	transform.TranslateWorld(mgl32.Vec3{2, 0, 0})
+	if !transform.Position.ApproxEqual(mgl32.Vec3{2, 0, 0}) {
+		t.Errorf("translated to local %v", transform.Position)
+	}
+}
+
+// Matrices that were set are used as they are, even when zero
+func TestTransformSetMatrices(t *testing.T) {
+	transform := NewTransformComponent(mgl32.Vec3{1, 2, 3})
+	if transform.GetParentMatrix() != mgl32.Ident4() {
+		t.Errorf("parent matrix %v before being set", transform.GetParentMatrix())
+	}
+
+	// Until the transform system sets it, the render matrix is the world matrix
+	transform.SetParentMatrix(mgl32.Translate3D(0, 5, 0))
+	if matrix := transform.GetRenderMatrix(); matrix != mgl32.Translate3D(1, 7, 3) {
+		t.Errorf("render matrix %v before being set", matrix)
+	}
+
+	transform.SetRenderMatrix(mgl32.Mat4{})
+	if matrix := transform.GetRenderMatrix(); matrix != (mgl32.Mat4{}) {
+		t.Errorf("render matrix %v, set to zero", matrix)
+	}
+
+	// A parent scaled to nothing collapses its children to its position
+	transform.SetParentMatrix(mgl32.Translate3D(0, 5, 0).Mul4(mgl32.Scale3D(0, 0, 0)))
+	if position := transform.GetWorldPosition(); position != (mgl32.Vec3{0, 5, 0}) {
+		t.Errorf("world position %v under a zero scaled parent", position)
+	}
+}
+
+func TestStorePreviousDoesNotAllocate(t *testing.T) {
+	transform := NewTransformComponent(mgl32.Vec3{})
+	if allocs := testing.AllocsPerRun(100, transform.StorePrevious); allocs != 0 {
+		t.Errorf("StorePrevious made %v allocations", allocs)
+	}
+}
@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"0xKowalski/game/input"
+	"0xKowalski/game/window"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Config holds everything InitEngine needs. It can be loaded from a JSON file
+// and overridden from the command line with ParseConfig.
+type Config struct {
+	Window window.WindowConfig
+
+	// Directory relative asset paths are resolved against, found by searching up
+	// from the working directory for an "assets" directory when empty.
+	AssetRoot      string
+	VertexShader   string
+	FragmentShader string
+
+	// Depth only shader shadow maps are drawn with, lights cast no shadows when empty
+	ShadowVertexShader   string
+	ShadowFragmentShader string
+
+	// Shader point light shadow maps are drawn with, point lights cast no
+	// shadows when empty. Drawing a point light's map is six passes, so only
+	// PointShadowBudget maps are redrawn each frame, 0 to redraw them all.
+	PointShadowVertexShader   string
+	PointShadowFragmentShader string
+	PointShadowBudget         int
+
+	PhysicsRate      float64 // Fixed simulation steps per second
+	MaxStepsPerFrame int
+	Gravity          mgl32.Vec3
+
+	Cursor         input.CursorMode
+	RawMouseMotion bool // Where supported, while the cursor is captured
+
+	// Player overrides of the game's input bindings, loaded when Run starts.
+	// Relative to the working directory, a missing file is ignored.
+	BindingsFile string
+
+	// Input recording to write when the engine is cleaned up, and one to replay
+	// from the first frame.
+	RecordInput string
+	ReplayInput string
+
+	Headless  bool
+	FrameTime float64 // Seconds each headless frame advances the clock by
+	Frames    int     // Headless runs stop after this many frames, 0 to run until closed
+
+	// Headless runs draw with the software renderer instead of recording and
+	// write the last frame to this PNG when the engine is cleaned up.
+	Screenshot string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Window: window.WindowConfig{
+			Title:  "Game Window",
+			Width:  800,
+			Height: 600,
+			VSync:  true,
+		},
+		VertexShader:   "assets/shaders/vertex.glsl",
+		FragmentShader: "assets/shaders/fragment.glsl",
+
+		ShadowVertexShader:        "assets/shaders/shadow.vertex.glsl",
+		ShadowFragmentShader:      "assets/shaders/shadow.fragment.glsl",
+		PointShadowVertexShader:   "assets/shaders/point.shadow.vertex.glsl",
+		PointShadowFragmentShader: "assets/shaders/point.shadow.fragment.glsl",
+		PointShadowBudget:         2,
+		PhysicsRate:               60,
+		MaxStepsPerFrame:          5,
+		Gravity:                   mgl32.Vec3{0, -9.8, 0},
+		Cursor:                    input.CursorCaptured,
+		RawMouseMotion:            true,
+		BindingsFile:              "bindings.json",
+		FrameTime:                 1.0 / 60.0,
+	}
+}
+
+// LoadConfig reads a JSON config file, settings missing from the file keep their defaults
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("error parsing config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// ParseConfig builds a config from command line arguments: the defaults, then
+// the file given with -config, then any other flags that were set.
+func ParseConfig(args []string) (Config, error) {
+	flags := flag.NewFlagSet("engine", flag.ContinueOnError)
+
+	configPath := flags.String("config", "", "path to a JSON engine config file")
+	title := flags.String("title", "", "window title")
+	width := flags.Int("width", 0, "window width")
+	height := flags.Int("height", 0, "window height")
+	vsync := flags.Bool("vsync", false, "enable vsync")
+	mode := flags.String("mode", "", "window mode: windowed, fullscreen or borderless")
+	monitor := flags.Int("monitor", 0, "monitor used in fullscreen and borderless modes")
+	msaa := flags.Int("msaa", 0, "MSAA samples per pixel, 0 to disable")
+	assetRoot := flags.String("assets", "", "asset root directory")
+	physicsRate := flags.Float64("physics-rate", 0, "fixed physics steps per second")
+	gravity := flags.String("gravity", "", "gravity as x,y,z")
+	cursor := flags.String("cursor", "", "cursor mode: normal, hidden or captured")
+	bindingsFile := flags.String("bindings", "", "input bindings file, empty to disable")
+	recordInput := flags.String("record", "", "record input to this file")
+	replayInput := flags.String("replay", "", "replay input recorded to this file")
+	headless := flags.Bool("headless", false, "run without a window")
+	frames := flags.Int("frames", 0, "frames to run headless, 0 to run until closed")
+	screenshot := flags.String("screenshot", "", "render headless frames in software and save the last one to this PNG")
+
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := DefaultConfig()
+	if *configPath != "" {
+		loaded, err := LoadConfig(*configPath)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg = loaded
+	}
+
+	// Only flags that were given override the file
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			cfg.Window.Title = *title
+		case "width":
+			cfg.Window.Width = *width
+		case "height":
+			cfg.Window.Height = *height
+		case "vsync":
+			cfg.Window.VSync = *vsync
+		case "mode":
+			if parseErr := cfg.Window.Mode.UnmarshalText([]byte(*mode)); parseErr != nil {
+				err = fmt.Errorf("invalid value %q for -mode: %w", *mode, parseErr)
+			}
+		case "monitor":
+			cfg.Window.Monitor = *monitor
+		case "msaa":
+			cfg.Window.Samples = *msaa
+		case "assets":
+			cfg.AssetRoot = *assetRoot
+		case "physics-rate":
+			cfg.PhysicsRate = *physicsRate
+		case "gravity":
+			if parseErr := parseVec3(*gravity, &cfg.Gravity); parseErr != nil {
+				err = fmt.Errorf("invalid value %q for -gravity: %w", *gravity, parseErr)
+			}
+		case "cursor":
+			if parseErr := cfg.Cursor.UnmarshalText([]byte(*cursor)); parseErr != nil {
+				err = fmt.Errorf("invalid value %q for -cursor: %w", *cursor, parseErr)
+			}
+		case "bindings":
+			cfg.BindingsFile = *bindingsFile
+		case "record":
+			cfg.RecordInput = *recordInput
+		case "replay":
+			cfg.ReplayInput = *replayInput
+		case "headless":
+			cfg.Headless = *headless
+		case "frames":
+			cfg.Frames = *frames
+		case "screenshot":
+			cfg.Screenshot = *screenshot
+		}
+	})
+
+	return cfg, err
+}
+
+func (cfg Config) validate() error {
+	if cfg.PhysicsRate <= 0 {
+		return fmt.Errorf("physics rate must be positive, got %v", cfg.PhysicsRate)
+	}
+	if cfg.MaxStepsPerFrame <= 0 {
+		return fmt.Errorf("max steps per frame must be positive, got %d", cfg.MaxStepsPerFrame)
+	}
+	if cfg.Headless && cfg.FrameTime <= 0 {
+		return fmt.Errorf("headless frame time must be positive, got %v", cfg.FrameTime)
+	}
+	if cfg.PointShadowBudget < 0 {
+		return fmt.Errorf("point shadow budget must not be negative, got %d", cfg.PointShadowBudget)
+	}
+	if cfg.Frames < 0 {
+		return fmt.Errorf("frames must not be negative, got %d", cfg.Frames)
+	}
+	if cfg.Screenshot != "" && !cfg.Headless {
+		return fmt.Errorf("screenshots are only taken by headless runs")
+	}
+	if cfg.Window.Monitor < 0 {
+		return fmt.Errorf("monitor index must not be negative, got %d", cfg.Window.Monitor)
+	}
+	if cfg.Window.Width <= 0 || cfg.Window.Height <= 0 {
+		return fmt.Errorf("window size must be positive, got %dx%d", cfg.Window.Width, cfg.Window.Height)
+	}
+	return nil
+}
+
+func parseVec3(value string, target *mgl32.Vec3) error {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected 3 comma separated values")
+	}
+
+	for i, part := range parts {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return err
+		}
+		target[i] = float32(parsed)
+	}
+
+	return nil
+}
@@ -1,15 +1,20 @@
 package engine
 
 import (
+	"0xKowalski/game/components"
 	"0xKowalski/game/entities"
+	"0xKowalski/game/graphics"
 	"0xKowalski/game/input"
+	"0xKowalski/game/resources"
 	"0xKowalski/game/systems"
 	"0xKowalski/game/window"
+	"errors"
+	"io/fs"
 	"log"
+	"math"
 	"runtime"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
-	"github.com/go-gl/mathgl/mgl32"
 )
 
 func init() {
@@ -20,82 +25,374 @@ func init() {
 
 type Engine struct {
 	LastFrame float64
+	Config    Config
 
-	Window       *window.Window
+	Window       *window.Window  // nil when headless
+	Device       graphics.Device // A RecordingDevice when headless, nil when taking a screenshot
 	InputManager *input.InputManager
+	FakeInput    *input.FakeInput // Drives InputManager when headless, nil otherwise
 
 	// ECS
 	EntityStore *entities.EntityStore
+	Commands    *entities.CommandBuffer // Structural changes, applied after each fixed step and before rendering
 
 	// Systems
-	RenderSystem  *systems.RenderSystem
-	PhysicsSystem *systems.PhysicsSystem
-}
+	Scheduler       *systems.Scheduler
+	RenderSystem    systems.Renderer // A SoftwareRenderer when taking a headless screenshot
+	PhysicsSystem   *systems.PhysicsSystem
+	TransformSystem *systems.TransformSystem
+	CameraSystem    *systems.CameraSystem
 
-func InitEngine() (*Engine, error) {
-	winConfig := window.WindowConfig{
-		Title:  "Game Window",
-		Width:  800,
-		Height: 600,
-	}
+	// Simulation runs in fixed steps, rendering interpolates between them
+	fixedTimeStep float64
+	accumulator   float64
+	alpha         float32
 
-	win, err := window.InitWindow(winConfig)
-	if err != nil {
-		log.Printf("Failed to create window: %v", err)
-		return nil, err
-	}
+	elapsed float64 // Headless clock
+	frames  int
+	closed  bool
+}
 
-	entityStore := entities.NewEntityStore()
+// Names of the built in systems, for ordering game systems with Before and After
+const (
+	InputSystemName     = "input"
+	CameraSystemName    = "camera"
+	PhysicsSystemName   = "physics"
+	CommandsSystemName  = "commands"
+	TransformSystemName = "transform"
+	RenderSystemName    = "render"
+)
 
-	rs, err := systems.NewRenderSystem(win, entityStore)
-	if err != nil {
+// InitEngine creates the engine described by cfg. With cfg.Headless no window
+// or OpenGL context is created, for tests and build machines: the render system
+// draws to a graphics.RecordingDevice holding the last frame's calls, or a
+// SoftwareRenderer draws when taking a screenshot, input comes from
+// Engine.FakeInput and time advances by cfg.FrameTime each frame.
+func InitEngine(cfg Config) (*Engine, error) {
+	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
 
-	ps := systems.NewPhysicsSystem(entityStore, mgl32.Vec3{0, -9.8, 0})
+	resources.SetRoot(cfg.AssetRoot)
 
-	inputManager := input.NewInputManager(win.GlfwWindow)
+	entityStore := entities.NewEntityStore()
 
 	engine := &Engine{
-		Window:       win,
-		InputManager: inputManager,
+		Config: cfg,
 
 		// Ecs
 		EntityStore: entityStore,
+		Commands:    entities.NewCommandBuffer(entityStore),
 
 		//Systems
-		RenderSystem:  rs,
-		PhysicsSystem: ps,
+		Scheduler:       systems.NewScheduler(),
+		PhysicsSystem:   systems.NewPhysicsSystem(entityStore, cfg.Gravity),
+		TransformSystem: systems.NewTransformSystem(entityStore),
+		CameraSystem:    systems.NewCameraSystem(entityStore, cfg.Window.Width, cfg.Window.Height),
+
+		fixedTimeStep: 1 / cfg.PhysicsRate,
+	}
+
+	if cfg.Headless {
+		engine.FakeInput = input.NewFakeInput()
+		engine.InputManager = input.NewInputManagerFromSource(engine.FakeInput)
+		if cfg.Screenshot != "" {
+			softwareRenderer := systems.NewSoftwareRenderer(entityStore, cfg.Window.Width, cfg.Window.Height)
+			softwareRenderer.PointShadowBudget = cfg.PointShadowBudget
+			engine.RenderSystem = softwareRenderer
+		} else {
+			device := graphics.NewRecordingDevice()
+			device.SetViewport(0, 0, cfg.Window.Width, cfg.Window.Height)
+
+			rs, err := engine.newRenderSystem(device)
+			if err != nil {
+				return nil, err
+			}
+			engine.Device = device
+			engine.RenderSystem = rs
+		}
+	} else if err := engine.initWindow(); err != nil {
+		return nil, err
+	}
+
+	engine.InputManager.SetCursorMode(cfg.Cursor)
+	engine.InputManager.SetRawMouseMotion(cfg.RawMouseMotion)
+
+	if err := engine.addBuiltinSystems(); err != nil {
+		return nil, err
+	}
+
+	if cfg.ReplayInput != "" {
+		recording, err := input.LoadRecording(cfg.ReplayInput)
+		if err != nil {
+			return nil, err
+		}
+		engine.InputManager.Replay(recording)
+	}
+	if cfg.RecordInput != "" {
+		engine.InputManager.StartRecording()
 	}
 
 	return engine, nil
 }
 
+func (e *Engine) initWindow() error {
+	win, err := window.InitWindow(e.Config.Window)
+	if err != nil {
+		log.Printf("Failed to create window: %v", err)
+		return err
+	}
+
+	device, err := graphics.NewGLDevice(win)
+	if err != nil {
+		log.Printf("Error initializing renderer: %v", err)
+		return err
+	}
+
+	rs, err := e.newRenderSystem(device)
+	if err != nil {
+		return err
+	}
+
+	e.Window = win
+	e.Device = device
+	win.OnResize(e.CameraSystem.Resize)
+	// The window may not have been created at the configured size, e.g fullscreen
+	e.CameraSystem.Resize(win.GetWidthAndHeight())
+	e.InputManager = input.NewInputManager(win.GlfwWindow)
+	e.RenderSystem = rs
+
+	return nil
+}
+
+// newRenderSystem creates the render system drawing to device with the
+// configured shaders
+func (e *Engine) newRenderSystem(device graphics.Device) (*systems.RenderSystem, error) {
+	rs, err := systems.NewRenderSystem(device, e.EntityStore, e.Config.VertexShader, e.Config.FragmentShader)
+	if err != nil {
+		return nil, err
+	}
+	if e.Config.ShadowVertexShader != "" && e.Config.ShadowFragmentShader != "" {
+		if err := rs.LoadShadowShader(e.Config.ShadowVertexShader, e.Config.ShadowFragmentShader); err != nil {
+			return nil, err
+		}
+	}
+	if e.Config.PointShadowVertexShader != "" && e.Config.PointShadowFragmentShader != "" {
+		if err := rs.LoadPointShadowShader(e.Config.PointShadowVertexShader, e.Config.PointShadowFragmentShader); err != nil {
+			return nil, err
+		}
+	}
+	rs.PointShadowBudget = e.Config.PointShadowBudget
+
+	return rs, nil
+}
+
+func (e *Engine) addBuiltinSystems() error {
+	builtins := []struct {
+		name   string
+		stage  systems.Stage
+		system systems.System
+		opts   []systems.SystemOption
+	}{
+		{InputSystemName, systems.PreUpdate, systems.SystemFunc(func(deltaTime float32) { e.InputManager.Update() }), nil},
+		{CameraSystemName, systems.PreUpdate, e.CameraSystem, nil},
+		{PhysicsSystemName, systems.FixedUpdate, e.PhysicsSystem, []systems.SystemOption{
+			systems.Writes[components.PhysicsComponent](),
+			systems.Writes[components.TransformComponent](),
+			systems.Reads[components.BoxColliderComponent](),
+		}},
+		// Apply spawns, despawns and component changes recorded this frame
+		{CommandsSystemName, systems.PostUpdate, systems.SystemFunc(func(deltaTime float32) { e.Commands.Flush() }), nil},
+		{TransformSystemName, systems.PostUpdate, systems.SystemFunc(func(deltaTime float32) { e.TransformSystem.Update(e.alpha) }), []systems.SystemOption{systems.After(CommandsSystemName)}},
+		{RenderSystemName, systems.Render, e.RenderSystem, nil},
+	}
+
+	for _, builtin := range builtins {
+		if err := e.Scheduler.Add(builtin.name, builtin.stage, builtin.system, builtin.opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (e *Engine) Run(gameLoop func()) {
+	// The game has bound its defaults by now
+	if err := e.LoadBindings(); err != nil {
+		log.Printf("Error loading bindings: %v", err)
+	}
+
 	// Initialize the time of the last frame
-	e.LastFrame = glfw.GetTime()
+	e.LastFrame = e.now()
+
+	for !e.shouldClose() {
+		e.frame(gameLoop)
+	}
+
+	e.Cleanup()
+}
+
+// LoadBindings applies the player's bindings file over the game's default bindings
+func (e *Engine) LoadBindings() error {
+	if e.Config.BindingsFile == "" {
+		return nil
+	}
+
+	err := e.InputManager.Actions.LoadBindings(e.Config.BindingsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
 
-	for !e.Window.GlfwWindow.ShouldClose() {
-		glfw.PollEvents()
+// SaveBindings writes the current bindings to the bindings file, e.g after the
+// player rebinds an action.
+func (e *Engine) SaveBindings() error {
+	if e.Config.BindingsFile == "" {
+		return errors.New("no bindings file configured")
+	}
 
-		// Calculate deltaTime
-		currentTime := glfw.GetTime()
-		deltaTime := currentTime - e.LastFrame
+	return e.InputManager.Actions.SaveBindings(e.Config.BindingsFile)
+}
 
-		e.InputManager.Update()
+// AddSystem schedules a system to run every frame in the given stage, or every
+// fixed step in the FixedUpdate stage. Systems declaring their component access
+// with systems.Reads and systems.Writes may run in parallel with each other.
+func (e *Engine) AddSystem(name string, stage systems.Stage, system systems.System, opts ...systems.SystemOption) error {
+	return e.Scheduler.Add(name, stage, system, opts...)
+}
 
-		gameLoop()
+func (e *Engine) EnableSystem(name string) error {
+	return e.Scheduler.SetEnabled(name, true)
+}
 
-		e.PhysicsSystem.Update(float32(deltaTime))
-		e.RenderSystem.Update()
+// DisableSystem stops a system running until it is enabled again
+func (e *Engine) DisableSystem(name string) error {
+	return e.Scheduler.SetEnabled(name, false)
+}
 
-		e.Window.GlfwWindow.SwapBuffers() // Swap buffers to display the frame
-		e.LastFrame = currentTime
+// RegisterUpdate adds a handler called once per frame with the frame's delta time
+func (e *Engine) RegisterUpdate(handler func(deltaTime float32)) error {
+	return e.Scheduler.Add("", systems.Update, systems.SystemFunc(handler))
+}
+
+// RegisterFixedUpdate adds a handler called every fixed step, before physics.
+// Gameplay that affects the simulation belongs here so it is frame rate independent.
+func (e *Engine) RegisterFixedUpdate(handler func(deltaTime float32)) error {
+	return e.Scheduler.Add("", systems.FixedUpdate, systems.SystemFunc(handler), systems.Before(PhysicsSystemName))
+}
+
+// Step runs a fixed number of frames, letting tests drive the loop
+func (e *Engine) Step(gameLoop func(), frames int) {
+	for i := 0; i < frames; i++ {
+		e.frame(gameLoop)
 	}
+}
 
-	e.Cleanup()
+// Close stops Run after the current frame
+func (e *Engine) Close() {
+	e.closed = true
+	if e.Window != nil {
+		e.Window.SetShouldClose(true)
+	}
+}
+
+func (e *Engine) frame(gameLoop func()) {
+	if e.Config.Headless {
+		e.elapsed += e.Config.FrameTime
+		// Keep only this frame's calls so long runs don't grow the recording
+		if recorder, ok := e.Device.(*graphics.RecordingDevice); ok {
+			recorder.Reset()
+		}
+	} else {
+		e.Window.PollEvents()
+	}
+
+	// Calculate deltaTime
+	currentTime := e.now()
+	deltaTime := currentTime - e.LastFrame
+	if e.Config.Headless {
+		deltaTime = e.Config.FrameTime // Exact, so headless frames line up with fixed steps
+	}
+
+	// Replays step with the recorded frame times so the simulation matches
+	if replayedTime, ok := e.InputManager.ReplayFrameTime(); ok {
+		deltaTime = replayedTime
+	}
+	e.InputManager.RecordFrameTime(deltaTime)
+
+	e.Scheduler.Run(systems.PreUpdate, float32(deltaTime))
+
+	e.alpha = e.fixedUpdate(deltaTime)
+
+	e.Scheduler.Run(systems.Update, float32(deltaTime))
+	gameLoop()
+
+	e.Scheduler.Run(systems.PostUpdate, float32(deltaTime))
+
+	e.Scheduler.Run(systems.Render, float32(deltaTime))
+
+	if e.Window != nil {
+		e.Window.SwapBuffers() // Swap buffers to display the frame
+	}
+	e.EntityStore.ClearChanged()
+	e.LastFrame = currentTime
+	e.frames++
+}
+
+// fixedUpdate runs as many fixed steps as the elapsed time calls for and returns
+// how far the frame is towards the next one.
+func (e *Engine) fixedUpdate(deltaTime float64) float32 {
+	e.accumulator += deltaTime
+
+	steps := 0
+	for e.accumulator >= e.fixedTimeStep {
+		if steps == e.Config.MaxStepsPerFrame {
+			// Too far behind, drop the backlog rather than slowing every frame down
+			e.accumulator = math.Mod(e.accumulator, e.fixedTimeStep)
+			break
+		}
+
+		e.TransformSystem.StorePrevious()
+		e.Scheduler.Run(systems.FixedUpdate, float32(e.fixedTimeStep))
+		// So the next step sees what this one spawned and despawned
+		e.Commands.Flush()
+
+		e.accumulator -= e.fixedTimeStep
+		steps++
+	}
+
+	return float32(e.accumulator / e.fixedTimeStep)
+}
+
+func (e *Engine) now() float64 {
+	if e.Config.Headless {
+		return e.elapsed
+	}
+	return glfw.GetTime()
+}
+
+func (e *Engine) shouldClose() bool {
+	if e.Window != nil {
+		return e.Window.ShouldClose()
+	}
+	return e.closed || (e.Config.Frames > 0 && e.frames >= e.Config.Frames)
 }
 
 func (e *Engine) Cleanup() {
-	e.Window.Cleanup()
+	if e.Config.RecordInput != "" && e.InputManager.Recording() {
+		if err := e.InputManager.StopRecording().Save(e.Config.RecordInput); err != nil {
+			log.Printf("Error saving input recording: %v", err)
+		}
+	}
+
+	if softwareRenderer, ok := e.RenderSystem.(*systems.SoftwareRenderer); ok && e.Config.Screenshot != "" {
+		if err := softwareRenderer.SavePNG(e.Config.Screenshot); err != nil {
+			log.Printf("Error saving screenshot: %v", err)
+		}
+	}
+
+	if e.Window != nil {
+		e.Window.Cleanup()
+	}
 }
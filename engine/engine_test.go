@@ -0,0 +1,425 @@
+package engine
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"0xKowalski/game/graphics"
+	"0xKowalski/game/input"
+	"0xKowalski/game/systems"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func newHeadlessEngine(t *testing.T, configure func(cfg *Config)) *Engine {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Headless = true
+	cfg.BindingsFile = ""
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	engine, err := InitEngine(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func approxEqual(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestHeadlessFallingBody(t *testing.T) {
+	engine := newHeadlessEngine(t, nil)
+	store := engine.EntityStore
+
+	store.NewFreecamEntity(mgl32.Vec3{0, 5, 20})
+	cube := store.NewCubeEntity(mgl32.Vec3{0, 10, 0}, 1)
+	physics := components.NewPhysicsComponent(mgl32.Vec3{}, mgl32.Vec3{}, mgl32.Vec3{}, mgl32.Vec3{}, 1, false)
+	store.AddComponent(*cube, physics)
+
+	// One second at the default 60 steps per second, one step a frame
+	engine.Step(func() {}, 60)
+
+	// Velocity is updated before position each step, so after n steps the body
+	// has fallen g * dt^2 * n(n+1)/2
+	const steps, dt = 60, float32(1.0 / 60)
+	wantVelocity := -9.8 * steps * dt
+	wantY := 10 - 9.8*dt*dt*steps*(steps+1)/2
+
+	if !approxEqual(physics.Velocity.Y(), wantVelocity) {
+		t.Errorf("velocity %v, want y %v", physics.Velocity, wantVelocity)
+	}
+
+	transform, _ := entities.Get[components.TransformComponent](store, *cube)
+	if !approxEqual(transform.Position.Y(), wantY) || transform.Position.X() != 0 || transform.Position.Z() != 0 {
+		t.Errorf("position %v, want y %v", transform.Position, wantY)
+	}
+
+	// Frames end exactly on a fixed step, where interpolation draws the step before.
+	// The device only holds the last frame's calls.
+	if engine.frames != 60 {
+		t.Fatalf("ran %d frames, want 60", engine.frames)
+	}
+	device := engine.Device.(*graphics.RecordingDevice)
+	if len(device.Errors) != 0 {
+		t.Fatalf("device errors %v", device.Errors)
+	}
+	shader := engine.RenderSystem.(*systems.RenderSystem).ShaderProgram.Shader
+	var draws []graphics.DrawCall
+	for _, draw := range device.Draws {
+		if draw.Shader == shader && draw.Framebuffer == 0 {
+			draws = append(draws, draw)
+		}
+	}
+	if len(draws) != 1 {
+		t.Fatalf("last frame drew %d times, want once", len(draws))
+	}
+	wantDrawnY := 10 - 9.8*dt*dt*(steps-1)*steps/2
+	model, _ := draws[0].Uniforms["model"].(mgl32.Mat4)
+	if y := model.Col(3).Y(); !approxEqual(y, wantDrawnY) {
+		t.Errorf("drawn at y %v, want %v", y, wantDrawnY)
+	}
+}
+
+// Headless runs record only the last frame, however long they run
+func TestHeadlessRecordsLastFrame(t *testing.T) {
+	engine := newHeadlessEngine(t, nil)
+	device := engine.Device.(*graphics.RecordingDevice)
+
+	// Nothing but the background without a camera
+	engine.Step(func() {}, 1)
+	if calls := callNames(device); !slices.Equal(calls, []string{"SetClearColor", "Clear"}) {
+		t.Errorf("frame without a camera made calls %q", calls)
+	}
+
+	engine.EntityStore.NewFreecamEntity(mgl32.Vec3{0, 0, 5})
+	engine.EntityStore.NewCubeEntity(mgl32.Vec3{}, 1)
+	engine.Step(func() {}, 2)
+	calls, draws := len(device.Calls), len(device.Draws)
+	if draws == 0 {
+		t.Fatal("nothing drawn")
+	}
+
+	engine.Step(func() {}, 100)
+	if len(device.Calls) != calls || len(device.Draws) != draws {
+		t.Errorf("%d calls and %d draws after 100 more frames, want %d and %d", len(device.Calls), len(device.Draws), calls, draws)
+	}
+}
+
+// callNames lists the names of the device's recorded calls
+func callNames(device *graphics.RecordingDevice) []string {
+	var names []string
+	for _, call := range device.Calls {
+		names = append(names, call.Name)
+	}
+	return names
+}
+
+func TestHeadlessFakeInputDrivesGameplay(t *testing.T) {
+	engine := newHeadlessEngine(t, func(cfg *Config) { cfg.Gravity = mgl32.Vec3{} })
+	store := engine.EntityStore
+
+	body := store.NewEntity()
+	store.AddComponent(body, components.NewTransformComponent(mgl32.Vec3{}))
+	physics := components.NewPhysicsComponent(mgl32.Vec3{}, mgl32.Vec3{}, mgl32.Vec3{}, mgl32.Vec3{}, 1, false)
+	store.AddComponent(body, physics)
+
+	actions := engine.InputManager.Actions
+	actions.Bind("jump", input.Key(glfw.KeySpace))
+
+	jumps := 0
+	engine.RegisterFixedUpdate(func(deltaTime float32) {
+		if actions.JustPressed("jump") {
+			physics.Velocity = physics.Velocity.Add(mgl32.Vec3{0, 6, 0})
+			jumps++
+		}
+	})
+
+	engine.Step(func() {}, 5)
+	engine.FakeInput.PressKey(glfw.KeySpace)
+	engine.Step(func() {}, 10) // Held, so only the first frame is a press
+	engine.FakeInput.ReleaseKey(glfw.KeySpace)
+	engine.Step(func() {}, 5)
+
+	if jumps != 1 {
+		t.Errorf("jumped %d times, want 1", jumps)
+	}
+	if physics.Velocity != (mgl32.Vec3{0, 6, 0}) {
+		t.Errorf("velocity %v", physics.Velocity)
+	}
+
+	// Jumping on frame 6 then moving for the 15 steps after
+	transform, _ := entities.Get[components.TransformComponent](store, body)
+	if !approxEqual(transform.Position.Y(), 6*15.0/60) {
+		t.Errorf("position %v, want y %v", transform.Position, 6*15.0/60)
+	}
+}
+
+func TestRegisterFixedUpdateRunsBeforePhysics(t *testing.T) {
+	engine := newHeadlessEngine(t, nil)
+
+	if err := engine.RegisterFixedUpdate(func(deltaTime float32) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.RegisterUpdate(func(deltaTime float32) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	if order := engine.Scheduler.Order(systems.FixedUpdate); len(order) != 2 || order[0] != "" || order[1] != PhysicsSystemName {
+		t.Errorf("fixed update order %q", order)
+	}
+}
+
+// Commands recorded in a fixed step are applied before the next step, even
+// when a frame runs several
+func TestCommandsFlushAfterEachFixedStep(t *testing.T) {
+	engine := newHeadlessEngine(t, func(cfg *Config) {
+		cfg.PhysicsRate = 60
+		cfg.FrameTime = 3.0 / 60
+	})
+	store := engine.EntityStore
+
+	for i := 0; i < 3; i++ {
+		store.AddComponent(store.NewEntity(), components.NewTransformComponent(mgl32.Vec3{}))
+	}
+	query := entities.NewQuery1[components.TransformComponent](store)
+
+	// Each step despawns one entity and spawns a marker, through the command buffer
+	var seen []int
+	err := engine.RegisterFixedUpdate(func(deltaTime float32) {
+		seen = append(seen, query.Len())
+		despawned := false
+		query.Each(func(entity entities.Entity, transform *components.TransformComponent) {
+			if !despawned {
+				engine.Commands.Despawn(entity)
+				despawned = true
+			}
+		})
+		engine.Commands.Spawn(&components.PhysicsComponent{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine.Step(func() {}, 1)
+
+	if !slices.Equal(seen, []int{3, 2, 1}) {
+		t.Errorf("steps saw %v entities, want [3 2 1]", seen)
+	}
+	if markers := len(entities.GetAll[components.PhysicsComponent](store)); markers != 3 {
+		t.Errorf("%d spawned entities after the frame, want 3", markers)
+	}
+	if engine.Commands.Len() != 0 {
+		t.Errorf("%d commands left after the frame", engine.Commands.Len())
+	}
+}
+
+// newSteppingBody adds a body moving one unit along x every fixed step, and
+// counts the fixed steps run
+func newSteppingBody(t *testing.T, engine *Engine) (*components.TransformComponent, *int) {
+	t.Helper()
+
+	transform := components.NewTransformComponent(mgl32.Vec3{})
+	body := engine.EntityStore.NewEntity()
+	engine.EntityStore.AddComponent(body, transform)
+	velocity := mgl32.Vec3{float32(engine.Config.PhysicsRate), 0, 0}
+	engine.EntityStore.AddComponent(body, components.NewPhysicsComponent(velocity, mgl32.Vec3{}, mgl32.Vec3{}, mgl32.Vec3{}, 1, false))
+
+	steps := 0
+	if err := engine.RegisterFixedUpdate(func(deltaTime float32) { steps++ }); err != nil {
+		t.Fatal(err)
+	}
+	return transform, &steps
+}
+
+// Frames between fixed steps draw bodies partway between their last two steps
+func TestFixedStepInterpolation(t *testing.T) {
+	engine := newHeadlessEngine(t, func(cfg *Config) {
+		cfg.Gravity = mgl32.Vec3{}
+		cfg.PhysicsRate = 60
+		cfg.FrameTime = 1.5 / 60
+	})
+	transform, steps := newSteppingBody(t, engine)
+
+	// One and a half steps a frame: one step then half way, two steps then
+	// exactly on a step, and so on. Drawn a step behind the simulation.
+	for frame, wantSteps := range []int{1, 2, 1, 2} {
+		*steps = 0
+		engine.Step(func() {}, 1)
+
+		if *steps != wantSteps {
+			t.Errorf("frame %d ran %d steps, want %d", frame, *steps, wantSteps)
+		}
+		wantAlpha := float32(0.5 * float64((frame+1)%2))
+		if !approxEqual(engine.alpha, wantAlpha) {
+			t.Errorf("frame %d alpha %v, want %v", frame, engine.alpha, wantAlpha)
+		}
+		if drawnAt, want := transform.GetRenderMatrix().Col(3).X(), 1.5*float32(frame+1)-1; !approxEqual(drawnAt, want) {
+			t.Errorf("frame %d drawn at x %v, want %v", frame, drawnAt, want)
+		}
+	}
+}
+
+// Frames longer than MaxStepsPerFrame steps run that many and drop the rest of
+// the backlog, keeping only the part of a step left over
+func TestFixedStepsClampAndDropBacklog(t *testing.T) {
+	engine := newHeadlessEngine(t, func(cfg *Config) {
+		cfg.Gravity = mgl32.Vec3{}
+		cfg.PhysicsRate = 60
+		cfg.MaxStepsPerFrame = 5
+		cfg.FrameTime = 10.25 / 60
+	})
+	transform, steps := newSteppingBody(t, engine)
+
+	for frame, wantAlpha := range []float32{0.25, 0.5, 0.75} {
+		*steps = 0
+		engine.Step(func() {}, 1)
+
+		if *steps != 5 {
+			t.Errorf("frame %d ran %d steps, want 5", frame, *steps)
+		}
+		// Only the leftover fractions add up between frames
+		if !approxEqual(engine.alpha, wantAlpha) {
+			t.Errorf("frame %d alpha %v, want %v", frame, engine.alpha, wantAlpha)
+		}
+		if drawnAt, want := transform.GetRenderMatrix().Col(3).X(), 5*float32(frame+1)-1+wantAlpha; !approxEqual(drawnAt, want) {
+			t.Errorf("frame %d drawn at x %v, want %v", frame, drawnAt, want)
+		}
+	}
+}
+
+func TestCamerasStartWithConfiguredAspectRatio(t *testing.T) {
+	engine := newHeadlessEngine(t, func(cfg *Config) {
+		cfg.Window.Width, cfg.Window.Height = 1920, 1080
+	})
+
+	freecam := engine.EntityStore.NewFreecamEntity(mgl32.Vec3{})
+	if freecam.CameraComponent.AspectRatio != 1920.0/1080.0 {
+		t.Errorf("aspect ratio %v before the first frame, want %v", freecam.CameraComponent.AspectRatio, 1920.0/1080.0)
+	}
+}
+
+// replayScene is a body moved by the movement axis, jumping on presses and
+// turned by the mouse, for comparing a recorded run against its replay
+type replayScene struct {
+	physics   *components.PhysicsComponent
+	transform *components.TransformComponent
+	jumps     int
+	turned    mgl32.Vec2
+}
+
+func newReplayScene(t *testing.T, engine *Engine) *replayScene {
+	t.Helper()
+
+	store := engine.EntityStore
+	body := store.NewEntity()
+	scene := &replayScene{
+		transform: components.NewTransformComponent(mgl32.Vec3{0, 10, 0}),
+		physics:   components.NewPhysicsComponent(mgl32.Vec3{}, mgl32.Vec3{}, mgl32.Vec3{}, mgl32.Vec3{}, 1, false),
+	}
+	store.AddComponent(body, scene.transform)
+	store.AddComponent(body, scene.physics)
+
+	actions := engine.InputManager.Actions
+	actions.Bind("forward", input.Key(glfw.KeyW), input.GamepadAxis(glfw.AxisLeftY, -1))
+	actions.Bind("backward", input.Key(glfw.KeyS), input.GamepadAxis(glfw.AxisLeftY, 1))
+	actions.Bind("left", input.Key(glfw.KeyA), input.GamepadAxis(glfw.AxisLeftX, -1))
+	actions.Bind("right", input.Key(glfw.KeyD), input.GamepadAxis(glfw.AxisLeftX, 1))
+	actions.BindAxis2D("move", "forward", "backward", "left", "right")
+	actions.Bind("jump", input.Key(glfw.KeySpace))
+
+	err := engine.RegisterFixedUpdate(func(deltaTime float32) {
+		move := actions.Axis2D("move").Mul(4)
+		scene.physics.Velocity = mgl32.Vec3{move.X(), scene.physics.Velocity.Y(), -move.Y()}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = engine.RegisterUpdate(func(deltaTime float32) {
+		if actions.JustPressed("jump") {
+			scene.physics.Velocity = scene.physics.Velocity.Add(mgl32.Vec3{0, 5, 0})
+			scene.jumps++
+		}
+		scene.turned = scene.turned.Add(engine.InputManager.MouseDelta())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return scene
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.json")
+	const frames = 120
+
+	// Frames don't line up with fixed steps, so some frames run none and some two
+	recorded := newHeadlessEngine(t, func(cfg *Config) {
+		cfg.FrameTime = 1.0 / 45
+		cfg.RecordInput = path
+	})
+	recordedScene := newReplayScene(t, recorded)
+
+	fake := recorded.FakeInput
+	fake.MoveMouse(100, 100)
+	recorded.Step(func() {}, 10)
+	fake.PressKey(glfw.KeyW)
+	fake.MoveMouse(130, 90)
+	recorded.Step(func() {}, 15)
+	fake.PressKey(glfw.KeySpace)
+	fake.PressKey(glfw.KeyD)
+	recorded.Step(func() {}, 1)
+	fake.ReleaseKey(glfw.KeySpace)
+	fake.ReleaseKey(glfw.KeyW)
+	fake.MoveMouse(90, 120)
+	recorded.Step(func() {}, 20)
+	fake.ReleaseKey(glfw.KeyD)
+	fake.ConnectGamepad(glfw.Joystick1, "Pad")
+	fake.SetGamepadAxis(glfw.Joystick1, glfw.AxisLeftX, -0.7)
+	recorded.Step(func() {}, 30)
+	fake.PressKey(glfw.KeySpace)
+	fake.SetGamepadAxis(glfw.Joystick1, glfw.AxisLeftY, 0.33)
+	recorded.Step(func() {}, frames-76)
+	recorded.Cleanup()
+
+	recording, err := input.LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recording.Frames != frames || len(recording.FrameTimes) != frames {
+		t.Fatalf("recorded %d frames and %d frame times, want %d", recording.Frames, len(recording.FrameTimes), frames)
+	}
+
+	// Replayed with a different frame time and live input that should be ignored
+	replayed := newHeadlessEngine(t, func(cfg *Config) {
+		cfg.FrameTime = 1.0 / 144
+		cfg.ReplayInput = path
+	})
+	replayedScene := newReplayScene(t, replayed)
+	replayed.FakeInput.PressKey(glfw.KeyS)
+	replayed.Step(func() {}, frames)
+
+	if recordedScene.jumps != 2 || recordedScene.transform.Position.X() == 0 || recordedScene.turned == (mgl32.Vec2{}) {
+		t.Fatalf("recording jumped %d times, moved to %v and turned %v", recordedScene.jumps, recordedScene.transform.Position, recordedScene.turned)
+	}
+	if replayedScene.jumps != recordedScene.jumps {
+		t.Errorf("replay jumped %d times, recording %d", replayedScene.jumps, recordedScene.jumps)
+	}
+	if replayedScene.turned != recordedScene.turned {
+		t.Errorf("replay turned %v, recording %v", replayedScene.turned, recordedScene.turned)
+	}
+	if replayedScene.transform.Position != recordedScene.transform.Position {
+		t.Errorf("replay ended at %v, recording %v", replayedScene.transform.Position, recordedScene.transform.Position)
+	}
+	if replayedScene.physics.Velocity != recordedScene.physics.Velocity {
+		t.Errorf("replay ended with velocity %v, recording %v", replayedScene.physics.Velocity, recordedScene.physics.Velocity)
+	}
+}
@@ -0,0 +1,85 @@
+package entities
+
+import "sync"
+
+// CommandBuffer records structural changes (spawning, freeing, adding and
+// removing components) so they can be made from inside a query or handler and
+// applied later with Flush, when nothing is iterating the store.
+//
+// Commands, Spawn included, can be recorded from systems running in parallel.
+type CommandBuffer struct {
+	store    *EntityStore
+	commands []func(*EntityStore)
+	lock     sync.Mutex
+}
+
+func NewCommandBuffer(store *EntityStore) *CommandBuffer {
+	return &CommandBuffer{store: store}
+}
+
+// Spawn reserves an entity handle straight away so it can be referenced by
+// later commands. The entity is only created, with its components, on Flush.
+func (cb *CommandBuffer) Spawn(components ...Component) Entity {
+	cb.lock.Lock()
+	entity := cb.store.reserveEntity()
+	cb.commands = append(cb.commands, func(store *EntityStore) {
+		store.createReserved(entity)
+	})
+	cb.lock.Unlock()
+
+	for _, component := range components {
+		cb.AddComponent(entity, component)
+	}
+
+	return entity
+}
+
+func (cb *CommandBuffer) Despawn(entity Entity) {
+	cb.record(func(store *EntityStore) {
+		store.FreeEntity(entity)
+	})
+}
+
+func (cb *CommandBuffer) AddComponent(entity Entity, component Component) {
+	cb.record(func(store *EntityStore) {
+		if store.IsAlive(entity) {
+			store.AddComponent(entity, component)
+		}
+	})
+}
+
+func (cb *CommandBuffer) RemoveComponent(entity Entity, componentType Component) {
+	cb.record(func(store *EntityStore) {
+		store.RemoveComponent(entity, componentType)
+	})
+}
+
+func (cb *CommandBuffer) Len() int {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return len(cb.commands)
+}
+
+func (cb *CommandBuffer) record(command func(*EntityStore)) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.commands = append(cb.commands, command)
+}
+
+// Flush applies the recorded commands in order. Commands targeting an entity
+// that has already been freed are skipped.
+func (cb *CommandBuffer) Flush() {
+	// Commands may record further commands, keep going until none are left
+	for cb.Len() > 0 {
+		cb.lock.Lock()
+		commands := cb.commands
+		cb.commands = nil
+		cb.lock.Unlock()
+
+		for _, command := range commands {
+			command(cb.store)
+		}
+	}
+}
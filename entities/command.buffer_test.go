@@ -0,0 +1,164 @@
+package entities
+
+import "testing"
+
+// Structural changes recorded while iterating are only applied on Flush
+func TestCommandBufferDuringQuery(t *testing.T) {
+	store := NewEntityStore()
+	commands := NewCommandBuffer(store)
+	query := NewQuery1[benchPosition](store)
+
+	moving, still, doomed := store.NewEntity(), store.NewEntity(), store.NewEntity()
+	Add(store, moving, &benchPosition{X: 1})
+	Add(store, still, &benchPosition{})
+	Add(store, still, &benchTag{})
+	Add(store, doomed, &benchPosition{X: -1})
+
+	var spawned Entity
+	visited := 0
+	query.Each(func(entity Entity, position *benchPosition) {
+		visited++
+		switch {
+		case position.X > 0:
+			commands.AddComponent(entity, &benchVelocity{})
+			spawned = commands.Spawn(&benchPosition{}, &benchTag{})
+		case position.X < 0:
+			commands.Despawn(entity)
+		default:
+			commands.RemoveComponent(entity, &benchTag{})
+		}
+	})
+
+	if visited != 3 {
+		t.Fatalf("query visited %d entities while recording, want 3", visited)
+	}
+	// Spawn records creating the entity and then one add per component
+	if commands.Len() != 6 {
+		t.Errorf("%d commands recorded, want 6", commands.Len())
+	}
+
+	// Nothing applied yet, the spawned entity is only reserved
+	if Has[benchVelocity](store, moving) || !Has[benchTag](store, still) || !store.IsAlive(doomed) {
+		t.Error("commands applied before Flush")
+	}
+	if store.IsAlive(spawned) {
+		t.Error("spawned entity alive before Flush")
+	}
+
+	commands.Flush()
+
+	if commands.Len() != 0 {
+		t.Errorf("%d commands left after Flush", commands.Len())
+	}
+	if !Has[benchVelocity](store, moving) {
+		t.Error("AddComponent not applied")
+	}
+	if Has[benchTag](store, still) || !Has[benchPosition](store, still) {
+		t.Error("RemoveComponent not applied, or removed too much")
+	}
+	if store.IsAlive(doomed) {
+		t.Error("Despawn not applied")
+	}
+	if !store.IsAlive(spawned) || !Has[benchPosition](store, spawned) || !Has[benchTag](store, spawned) {
+		t.Error("Spawn's components not added")
+	}
+	if query.Len() != 3 {
+		t.Errorf("query matches %d entities after Flush, want 3", query.Len())
+	}
+}
+
+// Commands for an entity freed before Flush, or through a stale handle, don't
+// reach whatever reuses its slot
+func TestCommandBufferStaleEntities(t *testing.T) {
+	store := NewEntityStore()
+	commands := NewCommandBuffer(store)
+
+	stale := store.NewEntity()
+	store.FreeEntity(stale)
+	reused := store.NewEntity()
+	if reused.ID != stale.ID {
+		t.Fatal("freed slot was not reused")
+	}
+	Add(store, reused, &benchPosition{})
+
+	commands.Despawn(stale)
+	commands.AddComponent(stale, &benchVelocity{})
+	commands.RemoveComponent(stale, &benchPosition{})
+	commands.Flush()
+
+	if !store.IsAlive(reused) {
+		t.Error("despawning a stale handle freed the reused slot")
+	}
+	if Has[benchVelocity](store, reused) || !Has[benchPosition](store, reused) {
+		t.Error("commands through a stale handle reached the reused slot")
+	}
+
+	// Freed between recording and Flush
+	entity := store.NewEntity()
+	commands.AddComponent(entity, &benchPosition{})
+	commands.Despawn(entity)
+	commands.Despawn(entity)
+	commands.AddComponent(entity, &benchVelocity{})
+	commands.Flush()
+
+	if store.IsAlive(entity) {
+		t.Error("entity not despawned")
+	}
+	if next := store.NewEntity(); Has[benchPosition](store, next) || Has[benchVelocity](store, next) {
+		t.Error("commands for a despawned entity reached the reused slot")
+	}
+}
+
+// Commands recorded by observers while flushing are applied by the same Flush
+func TestCommandBufferFlushesNestedCommands(t *testing.T) {
+	store := NewEntityStore()
+	commands := NewCommandBuffer(store)
+
+	OnAdd(store, func(entity Entity, position *benchPosition) {
+		commands.AddComponent(entity, &benchVelocity{})
+	})
+
+	entity := commands.Spawn(&benchPosition{})
+	commands.Flush()
+
+	if !Has[benchVelocity](store, entity) || commands.Len() != 0 {
+		t.Error("command recorded during Flush was not applied")
+	}
+}
+
+// Entities spawned through a buffer keep their handles while other entities
+// are created and freed before Flush
+func TestCommandBufferSpawnReservesHandles(t *testing.T) {
+	store := NewEntityStore()
+	commands := NewCommandBuffer(store)
+
+	freed := store.NewEntity()
+	store.FreeEntity(freed)
+
+	reused := commands.Spawn(&benchPosition{X: 1})
+	appended := commands.Spawn(&benchPosition{X: 2})
+	if reused.ID != freed.ID || reused.Generation == freed.Generation {
+		t.Errorf("spawn reserved %v, want the freed slot %d with a new generation", reused, freed.ID)
+	}
+
+	direct := store.NewEntity()
+	if direct == reused || direct == appended {
+		t.Fatalf("NewEntity returned reserved handle %v", direct)
+	}
+	store.Compact()
+
+	commands.Flush()
+
+	for i, entity := range []Entity{reused, appended} {
+		position, ok := Get[benchPosition](store, entity)
+		if !ok || position.X != float32(i+1) {
+			t.Errorf("spawned entity %v has position %v, want X %d", entity, position, i+1)
+		}
+	}
+	if !store.IsAlive(direct) || Has[benchPosition](store, direct) {
+		t.Error("directly created entity disturbed by the flush")
+	}
+	if len(store.ActiveEntities()) != 3 {
+		t.Errorf("%d active entities, want 3", len(store.ActiveEntities()))
+	}
+}
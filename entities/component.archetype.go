@@ -0,0 +1,259 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+)
+
+// componentMask is a bitset of ComponentIDs
+type componentMask []uint64
+
+func newComponentMask(ids []ComponentID) componentMask {
+	mask := componentMask{}
+	for _, id := range ids {
+		word := int(id / 64)
+		for len(mask) <= word {
+			mask = append(mask, 0)
+		}
+		mask[word] |= 1 << (id % 64)
+	}
+	return mask
+}
+
+func (mask componentMask) containsAll(other componentMask) bool {
+	for i, bits := range other {
+		if i >= len(mask) {
+			if bits != 0 {
+				return false
+			}
+			continue
+		}
+		if mask[i]&bits != bits {
+			return false
+		}
+	}
+	return true
+}
+
+func (mask componentMask) containsAny(other componentMask) bool {
+	for i := 0; i < len(mask) && i < len(other); i++ {
+		if mask[i]&other[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// archetype stores every entity that has exactly the same set of component
+// types. Each type gets its own column and an entity's components all live at
+// the same row.
+type archetype struct {
+	ids      []ComponentID
+	mask     componentMask
+	columns  []column
+	entities []Entity
+
+	// columnIndex maps a ComponentID to its position in columns, -1 when absent
+	columnIndex []int
+
+	// Cached transitions to the archetype with one component added or removed
+	addEdges    map[ComponentID]*archetype
+	removeEdges map[ComponentID]*archetype
+}
+
+func newArchetype(ids []ComponentID) *archetype {
+	arch := &archetype{
+		ids:         ids,
+		mask:        newComponentMask(ids),
+		columns:     make([]column, len(ids)),
+		addEdges:    make(map[ComponentID]*archetype),
+		removeEdges: make(map[ComponentID]*archetype),
+	}
+
+	maxID := ComponentID(0)
+	for _, id := range ids {
+		if id > maxID {
+			maxID = id
+		}
+	}
+
+	arch.columnIndex = make([]int, maxID+1)
+	for i := range arch.columnIndex {
+		arch.columnIndex[i] = -1
+	}
+
+	for i, id := range ids {
+		arch.columns[i] = newColumn(id)
+		arch.columnIndex[id] = i
+	}
+
+	return arch
+}
+
+func (arch *archetype) column(id ComponentID) (column, bool) {
+	if int(id) >= len(arch.columnIndex) || arch.columnIndex[id] < 0 {
+		return nil, false
+	}
+	return arch.columns[arch.columnIndex[id]], true
+}
+
+// removeRow swap removes a row, returning the entity that was moved into it
+func (arch *archetype) removeRow(row int) (Entity, bool) {
+	last := len(arch.entities) - 1
+	for _, col := range arch.columns {
+		col.swapRemove(row)
+	}
+
+	moved := arch.entities[last]
+	arch.entities[row] = moved
+	arch.entities = arch.entities[:last]
+
+	return moved, row != last
+}
+
+func archetypeKey(ids []ComponentID) string {
+	return fmt.Sprint(ids)
+}
+
+func (store *EntityStore) getArchetype(ids []ComponentID) *archetype {
+	key := archetypeKey(ids)
+	if arch, ok := store.archetypes[key]; ok {
+		return arch
+	}
+
+	arch := newArchetype(ids)
+	store.archetypes[key] = arch
+
+	// Queries match against the list, and may be created while this runs
+	store.queriesLock.Lock()
+	defer store.queriesLock.Unlock()
+
+	store.archetypeList = append(store.archetypeList, arch)
+	for _, cache := range store.queries {
+		cache.tryMatch(arch)
+	}
+
+	return arch
+}
+
+func (store *EntityStore) archetypeWith(arch *archetype, id ComponentID) *archetype {
+	if arch == nil {
+		return store.getArchetype([]ComponentID{id})
+	}
+
+	if next, ok := arch.addEdges[id]; ok {
+		return next
+	}
+
+	ids := append(append([]ComponentID(nil), arch.ids...), id)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	next := store.getArchetype(ids)
+	arch.addEdges[id] = next
+	next.removeEdges[id] = arch
+	return next
+}
+
+// archetypeWithout returns nil when removing id leaves no components
+func (store *EntityStore) archetypeWithout(arch *archetype, id ComponentID) *archetype {
+	if next, ok := arch.removeEdges[id]; ok {
+		return next
+	}
+
+	ids := make([]ComponentID, 0, len(arch.ids)-1)
+	for _, existing := range arch.ids {
+		if existing != id {
+			ids = append(ids, existing)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	next := store.getArchetype(ids)
+	arch.removeEdges[id] = next
+	next.addEdges[id] = arch
+	return next
+}
+
+// moveEntity moves an entity's components to another archetype. Components the
+// destination has no column for are dropped, and added fills the one column the
+// source archetype does not have when a component is being added.
+func (store *EntityStore) moveEntity(entity Entity, to *archetype, added Component) {
+	slot := &store.slots[entity.ID]
+	from, row := slot.archetype, slot.row
+
+	if to != nil {
+		for i, id := range to.ids {
+			if from != nil {
+				if col, ok := from.column(id); ok {
+					to.columns[i].append(col.get(row))
+					continue
+				}
+			}
+			to.columns[i].append(added)
+		}
+		to.entities = append(to.entities, entity)
+	}
+
+	if from != nil {
+		if moved, ok := from.removeRow(row); ok {
+			store.slots[moved.ID].row = row
+		}
+	}
+
+	slot.archetype = to
+	if to != nil {
+		slot.row = len(to.entities) - 1
+	}
+}
+
+// compactArchetypes drops archetypes without entities and trims the rest
+func (store *EntityStore) compactArchetypes() {
+	store.queriesLock.Lock()
+	defer store.queriesLock.Unlock()
+
+	kept := store.archetypeList[:0]
+	for _, arch := range store.archetypeList {
+		if len(arch.entities) == 0 {
+			delete(store.archetypes, archetypeKey(arch.ids))
+			continue
+		}
+
+		arch.entities = append([]Entity(nil), arch.entities...)
+		for _, col := range arch.columns {
+			col.shrink()
+		}
+		kept = append(kept, arch)
+	}
+
+	for i := len(kept); i < len(store.archetypeList); i++ {
+		store.archetypeList[i] = nil
+	}
+	store.archetypeList = kept
+
+	// Forget edges and query matches that point at removed archetypes
+	for _, arch := range store.archetypeList {
+		for id, next := range arch.addEdges {
+			if len(next.entities) == 0 {
+				delete(arch.addEdges, id)
+			}
+		}
+		for id, next := range arch.removeEdges {
+			if len(next.entities) == 0 {
+				delete(arch.removeEdges, id)
+			}
+		}
+	}
+
+	for _, cache := range store.queries {
+		matched := cache.archetypes[:0]
+		for _, arch := range cache.archetypes {
+			if len(arch.entities) > 0 {
+				matched = append(matched, arch)
+			}
+		}
+		cache.archetypes = matched
+	}
+}
@@ -0,0 +1,206 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+type benchPosition struct{ X, Y, Z float32 }
+type benchVelocity struct{ X, Y, Z float32 }
+type benchTag struct{}
+
+const benchEntities = 100_000
+
+// newBenchStore has n entities with a position and velocity, and every other one
+// a tag so the query spans two archetypes
+func newBenchStore(n int) (*EntityStore, []Entity) {
+	store := NewEntityStore()
+	entities := make([]Entity, n)
+	for i := range entities {
+		entity := store.NewEntity()
+		Add(store, entity, &benchPosition{})
+		Add(store, entity, &benchVelocity{X: 1, Y: 2, Z: 3})
+		if i%2 == 0 {
+			Add(store, entity, &benchTag{})
+		}
+		entities[i] = entity
+	}
+	return store, entities
+}
+
+// mapStore is the component layout archetypes replaced, a map of boxed
+// components per type keyed by entity ID
+type mapStore struct {
+	components map[reflect.Type]map[uint32]Component
+}
+
+func newMapStore(n int) *mapStore {
+	store := &mapStore{components: make(map[reflect.Type]map[uint32]Component)}
+	for i := 0; i < n; i++ {
+		store.add(uint32(i), &benchPosition{})
+		store.add(uint32(i), &benchVelocity{X: 1, Y: 2, Z: 3})
+		if i%2 == 0 {
+			store.add(uint32(i), &benchTag{})
+		}
+	}
+	return store
+}
+
+func (store *mapStore) add(id uint32, component Component) {
+	compType := reflect.TypeOf(component)
+	if store.components[compType] == nil {
+		store.components[compType] = make(map[uint32]Component)
+	}
+	store.components[compType][id] = component
+}
+
+func BenchmarkQuery2_100k(b *testing.B) {
+	store, _ := newBenchStore(benchEntities)
+	query := NewQuery2[benchPosition, benchVelocity](store)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query.Each(func(_ Entity, position *benchPosition, velocity *benchVelocity) {
+			position.X += velocity.X
+			position.Y += velocity.Y
+			position.Z += velocity.Z
+		})
+	}
+}
+
+func BenchmarkQuery2Without_100k(b *testing.B) {
+	store, _ := newBenchStore(benchEntities)
+	query := NewQuery2[benchPosition, benchVelocity](store, Without[benchTag]())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query.Each(func(_ Entity, position *benchPosition, velocity *benchVelocity) {
+			position.X += velocity.X
+		})
+	}
+}
+
+// BenchmarkMapStoreQuery2_100k iterates the same entities the way systems did
+// before archetypes, ranging over one type and looking up the other
+func BenchmarkMapStoreQuery2_100k(b *testing.B) {
+	store := newMapStore(benchEntities)
+	positionType := reflect.TypeOf(&benchPosition{})
+	velocityType := reflect.TypeOf(&benchVelocity{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		velocities := store.components[velocityType]
+		for id, component := range store.components[positionType] {
+			position := component.(*benchPosition)
+			velocity, ok := velocities[id].(*benchVelocity)
+			if !ok {
+				continue
+			}
+			position.X += velocity.X
+			position.Y += velocity.Y
+			position.Z += velocity.Z
+		}
+	}
+}
+
+func BenchmarkGet_100k(b *testing.B) {
+	store, entities := newBenchStore(benchEntities)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entity := range entities {
+			position, _ := Get[benchPosition](store, entity)
+			position.X++
+		}
+	}
+}
+
+func BenchmarkMapStoreGet_100k(b *testing.B) {
+	store := newMapStore(benchEntities)
+	positionType := reflect.TypeOf(&benchPosition{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for id := uint32(0); id < benchEntities; id++ {
+			position := store.components[positionType][id].(*benchPosition)
+			position.X++
+		}
+	}
+}
+
+// BenchmarkAddRemove_100k moves every entity to another archetype and back
+func BenchmarkAddRemove_100k(b *testing.B) {
+	store, entities := newBenchStore(benchEntities)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entity := range entities {
+			if Has[benchTag](store, entity) {
+				Remove[benchTag](store, entity)
+			} else {
+				Add(store, entity, &benchTag{})
+			}
+		}
+	}
+}
+
+// valueColumns is the value layout columns don't use, components stored inline
+// so iteration doesn't chase pointers
+type valueColumns struct {
+	positions  []benchPosition
+	velocities []benchVelocity
+}
+
+// BenchmarkValueColumnQuery2_100k iterates the untagged half of newBenchStore's
+// entities from value columns, to compare against the pointer columns' query
+func BenchmarkValueColumnQuery2_100k(b *testing.B) {
+	// Both archetypes of newBenchStore
+	archetypes := make([]valueColumns, 2)
+	for i := 0; i < benchEntities; i++ {
+		columns := &archetypes[i%2]
+		columns.positions = append(columns.positions, benchPosition{})
+		columns.velocities = append(columns.velocities, benchVelocity{X: 1, Y: 2, Z: 3})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for a := range archetypes {
+			positions, velocities := archetypes[a].positions, archetypes[a].velocities
+			for row := range positions {
+				position, velocity := &positions[row], &velocities[row]
+				position.X += velocity.X
+				position.Y += velocity.Y
+				position.Z += velocity.Z
+			}
+		}
+	}
+}
+
+// Components stay where their caller's pointer is as entities change archetype
+func TestComponentPointersSurviveArchetypeMoves(t *testing.T) {
+	store, entities := newBenchStore(100)
+
+	positions := make([]*benchPosition, len(entities))
+	for i, entity := range entities {
+		positions[i], _ = Get[benchPosition](store, entity)
+		positions[i].X = float32(i)
+	}
+
+	// Every move swap-removes rows from the old archetype and appends to the new one
+	for i, entity := range entities {
+		if i%3 == 0 {
+			Remove[benchTag](store, entity)
+		}
+		Add(store, entity, &benchTag{})
+		if i%5 == 0 {
+			store.FreeEntity(entities[len(entities)-1-i])
+		}
+	}
+
+	for i, entity := range entities {
+		position, ok := Get[benchPosition](store, entity)
+		if ok && (position != positions[i] || position.X != float32(i)) {
+			t.Errorf("%v's position moved from %p to %p", entity, positions[i], position)
+		}
+	}
+}
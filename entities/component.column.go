@@ -0,0 +1,108 @@
+package entities
+
+// column stores one component type for every entity of an archetype, indexed by row.
+//
+// Columns hold component pointers rather than values. AddComponent takes the
+// caller's pointer, and callers keep it: RenderableComponent.TransformComponent,
+// the Freecam struct and most systems hold on to components they added. Storing
+// values would copy the component on Add and again on every archetype move, so
+// those pointers would silently stop reaching the stored component. Chasing the
+// pointers makes iteration around 3x slower than value columns would be, see
+// BenchmarkQuery2_100k against BenchmarkValueColumnQuery2_100k.
+type column interface {
+	len() int
+	get(row int) Component
+	set(row int, component Component)
+	append(component Component)
+	swapRemove(row int)
+	shrink() // Release spare capacity
+}
+
+type columnFactory func() column
+
+// typedColumn is used for types registered through the generic API, so queries
+// can hand out the backing slice directly.
+type typedColumn[T any] struct {
+	data []*T
+}
+
+func newTypedColumn[T any]() column {
+	return &typedColumn[T]{}
+}
+
+func (col *typedColumn[T]) len() int {
+	return len(col.data)
+}
+
+func (col *typedColumn[T]) get(row int) Component {
+	return col.data[row]
+}
+
+func (col *typedColumn[T]) set(row int, component Component) {
+	col.data[row] = component.(*T)
+}
+
+func (col *typedColumn[T]) append(component Component) {
+	col.data = append(col.data, component.(*T))
+}
+
+func (col *typedColumn[T]) swapRemove(row int) {
+	last := len(col.data) - 1
+	col.data[row] = col.data[last]
+	col.data[last] = nil
+	col.data = col.data[:last]
+}
+
+func (col *typedColumn[T]) shrink() {
+	col.data = append([]*T(nil), col.data...)
+}
+
+// boxedColumn backs types that were only ever seen through the reflect based
+// methods, e.g AddComponent before any Get[T] for that type.
+type boxedColumn struct {
+	data []Component
+}
+
+func newBoxedColumn() column {
+	return &boxedColumn{}
+}
+
+func (col *boxedColumn) len() int {
+	return len(col.data)
+}
+
+func (col *boxedColumn) get(row int) Component {
+	return col.data[row]
+}
+
+func (col *boxedColumn) set(row int, component Component) {
+	col.data[row] = component
+}
+
+func (col *boxedColumn) append(component Component) {
+	col.data = append(col.data, component)
+}
+
+func (col *boxedColumn) swapRemove(row int) {
+	last := len(col.data) - 1
+	col.data[row] = col.data[last]
+	col.data[last] = nil
+	col.data = col.data[:last]
+}
+
+func (col *boxedColumn) shrink() {
+	col.data = append([]Component(nil), col.data...)
+}
+
+// columnData returns the components of a column as a typed slice
+func columnData[T any](col column) []*T {
+	if typed, ok := col.(*typedColumn[T]); ok {
+		return typed.data
+	}
+
+	data := make([]*T, col.len())
+	for row := range data {
+		data[row] = col.get(row).(*T)
+	}
+	return data
+}
@@ -0,0 +1,46 @@
+package entities
+
+// Type safe access to components. Components of type T are stored as *T, so
+// Get[components.TransformComponent] returns the *TransformComponent that was
+// added with either Add or AddComponent.
+
+func Add[T any](store *EntityStore, entity Entity, component *T) {
+	store.addComponent(entity, ComponentIDOf[T](), component)
+}
+
+func Get[T any](store *EntityStore, entity Entity) (*T, bool) {
+	col, row, ok := store.lookup(entity, ComponentIDOf[T]())
+	if !ok {
+		return nil, false
+	}
+
+	// Columns are keyed by the type's ID, so anything found here is a *T
+	if typed, ok := col.(*typedColumn[T]); ok {
+		return typed.data[row], true
+	}
+
+	component, ok := col.get(row).(*T)
+	return component, ok
+}
+
+func Has[T any](store *EntityStore, entity Entity) bool {
+	_, ok := Get[T](store, entity)
+	return ok
+}
+
+func Remove[T any](store *EntityStore, entity Entity) {
+	store.removeComponent(entity, ComponentIDOf[T]())
+}
+
+// GetAll returns every stored component of type T
+func GetAll[T any](store *EntityStore) []*T {
+	id := ComponentIDOf[T]()
+	all := []*T{}
+
+	store.query([]ComponentID{id}, nil).eachArchetype(func(arch *archetype) {
+		col, _ := arch.column(id)
+		all = append(all, columnData[T](col)...)
+	})
+
+	return all
+}
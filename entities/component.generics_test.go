@@ -0,0 +1,98 @@
+package entities
+
+import "testing"
+
+func TestAddGetHasRemove(t *testing.T) {
+	store := NewEntityStore()
+	entity := store.NewEntity()
+
+	position := &benchPosition{X: 1}
+	Add(store, entity, position)
+	if got, ok := Get[benchPosition](store, entity); !ok || got != position {
+		t.Fatalf("Get returned %v, %v, want the added component", got, ok)
+	}
+	if !Has[benchPosition](store, entity) {
+		t.Error("Has is false after Add")
+	}
+
+	// Types with the same layout are still different components
+	if got, ok := Get[benchVelocity](store, entity); ok || got != nil {
+		t.Errorf("Get of a missing component returned %v, %v", got, ok)
+	}
+
+	// Adding again replaces rather than adding a second one
+	replacement := &benchPosition{X: 2}
+	Add(store, entity, replacement)
+	if got, _ := Get[benchPosition](store, entity); got != replacement {
+		t.Errorf("Get returned %v after replacing, want %v", got, replacement)
+	}
+	if all := GetAll[benchPosition](store); len(all) != 1 || all[0] != replacement {
+		t.Errorf("GetAll returned %v after replacing", all)
+	}
+
+	velocity := &benchVelocity{}
+	Add(store, entity, velocity)
+	Remove[benchPosition](store, entity)
+	if got, ok := Get[benchPosition](store, entity); ok || got != nil || Has[benchPosition](store, entity) {
+		t.Errorf("Get returned %v, %v after Remove", got, ok)
+	}
+	if got, ok := Get[benchVelocity](store, entity); !ok || got != velocity {
+		t.Error("removing one component lost another")
+	}
+
+	// Removing what isn't there does nothing
+	Remove[benchPosition](store, entity)
+	Remove[benchTag](store, entity)
+	if !Has[benchVelocity](store, entity) {
+		t.Error("removing missing components lost another")
+	}
+}
+
+func TestGetOnMissingOrStaleEntity(t *testing.T) {
+	store := NewEntityStore()
+
+	if _, ok := Get[benchPosition](store, Entity{ID: 42}); ok {
+		t.Error("Get found a component on an entity that was never created")
+	}
+
+	entity := store.NewEntity()
+	if _, ok := Get[benchPosition](store, entity); ok {
+		t.Error("Get found a component on an entity without any")
+	}
+
+	Add(store, entity, &benchPosition{})
+	store.FreeEntity(entity)
+	if _, ok := Get[benchPosition](store, entity); ok || Has[benchPosition](store, entity) {
+		t.Error("Get found a component through a freed entity")
+	}
+
+	// Adding through a stale handle is ignored rather than reaching the reused slot
+	reused := store.NewEntity()
+	Add(store, entity, &benchPosition{})
+	if Has[benchPosition](store, reused) {
+		t.Error("Add through a stale handle reached the reused slot")
+	}
+}
+
+// The reflect based methods and the generic ones see the same components
+func TestGenericAndReflectAccessAgree(t *testing.T) {
+	store := NewEntityStore()
+	entity := store.NewEntity()
+
+	position := &benchPosition{}
+	store.AddComponent(entity, position)
+	if got, ok := Get[benchPosition](store, entity); !ok || got != position {
+		t.Errorf("Get returned %v, %v for a component added with AddComponent", got, ok)
+	}
+
+	velocity := &benchVelocity{}
+	Add(store, entity, velocity)
+	if got := store.GetComponent(entity, &benchVelocity{}); got != velocity {
+		t.Errorf("GetComponent returned %v for a component added with Add", got)
+	}
+
+	store.RemoveComponent(entity, &benchVelocity{})
+	if Has[benchVelocity](store, entity) {
+		t.Error("Has is true after RemoveComponent")
+	}
+}
@@ -0,0 +1,88 @@
+package entities
+
+// Observers let systems react to components being attached to or detached from
+// entities, and to components that gameplay code has marked as changed.
+
+type componentHook func(Entity, Component)
+
+// OnAdd calls fn after a T is added to an entity, including when it replaces an existing T
+func OnAdd[T any](store *EntityStore, fn func(Entity, *T)) {
+	id := ComponentIDOf[T]()
+	store.onAdd[id] = append(store.onAdd[id], func(entity Entity, component Component) {
+		fn(entity, component.(*T))
+	})
+}
+
+// OnRemove calls fn before a T is removed from an entity, either directly, by
+// being replaced, or because the entity is freed.
+func OnRemove[T any](store *EntityStore, fn func(Entity, *T)) {
+	id := ComponentIDOf[T]()
+	store.onRemove[id] = append(store.onRemove[id], func(entity Entity, component Component) {
+		fn(entity, component.(*T))
+	})
+}
+
+// MarkChanged flags the entity's T as changed for the current frame
+func MarkChanged[T any](store *EntityStore, entity Entity) {
+	if !Has[T](store, entity) {
+		return
+	}
+
+	id := ComponentIDOf[T]()
+	set, ok := store.changed[id]
+	if !ok {
+		set = &changedSet{index: make(map[Entity]struct{})}
+		store.changed[id] = set
+	}
+	set.add(entity)
+}
+
+// Changed returns the entities whose T was marked changed this frame, in the
+// order they were marked.
+func Changed[T any](store *EntityStore) []Entity {
+	set, ok := store.changed[ComponentIDOf[T]()]
+	if !ok {
+		return nil
+	}
+
+	changed := make([]Entity, 0, len(set.entities))
+	for _, entity := range set.entities {
+		if Has[T](store, entity) {
+			changed = append(changed, entity)
+		}
+	}
+	return changed
+}
+
+// ClearChanged resets every changed set, it is called once at the end of each frame
+func (store *EntityStore) ClearChanged() {
+	for _, set := range store.changed {
+		set.entities = set.entities[:0]
+		clear(set.index)
+	}
+}
+
+type changedSet struct {
+	entities []Entity
+	index    map[Entity]struct{}
+}
+
+func (set *changedSet) add(entity Entity) {
+	if _, ok := set.index[entity]; ok {
+		return
+	}
+	set.index[entity] = struct{}{}
+	set.entities = append(set.entities, entity)
+}
+
+func (store *EntityStore) notifyAdd(entity Entity, id ComponentID, component Component) {
+	for _, hook := range store.onAdd[id] {
+		hook(entity, component)
+	}
+}
+
+func (store *EntityStore) notifyRemove(entity Entity, id ComponentID, component Component) {
+	for _, hook := range store.onRemove[id] {
+		hook(entity, component)
+	}
+}
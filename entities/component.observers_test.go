@@ -0,0 +1,161 @@
+package entities
+
+import (
+	"slices"
+	"testing"
+)
+
+// observed records what the observers of a T were called with
+type observed[T any] struct {
+	added, removed []*T
+}
+
+func observe[T any](t *testing.T, store *EntityStore) *observed[T] {
+	calls := &observed[T]{}
+	OnAdd(store, func(entity Entity, component *T) {
+		if got, _ := Get[T](store, entity); got != component {
+			t.Error("OnAdd called before the component was stored")
+		}
+		calls.added = append(calls.added, component)
+	})
+	OnRemove(store, func(entity Entity, component *T) {
+		if got, _ := Get[T](store, entity); got != component {
+			t.Error("OnRemove called after the component was detached")
+		}
+		calls.removed = append(calls.removed, component)
+	})
+	return calls
+}
+
+func (calls *observed[T]) check(t *testing.T, action string, added, removed []*T) {
+	t.Helper()
+
+	if !slices.Equal(calls.added, added) || !slices.Equal(calls.removed, removed) {
+		t.Errorf("after %s added %v and removed %v, want %v and %v", action, calls.added, calls.removed, added, removed)
+	}
+	calls.added, calls.removed = nil, nil
+}
+
+func TestObserversOnAddAndRemove(t *testing.T) {
+	store := NewEntityStore()
+	positions := observe[benchPosition](t, store)
+	velocities := observe[benchVelocity](t, store)
+	entity := store.NewEntity()
+
+	first := &benchPosition{X: 1}
+	Add(store, entity, first)
+	positions.check(t, "Add", []*benchPosition{first}, nil)
+
+	// Replacing removes the old component, then adds the new one
+	second := &benchPosition{X: 2}
+	store.AddComponent(entity, second)
+	positions.check(t, "replacing", []*benchPosition{second}, []*benchPosition{first})
+
+	velocity := &benchVelocity{}
+	Add(store, entity, velocity)
+	velocities.check(t, "Add", []*benchVelocity{velocity}, nil)
+	positions.check(t, "adding another type", nil, nil)
+
+	Remove[benchPosition](store, entity)
+	positions.check(t, "Remove", nil, []*benchPosition{second})
+
+	// Removing what isn't there calls nothing
+	Remove[benchPosition](store, entity)
+	store.RemoveComponent(entity, &benchPosition{})
+	positions.check(t, "removing a missing component", nil, nil)
+
+	third := &benchPosition{X: 3}
+	Add(store, entity, third)
+	positions.check(t, "adding again", []*benchPosition{third}, nil)
+
+	// Freeing removes every component, once
+	store.FreeEntity(entity)
+	positions.check(t, "FreeEntity", nil, []*benchPosition{third})
+	velocities.check(t, "FreeEntity", nil, []*benchVelocity{velocity})
+
+	store.FreeEntity(entity)
+	Add(store, entity, &benchPosition{})
+	positions.check(t, "using a freed entity", nil, nil)
+}
+
+// Observers may change the entity they are called for
+func TestObserversMovingTheEntity(t *testing.T) {
+	store := NewEntityStore()
+	OnAdd(store, func(entity Entity, position *benchPosition) {
+		Add(store, entity, &benchVelocity{})
+	})
+	OnRemove(store, func(entity Entity, position *benchPosition) {
+		Remove[benchVelocity](store, entity)
+	})
+
+	entity := store.NewEntity()
+	Add(store, entity, &benchPosition{})
+	if !Has[benchVelocity](store, entity) || !Has[benchPosition](store, entity) {
+		t.Error("component added by OnAdd missing")
+	}
+
+	Remove[benchPosition](store, entity)
+	if Has[benchVelocity](store, entity) || Has[benchPosition](store, entity) {
+		t.Error("component removed by OnRemove still there")
+	}
+
+	Add(store, entity, &benchPosition{})
+	store.FreeEntity(entity)
+	if store.IsAlive(entity) {
+		t.Error("entity not freed")
+	}
+}
+
+func TestChangedSets(t *testing.T) {
+	store := NewEntityStore()
+	a, b, c := store.NewEntity(), store.NewEntity(), store.NewEntity()
+	for _, entity := range []Entity{a, b, c} {
+		Add(store, entity, &benchPosition{})
+	}
+	Add(store, c, &benchVelocity{})
+
+	if changed := Changed[benchPosition](store); len(changed) != 0 {
+		t.Errorf("changed %v before marking anything", changed)
+	}
+
+	// In the order marked, once each
+	MarkChanged[benchPosition](store, c)
+	MarkChanged[benchPosition](store, a)
+	MarkChanged[benchPosition](store, c)
+	if changed := Changed[benchPosition](store); !slices.Equal(changed, []Entity{c, a}) {
+		t.Errorf("changed %v, want %v", changed, []Entity{c, a})
+	}
+
+	// Sets are per component type, and marking a missing component does nothing
+	MarkChanged[benchVelocity](store, a)
+	MarkChanged[benchVelocity](store, c)
+	if changed := Changed[benchVelocity](store); !slices.Equal(changed, []Entity{c}) {
+		t.Errorf("changed velocities %v, want %v", changed, []Entity{c})
+	}
+
+	// Entities that lost the component or were freed since are left out
+	Remove[benchPosition](store, a)
+	store.FreeEntity(c)
+	MarkChanged[benchPosition](store, b)
+	if changed := Changed[benchPosition](store); !slices.Equal(changed, []Entity{b}) {
+		t.Errorf("changed %v after removing and freeing, want %v", changed, []Entity{b})
+	}
+
+	// Stale handles don't match the entity reusing the slot
+	reused := store.NewEntity()
+	Add(store, reused, &benchPosition{})
+	if changed := Changed[benchPosition](store); !slices.Equal(changed, []Entity{b}) {
+		t.Errorf("changed %v after reusing a marked slot, want %v", changed, []Entity{b})
+	}
+
+	store.ClearChanged()
+	if len(Changed[benchPosition](store)) != 0 || len(Changed[benchVelocity](store)) != 0 {
+		t.Error("changed sets not cleared")
+	}
+
+	// Entities can be marked again next frame
+	MarkChanged[benchPosition](store, b)
+	if changed := Changed[benchPosition](store); !slices.Equal(changed, []Entity{b}) {
+		t.Errorf("changed %v after clearing, want %v", changed, []Entity{b})
+	}
+}
@@ -0,0 +1,87 @@
+package entities
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ComponentID identifies a component type inside an EntityStore.
+// IDs are handed out the first time a type is seen and never change afterwards.
+type ComponentID uint32
+
+// componentKey is a zero sized key that is unique per component type, letting
+// the generic API find a type's ID without going through reflect.
+type componentKey[T any] struct{}
+
+var componentRegistry = struct {
+	sync.RWMutex
+	byKey  map[any]ComponentID
+	byType map[reflect.Type]ComponentID
+	types  []reflect.Type
+
+	// Column constructor per ComponentID
+	columns []columnFactory
+}{
+	byKey:  make(map[any]ComponentID),
+	byType: make(map[reflect.Type]ComponentID),
+}
+
+// ComponentIDOf returns the ID of the component type T, where components of
+// type T are stored as *T.
+func ComponentIDOf[T any]() ComponentID {
+	componentRegistry.RLock()
+	id, ok := componentRegistry.byKey[componentKey[T]{}]
+	componentRegistry.RUnlock()
+	if ok {
+		return id
+	}
+
+	return registerComponentType(componentKey[T]{}, reflect.TypeOf((*T)(nil)), newTypedColumn[T])
+}
+
+// componentIDOfValue returns the ID for the dynamic type of a component, used by
+// the non generic EntityStore methods.
+func componentIDOfValue(component Component) ComponentID {
+	compType := reflect.TypeOf(component)
+
+	componentRegistry.RLock()
+	id, ok := componentRegistry.byType[compType]
+	componentRegistry.RUnlock()
+	if ok {
+		return id
+	}
+
+	return registerComponentType(nil, compType, nil)
+}
+
+// registerComponentType assigns an ID to compType. Types first seen through
+// reflection get boxed columns until the generic API provides a typed factory.
+func registerComponentType(key any, compType reflect.Type, factory columnFactory) ComponentID {
+	componentRegistry.Lock()
+	defer componentRegistry.Unlock()
+
+	id, ok := componentRegistry.byType[compType]
+	if !ok {
+		id = ComponentID(len(componentRegistry.types))
+		componentRegistry.types = append(componentRegistry.types, compType)
+		componentRegistry.columns = append(componentRegistry.columns, newBoxedColumn)
+		componentRegistry.byType[compType] = id
+	}
+
+	if key != nil {
+		componentRegistry.byKey[key] = id
+	}
+
+	if factory != nil {
+		componentRegistry.columns[id] = factory
+	}
+
+	return id
+}
+
+func newColumn(id ComponentID) column {
+	componentRegistry.RLock()
+	defer componentRegistry.RUnlock()
+
+	return componentRegistry.columns[id]()
+}
@@ -44,25 +44,13 @@ func generateCube(size float32) ([]components.Vertex, []uint32) {
 	return vertices, indices
 }
 
-type CubeOption func(*EntityStore, *Entity)
-
-func (es *EntityStore) NewCubeEntity(position mgl32.Vec3, size float32, opts ...CubeOption) *Entity {
-	entity := es.NewEntity()
-
+func newCubeModel(size float32) *components.ModelComponent {
 	vertices, indices := generateCube(size)
 
-	transform := components.NewTransformComponent(position)
-	es.AddComponent(entity, transform)
-
 	meshComponents := make([]*components.MeshComponent, 1)
 	mesh := components.NewMeshComponent(vertices, indices)
 	meshComponents[0] = mesh
 
-	// Similarly, initialize the buffer components slice
-	bufferComponents := make([]*components.BufferComponent, 1)
-	buffer := components.NewBufferComponent(vertices, indices)
-	bufferComponents[0] = buffer
-
 	// Initialize the material components slice
 	materialComponents := make([]*components.MaterialComponent, 1)
 	material := components.NewMaterialComponent(
@@ -72,10 +60,22 @@ func (es *EntityStore) NewCubeEntity(position mgl32.Vec3, size float32, opts ...
 
 	materialComponents[0] = material
 
-	modelComponent := &components.ModelComponent{
+	return &components.ModelComponent{
 		MeshComponents:     meshComponents,
 		MaterialComponents: materialComponents,
-		BufferComponents:   bufferComponents}
+		Source:             components.ModelSource{Primitive: "cube", Size: size},
+	}
+}
+
+type CubeOption func(*EntityStore, *Entity)
+
+func (es *EntityStore) NewCubeEntity(position mgl32.Vec3, size float32, opts ...CubeOption) *Entity {
+	entity := es.NewEntity()
+
+	transform := components.NewTransformComponent(position)
+	es.AddComponent(entity, transform)
+
+	modelComponent := newCubeModel(size)
 	es.AddComponent(entity, modelComponent)
 
 	// Apply any additional options
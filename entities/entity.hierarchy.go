@@ -0,0 +1,134 @@
+package entities
+
+import (
+	"0xKowalski/game/components"
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Parent/child relationships between entities. A child's TransformComponent is
+// relative to its parent, the transform system resolves world matrices top-down.
+
+type ParentComponent struct {
+	Parent Entity
+}
+
+// ChildrenComponent is maintained by the store. Freeing a parent frees its
+// children, removing the component on its own detaches them.
+type ChildrenComponent struct {
+	Children []Entity
+}
+
+func (store *EntityStore) registerHierarchyObservers() {
+	OnRemove(store, func(child Entity, parentComponent *ParentComponent) {
+		childrenComponent, ok := Get[ChildrenComponent](store, parentComponent.Parent)
+		if !ok {
+			return
+		}
+
+		for i, existing := range childrenComponent.Children {
+			if existing == child {
+				childrenComponent.Children = append(childrenComponent.Children[:i], childrenComponent.Children[i+1:]...)
+				break
+			}
+		}
+	})
+
+	OnRemove(store, func(parent Entity, childrenComponent *ChildrenComponent) {
+		freeing := store.slots[parent.ID].freeing
+		for _, child := range append([]Entity(nil), childrenComponent.Children...) {
+			if freeing {
+				store.FreeEntity(child)
+			} else {
+				store.ClearParent(child, true)
+			}
+		}
+	})
+}
+
+// SetParent attaches child to parent. With keepWorldTransform the child's local
+// transform is adjusted so it stays where it currently is in the world,
+// otherwise its current transform is taken as relative to the parent.
+func (store *EntityStore) SetParent(child, parent Entity, keepWorldTransform bool) error {
+	if !store.IsAlive(child) || !store.IsAlive(parent) {
+		return fmt.Errorf("cannot parent %v to %v, entity has been freed", child, parent)
+	}
+
+	for ancestor, ok := parent, true; ok; ancestor, ok = store.GetParent(ancestor) {
+		if ancestor == child {
+			return fmt.Errorf("cannot parent %v to its own descendant %v", child, parent)
+		}
+	}
+
+	if current, ok := store.GetParent(child); ok && current == parent {
+		return nil
+	}
+
+	store.updateParentMatrix(child, store.worldMatrix(parent), keepWorldTransform)
+
+	Add(store, child, &ParentComponent{Parent: parent})
+
+	childrenComponent, ok := Get[ChildrenComponent](store, parent)
+	if !ok {
+		childrenComponent = &ChildrenComponent{}
+		Add(store, parent, childrenComponent)
+	}
+	childrenComponent.Children = append(childrenComponent.Children, child)
+
+	return nil
+}
+
+// ClearParent detaches child from its parent, making its transform relative to the world
+func (store *EntityStore) ClearParent(child Entity, keepWorldTransform bool) {
+	if _, ok := store.GetParent(child); !ok {
+		return
+	}
+
+	store.updateParentMatrix(child, mgl32.Ident4(), keepWorldTransform)
+	Remove[ParentComponent](store, child)
+}
+
+func (store *EntityStore) GetParent(child Entity) (Entity, bool) {
+	parentComponent, ok := Get[ParentComponent](store, child)
+	if !ok {
+		return Entity{}, false
+	}
+	return parentComponent.Parent, true
+}
+
+func (store *EntityStore) GetChildren(parent Entity) []Entity {
+	childrenComponent, ok := Get[ChildrenComponent](store, parent)
+	if !ok {
+		return nil
+	}
+	return append([]Entity(nil), childrenComponent.Children...)
+}
+
+// worldMatrix returns the entity's world matrix, entities without a transform
+// pass their parent's through.
+func (store *EntityStore) worldMatrix(entity Entity) mgl32.Mat4 {
+	if transform, ok := Get[components.TransformComponent](store, entity); ok {
+		return transform.GetWorldMatrix()
+	}
+
+	if parent, ok := store.GetParent(entity); ok {
+		return store.worldMatrix(parent)
+	}
+
+	return mgl32.Ident4()
+}
+
+func (store *EntityStore) updateParentMatrix(entity Entity, parentMatrix mgl32.Mat4, keepWorldTransform bool) {
+	transform, ok := Get[components.TransformComponent](store, entity)
+	if !ok {
+		return
+	}
+
+	position, rotation, scale := transform.GetWorldPosition(), transform.GetWorldRotation(), transform.GetWorldScale()
+	transform.SetParentMatrix(parentMatrix)
+
+	if keepWorldTransform {
+		transform.SetWorldTransform(position, rotation, scale)
+	}
+}
@@ -0,0 +1,60 @@
+package entities
+
+import (
+	"0xKowalski/game/components"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// newFamily is a parent at x=1 with a child at x=2, and a grandchild at x=3
+func newFamily(t *testing.T, store *EntityStore) (parent, child, grandchild Entity) {
+	parent, child, grandchild = store.NewEntity(), store.NewEntity(), store.NewEntity()
+	Add(store, parent, components.NewTransformComponent(mgl32.Vec3{1, 0, 0}))
+	Add(store, child, components.NewTransformComponent(mgl32.Vec3{1, 0, 0}))
+	Add(store, grandchild, components.NewTransformComponent(mgl32.Vec3{1, 0, 0}))
+
+	if err := store.SetParent(child, parent, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetParent(grandchild, child, false); err != nil {
+		t.Fatal(err)
+	}
+	return parent, child, grandchild
+}
+
+func TestFreeParentFreesSubtree(t *testing.T) {
+	store := NewEntityStore()
+	parent, child, grandchild := newFamily(t, store)
+
+	store.FreeEntity(parent)
+
+	for _, entity := range []Entity{parent, child, grandchild} {
+		if store.IsAlive(entity) {
+			t.Errorf("%v is alive after its ancestor was freed", entity)
+		}
+	}
+}
+
+func TestRemoveChildrenDetaches(t *testing.T) {
+	store := NewEntityStore()
+	parent, child, grandchild := newFamily(t, store)
+
+	Remove[ChildrenComponent](store, parent)
+
+	if !store.IsAlive(child) || !store.IsAlive(grandchild) {
+		t.Fatal("removing ChildrenComponent freed the children")
+	}
+	if _, ok := store.GetParent(child); ok {
+		t.Error("child still has a parent")
+	}
+	if parent, ok := store.GetParent(grandchild); !ok || parent != child {
+		t.Error("grandchild lost its own parent")
+	}
+
+	// Detached children keep their place in the world
+	transform, _ := Get[components.TransformComponent](store, child)
+	if position := transform.GetWorldPosition(); !position.ApproxEqual(mgl32.Vec3{2, 0, 0}) {
+		t.Errorf("child moved to %v", position)
+	}
+}
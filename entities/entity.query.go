@@ -0,0 +1,203 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Queries
+
+// QueryFilter narrows the entities a query matches beyond its fetched components.
+type QueryFilter func(*queryFilter)
+
+type queryFilter struct {
+	include []ComponentID
+	exclude []ComponentID
+}
+
+// With only matches entities that also have a T, without fetching it
+func With[T any]() QueryFilter {
+	return withID(ComponentIDOf[T]())
+}
+
+// Without skips entities that have a T
+func Without[T any]() QueryFilter {
+	return func(f *queryFilter) {
+		f.exclude = append(f.exclude, ComponentIDOf[T]())
+	}
+}
+
+func withID(id ComponentID) QueryFilter {
+	return func(f *queryFilter) {
+		f.include = append(f.include, id)
+	}
+}
+
+// queryCache holds the archetypes matching one include/exclude signature. New
+// archetypes are matched as they are created, so iterating a query only
+// touches tables that hold matching entities.
+type queryCache struct {
+	include componentMask
+	exclude componentMask
+
+	archetypes []*archetype
+}
+
+func (store *EntityStore) query(include, exclude []ComponentID) *queryCache {
+	include = sortedIDs(include)
+	exclude = sortedIDs(exclude)
+
+	key := fmt.Sprint(include, exclude)
+
+	store.queriesLock.Lock()
+	defer store.queriesLock.Unlock()
+
+	if cache, ok := store.queries[key]; ok {
+		return cache
+	}
+
+	cache := &queryCache{
+		include: newComponentMask(include),
+		exclude: newComponentMask(exclude),
+	}
+
+	for _, arch := range store.archetypeList {
+		cache.tryMatch(arch)
+	}
+
+	store.queries[key] = cache
+	return cache
+}
+
+func (cache *queryCache) tryMatch(arch *archetype) {
+	if arch.mask.containsAll(cache.include) && !arch.mask.containsAny(cache.exclude) {
+		cache.archetypes = append(cache.archetypes, arch)
+	}
+}
+
+func (cache *queryCache) eachArchetype(fn func(*archetype)) {
+	for _, arch := range cache.archetypes {
+		if len(arch.entities) > 0 {
+			fn(arch)
+		}
+	}
+}
+
+func sortedIDs(ids []ComponentID) []ComponentID {
+	sorted := append([]ComponentID(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// Drop duplicates
+	unique := sorted[:0]
+	for i, id := range sorted {
+		if i == 0 || id != sorted[i-1] {
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
+// Typed queries. Components must not be added or removed while iterating with Each.
+
+type Query struct {
+	store   *EntityStore
+	cache   *queryCache
+	fetched []ComponentID
+}
+
+func newQuery(store *EntityStore, fetched []ComponentID, filters []QueryFilter) Query {
+	filter := &queryFilter{include: fetched}
+	for _, f := range filters {
+		f(filter)
+	}
+
+	return Query{
+		store:   store,
+		cache:   store.query(filter.include, filter.exclude),
+		fetched: fetched,
+	}
+}
+
+// NewQuery matches entities using filters only, e.g NewQuery(store, With[A](), Without[B]())
+func NewQuery(store *EntityStore, filters ...QueryFilter) Query {
+	return newQuery(store, nil, filters)
+}
+
+func (q Query) Len() int {
+	count := 0
+	q.cache.eachArchetype(func(arch *archetype) { count += len(arch.entities) })
+	return count
+}
+
+// Entities returns a copy of the matching entities
+func (q Query) Entities() []Entity {
+	var entities []Entity
+	q.Each(func(entity Entity) { entities = append(entities, entity) })
+	return entities
+}
+
+func (q Query) Each(fn func(Entity)) {
+	q.cache.eachArchetype(func(arch *archetype) {
+		for _, entity := range arch.entities {
+			fn(entity)
+		}
+	})
+}
+
+// fetch returns the typed column for the i'th fetched component of an archetype
+func fetch[T any](q Query, arch *archetype, i int) []*T {
+	col, _ := arch.column(q.fetched[i])
+	return columnData[T](col)
+}
+
+type Query1[A any] struct {
+	Query
+}
+
+func NewQuery1[A any](store *EntityStore, filters ...QueryFilter) Query1[A] {
+	return Query1[A]{newQuery(store, []ComponentID{ComponentIDOf[A]()}, filters)}
+}
+
+func (q Query1[A]) Each(fn func(Entity, *A)) {
+	q.cache.eachArchetype(func(arch *archetype) {
+		as := fetch[A](q.Query, arch, 0)
+		for row, entity := range arch.entities {
+			fn(entity, as[row])
+		}
+	})
+}
+
+type Query2[A, B any] struct {
+	Query
+}
+
+func NewQuery2[A, B any](store *EntityStore, filters ...QueryFilter) Query2[A, B] {
+	return Query2[A, B]{newQuery(store, []ComponentID{ComponentIDOf[A](), ComponentIDOf[B]()}, filters)}
+}
+
+func (q Query2[A, B]) Each(fn func(Entity, *A, *B)) {
+	q.cache.eachArchetype(func(arch *archetype) {
+		as, bs := fetch[A](q.Query, arch, 0), fetch[B](q.Query, arch, 1)
+		for row, entity := range arch.entities {
+			fn(entity, as[row], bs[row])
+		}
+	})
+}
+
+type Query3[A, B, C any] struct {
+	Query
+}
+
+func NewQuery3[A, B, C any](store *EntityStore, filters ...QueryFilter) Query3[A, B, C] {
+	return Query3[A, B, C]{newQuery(store, []ComponentID{ComponentIDOf[A](), ComponentIDOf[B](), ComponentIDOf[C]()}, filters)}
+}
+
+func (q Query3[A, B, C]) Each(fn func(Entity, *A, *B, *C)) {
+	q.cache.eachArchetype(func(arch *archetype) {
+		as, bs, cs := fetch[A](q.Query, arch, 0), fetch[B](q.Query, arch, 1), fetch[C](q.Query, arch, 2)
+		for row, entity := range arch.entities {
+			fn(entity, as[row], bs[row], cs[row])
+		}
+	})
+}
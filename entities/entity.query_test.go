@@ -0,0 +1,127 @@
+package entities
+
+import (
+	"sync"
+	"testing"
+)
+
+// Systems running in parallel may create queries while the main thread makes
+// archetypes, run with -race
+func TestQueryCreationDuringArchetypeCreation(t *testing.T) {
+	store := NewEntityStore()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			NewQuery1[benchPosition](store)
+			NewQuery2[benchPosition, benchVelocity](store, Without[benchTag]())
+			NewQuery(store, With[benchTag]())
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		entity := store.NewEntity()
+		switch i % 3 {
+		case 0:
+			Add(store, entity, &benchTag{})
+			Add(store, entity, &benchPosition{})
+		case 1:
+			Add(store, entity, &benchVelocity{})
+			Add(store, entity, &benchPosition{})
+		case 2:
+			Add(store, entity, &benchPosition{})
+			Add(store, entity, &benchVelocity{})
+			Add(store, entity, &benchTag{})
+		}
+	}
+	wg.Wait()
+
+	if n := NewQuery2[benchPosition, benchVelocity](store, Without[benchTag]()).Len(); n != 33 {
+		t.Errorf("query matched %d entities, want 33", n)
+	}
+	if n := NewQuery(store, With[benchTag]()).Len(); n != 67 {
+		t.Errorf("query matched %d entities, want 67", n)
+	}
+}
+
+// queried collects the entities a query visits, checking each is handed its own components
+func queried[A, B any](t *testing.T, store *EntityStore, q Query2[A, B]) map[Entity]bool {
+	t.Helper()
+
+	seen := make(map[Entity]bool)
+	q.Each(func(entity Entity, a *A, b *B) {
+		if seen[entity] {
+			t.Errorf("%v visited twice", entity)
+		}
+		seen[entity] = true
+
+		if wantA, _ := Get[A](store, entity); a != wantA {
+			t.Errorf("%v was handed another entity's component", entity)
+		}
+		if wantB, _ := Get[B](store, entity); b != wantB {
+			t.Errorf("%v was handed another entity's component", entity)
+		}
+	})
+	return seen
+}
+
+func checkMatches(t *testing.T, name string, got map[Entity]bool, want ...Entity) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s matched %v, want %v", name, got, want)
+		return
+	}
+	for _, entity := range want {
+		if !got[entity] {
+			t.Errorf("%s matched %v, want %v", name, got, want)
+			return
+		}
+	}
+}
+
+// Queries made before any entity exist follow entities as they move between archetypes
+func TestQueryFiltersFollowArchetypeMoves(t *testing.T) {
+	store := NewEntityStore()
+	moving := NewQuery2[benchPosition, benchVelocity](store, Without[benchTag]())
+	tagged := NewQuery2[benchPosition, benchVelocity](store, With[benchTag]())
+	all := NewQuery3[benchPosition, benchVelocity, benchTag](store)
+
+	spawn := func(components ...Component) Entity {
+		entity := store.NewEntity()
+		for _, component := range components {
+			store.AddComponent(entity, component)
+		}
+		return entity
+	}
+	a := spawn(&benchPosition{}, &benchVelocity{})
+	b := spawn(&benchPosition{}, &benchVelocity{}, &benchTag{})
+	c := spawn(&benchPosition{})
+	d := spawn(&benchVelocity{}, &benchPosition{}, &benchTag{}) // Same archetype as b, added in another order
+
+	checkMatches(t, "Without[tag]", queried(t, store, moving), a)
+	checkMatches(t, "With[tag]", queried(t, store, tagged), b, d)
+
+	Remove[benchTag](store, b)      // b now moves
+	Add(store, c, &benchVelocity{}) // c now moves
+	Add(store, a, &benchTag{})      // a is now tagged
+	store.FreeEntity(d)
+
+	checkMatches(t, "Without[tag]", queried(t, store, moving), b, c)
+	checkMatches(t, "With[tag]", queried(t, store, tagged), a)
+
+	seen := make(map[Entity]bool)
+	all.Each(func(entity Entity, position *benchPosition, velocity *benchVelocity, tag *benchTag) {
+		if want, _ := Get[benchTag](store, entity); tag != want {
+			t.Errorf("%v was handed another entity's tag", entity)
+		}
+		seen[entity] = true
+	})
+	checkMatches(t, "Query3", seen, a)
+
+	if moving.Len() != 2 || tagged.Len() != 1 || all.Len() != 1 {
+		t.Errorf("lengths %d, %d and %d", moving.Len(), tagged.Len(), all.Len())
+	}
+}
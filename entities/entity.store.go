@@ -1,60 +1,202 @@
 package entities
 
 import (
-	"reflect"
+	"log"
+	"sync"
 )
 
+// EntityStore keeps components in archetypes: every distinct set of component
+// types gets a table with one column per type, and entities move between
+// tables as components are added and removed.
 type EntityStore struct {
-	entities    []Entity
+	slots       []entitySlot
+	freeList    []uint32 // Indices of freed slots, reused before the slots grow
 	activeCount int
-	components  map[reflect.Type]map[uint32]Component
+
+	// Entities may be reserved from systems running in parallel, reserveLock
+	// guards freeList and the reservation counts
+	reserveLock  sync.Mutex
+	pendingSlots uint32 // Slots reserved past the end of slots, every index up to len(slots)+pendingSlots is taken
+	reserved     int    // Reserved entities not yet created
+
+	// Generation given to slots recreated after Compact trimmed them, so handles
+	// to trimmed slots stay stale
+	generationFloor uint32
+
+	archetypes    map[string]*archetype
+	archetypeList []*archetype
+
+	queries     map[string]*queryCache
+	queriesLock sync.Mutex // Guards queries and archetypeList, queries may be created from systems running in parallel
+
+	// Observers
+	onAdd    map[ComponentID][]componentHook
+	onRemove map[ComponentID][]componentHook
+	changed  map[ComponentID]*changedSet
+}
+
+// entitySlot tracks one entity index. archetype is nil while the entity has no components.
+type entitySlot struct {
+	generation uint32
+	alive      bool
+	freeing    bool // Set while OnRemove observers run for a FreeEntity call
+
+	archetype *archetype
+	row       int
 }
 
 func NewEntityStore() *EntityStore {
-	return &EntityStore{
-		components: make(map[reflect.Type]map[uint32]Component),
+	store := &EntityStore{
+		archetypes: make(map[string]*archetype),
+		queries:    make(map[string]*queryCache),
+		onAdd:      make(map[ComponentID][]componentHook),
+		onRemove:   make(map[ComponentID][]componentHook),
+		changed:    make(map[ComponentID]*changedSet),
 	}
+
+	store.registerHierarchyObservers()
+
+	return store
 }
 
 // Entities
 
+// Entity is a handle made of a slot index and the generation of that slot.
+// Freeing an entity bumps the generation, so old handles stop resolving even
+// once the slot is reused.
 type Entity struct {
-	ID     uint32
-	Active bool
+	ID         uint32
+	Generation uint32
 }
 
 func (store *EntityStore) NewEntity() Entity {
-	if store.activeCount < len(store.entities) {
+	entity := store.reserveEntity()
+	store.createReserved(entity)
+
+	return entity
+}
+
+// reserveEntity picks the handle the next entity will get without touching
+// the slots, so it is safe to call while other systems read the store. The
+// entity only becomes alive once createReserved is called for it.
+func (store *EntityStore) reserveEntity() Entity {
+	store.reserveLock.Lock()
+	defer store.reserveLock.Unlock()
+
+	store.reserved++
+
+	if len(store.freeList) > 0 {
 		// Reuse a previously freed entity slot.
-		entity := &store.entities[store.activeCount]
-		entity.Active = true
-		entity.ID = uint32(store.activeCount)
-		store.activeCount++
-		return *entity
-	} else {
-		// Expand the array with a new entity.
-		id := uint32(len(store.entities))
-		entity := Entity{ID: id, Active: true}
-		store.entities = append(store.entities, entity)
-		store.activeCount++
-		return entity
-	}
-}
-
-// Memory currently grows infinetely, swap for a batched approach in future
-func (store *EntityStore) FreeEntity(id uint32) {
-	if int(id) < len(store.entities) && store.entities[id].Active {
-		store.entities[id].Active = false
-		store.activeCount--
-		// Swap with the last active entity
-		if store.activeCount > 0 && int(id) != store.activeCount {
-			store.entities[id], store.entities[store.activeCount] = store.entities[store.activeCount], store.entities[id]
+		id := store.freeList[len(store.freeList)-1]
+		store.freeList = store.freeList[:len(store.freeList)-1]
+		return Entity{ID: id, Generation: store.slots[id].generation}
+	}
+
+	// Claim a slot past the end, the array is expanded on creation.
+	id := uint32(len(store.slots)) + store.pendingSlots
+	store.pendingSlots++
+	return Entity{ID: id, Generation: store.generationFloor}
+}
+
+// createReserved makes a reserved entity alive
+func (store *EntityStore) createReserved(entity Entity) {
+	store.reserveLock.Lock()
+	for int(entity.ID) >= len(store.slots) {
+		store.slots = append(store.slots, entitySlot{generation: store.generationFloor})
+		store.pendingSlots--
+	}
+	store.reserved--
+	store.reserveLock.Unlock()
+
+	slot := &store.slots[entity.ID]
+	slot.alive = true
+	store.activeCount++
+}
+
+// FreeEntity removes all of the entity's components and invalidates its handle
+func (store *EntityStore) FreeEntity(entity Entity) {
+	if !store.IsAlive(entity) || store.slots[entity.ID].freeing {
+		return
+	}
+
+	store.slots[entity.ID].freeing = true
+	if arch := store.slots[entity.ID].archetype; arch != nil {
+		// Snapshot first, observers may move the entity while we notify
+		row := store.slots[entity.ID].row
+		removed := make([]Component, len(arch.ids))
+		for i := range arch.ids {
+			removed[i] = arch.columns[i].get(row)
+		}
+
+		for i, id := range arch.ids {
+			store.notifyRemove(entity, id, removed[i])
 		}
 	}
+
+	store.moveEntity(entity, nil, nil)
+
+	slot := &store.slots[entity.ID]
+	slot.alive = false
+	slot.freeing = false
+	slot.generation++
+	store.activeCount--
+
+	store.reserveLock.Lock()
+	store.freeList = append(store.freeList, entity.ID)
+	store.reserveLock.Unlock()
+}
+
+// IsAlive reports whether the entity has not been freed since the handle was created
+func (store *EntityStore) IsAlive(entity Entity) bool {
+	if int(entity.ID) >= len(store.slots) {
+		return false
+	}
+
+	slot := store.slots[entity.ID]
+	return slot.alive && slot.generation == entity.Generation
 }
 
 func (store *EntityStore) ActiveEntities() []Entity {
-	return store.entities[:store.activeCount]
+	entities := make([]Entity, 0, store.activeCount)
+	for id, slot := range store.slots {
+		if slot.alive {
+			entities = append(entities, Entity{ID: uint32(id), Generation: slot.generation})
+		}
+	}
+	return entities
+}
+
+// Compact releases memory left behind by freed entities: trailing free slots
+// are dropped, empty archetypes are removed and every backing slice is
+// shrunk to its length. Slots are left alone while a CommandBuffer holds
+// spawned entities that haven't been flushed yet.
+func (store *EntityStore) Compact() {
+	store.reserveLock.Lock()
+	reserved := store.reserved
+	store.reserveLock.Unlock()
+	if reserved > 0 {
+		store.compactArchetypes()
+		return
+	}
+
+	end := len(store.slots)
+	for end > 0 && !store.slots[end-1].alive {
+		end--
+		if store.slots[end].generation > store.generationFloor {
+			store.generationFloor = store.slots[end].generation
+		}
+	}
+	store.slots = append([]entitySlot(nil), store.slots[:end]...)
+
+	freeList := make([]uint32, 0, len(store.freeList))
+	for _, id := range store.freeList {
+		if int(id) < end {
+			freeList = append(freeList, id)
+		}
+	}
+	store.freeList = freeList
+
+	store.compactArchetypes()
 }
 
 // Components
@@ -62,67 +204,101 @@ func (store *EntityStore) ActiveEntities() []Entity {
 type Component interface{}
 
 func (store *EntityStore) AddComponent(entity Entity, component Component) {
-	compType := reflect.TypeOf(component)
-	if store.components[compType] == nil {
-		store.components[compType] = make(map[uint32]Component)
+	store.addComponent(entity, componentIDOfValue(component), component)
+}
+
+func (store *EntityStore) addComponent(entity Entity, id ComponentID, component Component) {
+	if !store.IsAlive(entity) {
+		log.Printf("Cannot add component to freed entity %v", entity)
+		return
+	}
+
+	// Replacing a component counts as removing the old one
+	if col, row, ok := store.lookup(entity, id); ok {
+		store.notifyRemove(entity, id, col.get(row))
+
+		if !store.IsAlive(entity) {
+			return
+		}
+	}
+
+	if col, row, ok := store.lookup(entity, id); ok {
+		col.set(row, component)
+	} else {
+		from := store.slots[entity.ID].archetype
+		store.moveEntity(entity, store.archetypeWith(from, id), component)
+	}
+
+	store.notifyAdd(entity, id, component)
+}
+
+// lookup returns the column holding an entity's component of the given type
+func (store *EntityStore) lookup(entity Entity, id ComponentID) (column, int, bool) {
+	if !store.IsAlive(entity) {
+		return nil, 0, false
+	}
+
+	slot := store.slots[entity.ID]
+	if slot.archetype == nil {
+		return nil, 0, false
 	}
-	store.components[compType][entity.ID] = component
+
+	col, ok := slot.archetype.column(id)
+	return col, slot.row, ok
 }
 
 func (store *EntityStore) GetComponent(entity Entity, componentType Component) Component {
-	compType := reflect.TypeOf(componentType)
-	if comps, ok := store.components[compType]; ok {
-		return comps[entity.ID]
+	col, row, ok := store.lookup(entity, componentIDOfValue(componentType))
+	if !ok {
+		return nil
 	}
-	return nil
+	return col.get(row)
 }
 
 func (store *EntityStore) GetAllComponents(componentType Component) []Component {
-	compType := reflect.TypeOf(componentType)
+	id := componentIDOfValue(componentType)
 	components := []Component{}
 
-	if comps, ok := store.components[compType]; ok {
-		for _, comp := range comps {
-			components = append(components, comp)
+	store.query([]ComponentID{id}, nil).eachArchetype(func(arch *archetype) {
+		col, _ := arch.column(id)
+		for row := range arch.entities {
+			components = append(components, col.get(row))
 		}
-	}
+	})
 
 	return components
 }
 
 func (store *EntityStore) RemoveComponent(entity Entity, componentType Component) {
-	compType := reflect.TypeOf(componentType)
-	if comps, ok := store.components[compType]; ok {
-		delete(comps, entity.ID)
-	}
+	store.removeComponent(entity, componentIDOfValue(componentType))
 }
 
-// This should be o(1), fix me later
-func (store *EntityStore) GetEntitiesWithComponentType(componentType Component) []Entity {
-	compType := reflect.TypeOf(componentType)
-	var entities []Entity
-
-	if comps, ok := store.components[compType]; ok {
-		for id := range comps {
-			if store.entities[id].Active {
-				entities = append(entities, store.entities[id])
-			}
-		}
+func (store *EntityStore) removeComponent(entity Entity, id ComponentID) {
+	col, row, ok := store.lookup(entity, id)
+	if !ok {
+		return
 	}
 
-	return entities
+	store.notifyRemove(entity, id, col.get(row))
+
+	// Observers may have already removed it
+	if _, _, ok := store.lookup(entity, id); !ok {
+		return
+	}
+
+	from := store.slots[entity.ID].archetype
+	store.moveEntity(entity, store.archetypeWithout(from, id), nil)
+}
+
+func (store *EntityStore) GetEntitiesWithComponentType(componentType Component) []Entity {
+	return NewQuery(store, withID(componentIDOfValue(componentType))).Entities()
 }
 
 // Does not prevent duplicates
 func (store *EntityStore) GetEntityWithComponentType(componentType Component) Entity {
-	compType := reflect.TypeOf(componentType)
-
-	if comps, ok := store.components[compType]; ok {
-		for id := range comps {
-			if store.entities[id].Active {
-				return store.entities[id]
-			}
-		}
+	entities := store.GetEntitiesWithComponentType(componentType)
+	if len(entities) > 0 {
+		return entities[0]
 	}
 
 	panic("No entity with that type") // Only used for camera component atm, which is essential
@@ -0,0 +1,105 @@
+package entities
+
+import (
+	"runtime"
+	"testing"
+)
+
+// churn spawns and frees a million entities with components, in batches so
+// freed slots are reused while others are still alive
+func churn(store *EntityStore) {
+	const batch = 10_000
+	entities := make([]Entity, batch)
+	for round := 0; round < 1_000_000/batch; round++ {
+		for i := range entities {
+			entities[i] = store.NewEntity()
+			Add(store, entities[i], &benchPosition{X: float32(i)})
+			Add(store, entities[i], &benchVelocity{})
+		}
+		for _, entity := range entities {
+			store.FreeEntity(entity)
+		}
+	}
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestFreeEntityKeepsMemoryFlat(t *testing.T) {
+	store := NewEntityStore()
+
+	churn(store)
+	after := heapAlloc()
+	slots := len(store.slots)
+
+	churn(store)
+	again := heapAlloc()
+
+	if len(store.slots) != slots {
+		t.Errorf("slots grew from %d to %d", slots, len(store.slots))
+	}
+	if again > after && again-after > 1<<20 {
+		t.Errorf("heap grew by %d bytes over a second million entities", again-after)
+	}
+	if store.activeCount != 0 || len(store.ActiveEntities()) != 0 {
+		t.Errorf("%d entities still active", store.activeCount)
+	}
+}
+
+func TestStaleHandleAfterReuse(t *testing.T) {
+	store := NewEntityStore()
+
+	stale := store.NewEntity()
+	Add(store, stale, &benchPosition{X: 1})
+	store.FreeEntity(stale)
+
+	reused := store.NewEntity()
+	Add(store, reused, &benchPosition{X: 2})
+	if reused.ID != stale.ID {
+		t.Fatalf("freed slot %d not reused, got %d", stale.ID, reused.ID)
+	}
+
+	if store.IsAlive(stale) {
+		t.Error("stale handle is alive")
+	}
+	if _, ok := Get[benchPosition](store, stale); ok {
+		t.Error("stale handle resolved to the reused slot's component")
+	}
+	if position, ok := Get[benchPosition](store, reused); !ok || position.X != 2 {
+		t.Errorf("reused entity has position %v, %v", position, ok)
+	}
+}
+
+func TestStaleHandleAfterCompact(t *testing.T) {
+	store := NewEntityStore()
+
+	entities := make([]Entity, 100)
+	for i := range entities {
+		entities[i] = store.NewEntity()
+		Add(store, entities[i], &benchPosition{})
+	}
+	for _, entity := range entities {
+		store.FreeEntity(entity)
+	}
+
+	store.Compact()
+	if len(store.slots) != 0 || len(store.freeList) != 0 {
+		t.Fatalf("compact left %d slots and %d free", len(store.slots), len(store.freeList))
+	}
+
+	// Slots are recreated from index 0, with generations past every freed handle
+	for _, stale := range entities {
+		entity := store.NewEntity()
+		Add(store, entity, &benchPosition{})
+		if store.IsAlive(stale) {
+			t.Fatalf("stale handle %v is alive after compact", stale)
+		}
+		if _, ok := Get[benchPosition](store, stale); ok {
+			t.Fatalf("stale handle %v resolved after compact", stale)
+		}
+	}
+}
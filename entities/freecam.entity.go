@@ -24,7 +24,7 @@ func (es *EntityStore) NewFreecamEntity(position mgl32.Vec3) *Freecam {
 		-90.0,               // Yaw: Initial yaw angle, facing forward along the Z-axis
 		0.0,                 // Pitch: Initial pitch angle, looking straight at the horizon
 		45.0,                // Field of view in degrees
-		800.0/600.0,         // Aspect ratio: width divided by height of the viewport
+		0,                   // Aspect ratio: set from the window size by the camera system
 		0.1,                 // Near clipping plane: the closest distance the camera can see
 		100.0,               // Far clipping plane: the farthest distance the camera can see
 	)
@@ -2,6 +2,8 @@ package entities
 
 import (
 	"0xKowalski/game/components"
+	"fmt"
+
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -21,3 +23,19 @@ func (es *EntityStore) NewModelEntity(position mgl32.Vec3, objPath string, mtlPa
 
 	return &entity
 }
+
+// NewModelFromSource rebuilds a model from the source it was created with
+func NewModelFromSource(source components.ModelSource) (*components.ModelComponent, error) {
+	switch source.Primitive {
+	case "":
+		return components.LoadModelComponent(source.ObjPath, source.MtlPath)
+	case "cube":
+		return newCubeModel(source.Size), nil
+	case "plane":
+		return newPlaneModel(), nil
+	case "sphere":
+		return newSphereModel(source.Size, source.Segments, source.Rings), nil
+	}
+
+	return nil, fmt.Errorf("unknown model primitive %q", source.Primitive)
+}
@@ -22,23 +22,12 @@ var defaultPlaneIndices = []uint32{
 	0, 1, 2, 3, 4, 5,
 }
 
-func (es *EntityStore) NewPlaneEntity(position mgl32.Vec3) *Entity {
-	entity := es.NewEntity()
-
-	transform := components.NewTransformComponent(position)
-	es.AddComponent(entity, transform)
-	transform.SetScale(50, 50, 50)
-
+func newPlaneModel() *components.ModelComponent {
 	// Mesh component for the plane
 	meshComponents := make([]*components.MeshComponent, 1)
 	mesh := components.NewMeshComponent(defaultPlaneVertices, defaultPlaneIndices)
 	meshComponents[0] = mesh
 
-	// Buffer component for the plane
-	bufferComponents := make([]*components.BufferComponent, 1)
-	buffer := components.NewBufferComponent(defaultPlaneVertices, defaultPlaneIndices)
-	bufferComponents[0] = buffer
-
 	// Material component for the plane
 	materialComponents := make([]*components.MaterialComponent, 1)
 	material := components.NewMaterialComponent(
@@ -48,11 +37,21 @@ func (es *EntityStore) NewPlaneEntity(position mgl32.Vec3) *Entity {
 	materialComponents[0] = material
 
 	// Model component that aggregates mesh, material, and buffer components
-	modelComponent := &components.ModelComponent{
+	return &components.ModelComponent{
 		MeshComponents:     meshComponents,
 		MaterialComponents: materialComponents,
-		BufferComponents:   bufferComponents,
+		Source:             components.ModelSource{Primitive: "plane"},
 	}
+}
+
+func (es *EntityStore) NewPlaneEntity(position mgl32.Vec3) *Entity {
+	entity := es.NewEntity()
+
+	transform := components.NewTransformComponent(position)
+	es.AddComponent(entity, transform)
+	transform.SetScale(50, 50, 50)
+
+	modelComponent := newPlaneModel()
 	es.AddComponent(entity, modelComponent)
 
 	// Renderable component to integrate with the rendering system
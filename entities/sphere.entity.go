@@ -48,12 +48,7 @@ func generateSphere(stacks, slices int, radius float32) ([]components.Vertex, []
 	return vertices, indices
 }
 
-func (es *EntityStore) NewSphereEntity(position mgl32.Vec3, radius float32, segments int, rings int) *Entity {
-	entity := es.NewEntity()
-
-	transform := components.NewTransformComponent(position)
-	es.AddComponent(entity, transform)
-
+func newSphereModel(radius float32, segments int, rings int) *components.ModelComponent {
 	// Generate vertices and indices for the sphere
 	vertices, indices := generateSphere(segments, rings, radius)
 
@@ -61,10 +56,6 @@ func (es *EntityStore) NewSphereEntity(position mgl32.Vec3, radius float32, segm
 	mesh := components.NewMeshComponent(vertices, indices)
 	meshComponents[0] = mesh
 
-	bufferComponents := make([]*components.BufferComponent, 1)
-	buffer := components.NewBufferComponent(vertices, indices)
-	bufferComponents[0] = buffer
-
 	materialComponents := make([]*components.MaterialComponent, 1)
 	material := components.NewMaterialComponent(
 		"assets/textures/container.png",
@@ -73,11 +64,20 @@ func (es *EntityStore) NewSphereEntity(position mgl32.Vec3, radius float32, segm
 
 	materialComponents[0] = material
 
-	modelComponent := &components.ModelComponent{
+	return &components.ModelComponent{
 		MeshComponents:     meshComponents,
 		MaterialComponents: materialComponents,
-		BufferComponents:   bufferComponents,
+		Source:             components.ModelSource{Primitive: "sphere", Size: radius, Segments: segments, Rings: rings},
 	}
+}
+
+func (es *EntityStore) NewSphereEntity(position mgl32.Vec3, radius float32, segments int, rings int) *Entity {
+	entity := es.NewEntity()
+
+	transform := components.NewTransformComponent(position)
+	es.AddComponent(entity, transform)
+
+	modelComponent := newSphereModel(radius, segments, rings)
 	es.AddComponent(entity, modelComponent)
 
 	renderable := components.NewRenderableComponent(transform, modelComponent)
@@ -4,8 +4,10 @@ import (
 	"0xKowalski/game/components"
 	"0xKowalski/game/engine"
 	"0xKowalski/game/entities"
+	"0xKowalski/game/input"
 	"log"
 	"math"
+	"os"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
@@ -51,6 +53,20 @@ func (player *Player) updateCameraVectors() {
 type Game struct {
 	Engine *engine.Engine
 	Player *Player
+	Paused bool
+}
+
+// SetPaused freezes the simulation and releases the cursor while paused
+func (g *Game) SetPaused(paused bool) {
+	g.Paused = paused
+
+	if paused {
+		g.Engine.InputManager.SetCursorMode(input.CursorNormal)
+		g.Engine.DisableSystem(engine.PhysicsSystemName)
+	} else {
+		g.Engine.InputManager.SetCursorMode(input.CursorCaptured)
+		g.Engine.EnableSystem(engine.PhysicsSystemName)
+	}
 }
 
 func (g *Game) MainLoop() {
@@ -61,7 +77,13 @@ func main() {
 
 	game := Game{}
 
-	eng, err := engine.InitEngine()
+	cfg, err := engine.ParseConfig(os.Args[1:])
+	if err != nil {
+		log.Printf("Error reading config: %v", err)
+		panic(err)
+	}
+
+	eng, err := engine.InitEngine(cfg)
 	if err != nil {
 		log.Printf("Error starting engine: %v", err)
 		panic(err)
@@ -81,13 +103,13 @@ func main() {
 		-90.0,               // Yaw: Initial yaw angle, facing forward along the Z-axis
 		0.0,                 // Pitch: Initial pitch angle, looking straight at the horizon
 		45.0,                // Field of view in degrees
-		800.0/600.0,         // Aspect ratio: width divided by height of the viewport
+		0,                   // Aspect ratio: set from the window size by the camera system
 		0.1,                 // Near clipping plane: the closest distance the camera can see
 		100.0,               // Far clipping plane: the farthest distance the camera can see
 	)
 	game.Engine.EntityStore.AddComponent(entity, cameraComp)
 
-	physicsComponent := components.NewPhysicsComponent(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, 0}, 1, mgl32.Vec3{1, 0.5, 1}, false)
+	physicsComponent := components.NewPhysicsComponent(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{1, 0.5, 1}, 1, false)
 	game.Engine.EntityStore.AddComponent(entity, physicsComponent)
 
 	game.Player = &Player{
@@ -100,7 +122,7 @@ func main() {
 	// Floor
 	floorEntity := game.Engine.EntityStore.NewPlaneEntity(mgl32.Vec3{0, 0, 0})
 
-	floorPhysicsComponent := components.NewPhysicsComponent(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0.0, 0.0, 0.0}, 1, mgl32.Vec3{25, 0, 25}, true)
+	floorPhysicsComponent := components.NewPhysicsComponent(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0.0, 0.0, 0.0}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{25, 0, 25}, 1, true)
 	game.Engine.EntityStore.AddComponent(*floorEntity, floorPhysicsComponent)
 
 	// LIGHTING
@@ -113,78 +135,92 @@ func main() {
 	// Directional
 	directionalLightEntity := game.Engine.EntityStore.NewEntity()
 	directionalLightComponent := components.NewDirectionalLightComponent(mgl32.Vec3{-0.2, -1.0, -0.3}, mgl32.Vec3{1.0, 1.0, 1.0}, 1)
+	directionalLightComponent.CastShadows = true
 	game.Engine.EntityStore.AddComponent(directionalLightEntity, directionalLightComponent)
 
 	// END LIGHTING
 
-	// Inputs
-	const (
-		CloseApp = iota
+	// Inputs, players can override these in the bindings file
+	actions := game.Engine.InputManager.Actions
+	actions.Bind("close", input.Key(glfw.KeyEscape), input.GamepadButton(glfw.ButtonBack))
+	actions.Bind("forward", input.Key(glfw.KeyW), input.Key(glfw.KeyUp), input.GamepadAxis(glfw.AxisLeftY, -1))
+	actions.Bind("backward", input.Key(glfw.KeyS), input.Key(glfw.KeyDown), input.GamepadAxis(glfw.AxisLeftY, 1))
+	actions.Bind("left", input.Key(glfw.KeyA), input.Key(glfw.KeyLeft), input.GamepadAxis(glfw.AxisLeftX, -1))
+	actions.Bind("right", input.Key(glfw.KeyD), input.Key(glfw.KeyRight), input.GamepadAxis(glfw.AxisLeftX, 1))
+	actions.Bind("jump", input.Key(glfw.KeySpace), input.MouseButton(glfw.MouseButtonRight), input.GamepadButton(glfw.ButtonA))
+	actions.Bind("rebind-jump", input.Key(glfw.KeyF2))
+	actions.Bind("pause", input.Key(glfw.KeyP), input.GamepadButton(glfw.ButtonStart))
+	actions.Bind("resume", input.MouseButton(glfw.MouseButtonLeft))
+	actions.BindAxis2D("move", "forward", "backward", "left", "right")
 
-		MoveForward
-		MoveBackward
-		StrafeRight
-		StrafeLeft
+	const (
+		playerSpeed  = 3
+		jumpSpeed    = 5
+		groundHeight = 1 // The floor has no collider, so keep the player standing on it here
 	)
 
-	currentFrame := glfw.GetTime()
-	deltaTime := currentFrame - game.Engine.LastFrame
-	game.Engine.LastFrame = currentFrame
-	playerSpeed := float32(3 * deltaTime) // Define the player's movement s
-
-	// Key Inputs
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyEscape, CloseApp, func() { game.Engine.Window.GlfwWindow.SetShouldClose(true) })
+	err = game.Engine.RegisterUpdate(func(deltaTime float32) {
+		if actions.JustPressed("close") {
+			game.Engine.Close()
+		}
 
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyW, MoveForward, func() {
-		// Calculate the new forward movement only in the X/Z plane
-		newPosition := game.Player.TransformComponent.Position.Add(mgl32.Vec3{game.Player.CameraComponent.Front.X(), 0, game.Player.CameraComponent.Front.Z()}.Mul(playerSpeed))
-		game.Player.TransformComponent.Position = newPosition
-	})
+		if actions.JustPressed("rebind-jump") {
+			log.Println("Press the key or button to jump with")
+			actions.RebindNext("jump", func(binding input.Binding, conflicts []string) {
+				if len(conflicts) > 0 {
+					log.Printf("%v is also bound to %v", binding, conflicts)
+				}
+				if err := game.Engine.SaveBindings(); err != nil {
+					log.Printf("Error saving bindings: %v", err)
+				}
+			})
+		}
 
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyS, MoveBackward, func() {
-		// Calculate the new backward movement only in the X/Z plane
-		newPosition := game.Player.TransformComponent.Position.Sub(mgl32.Vec3{game.Player.CameraComponent.Front.X(), 0, game.Player.CameraComponent.Front.Z()}.Mul(playerSpeed))
-		game.Player.TransformComponent.Position = newPosition
-	})
+		// Pausing releases the cursor, clicking back into the window resumes
+		if actions.JustPressed("pause") || (game.Paused && actions.JustPressed("resume")) {
+			game.SetPaused(!game.Paused)
+		}
+		if game.Paused {
+			return
+		}
 
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyD, StrafeRight, func() {
-		// Calculate the new right strafe movement only in the X/Z plane
-		newPosition := game.Player.TransformComponent.Position.Add(mgl32.Vec3{game.Player.CameraComponent.Right.X(), 0, game.Player.CameraComponent.Right.Z()}.Mul(playerSpeed))
-		game.Player.TransformComponent.Position = newPosition
-	})
+		// Move only in the X/Z plane
+		move := actions.Axis2D("move").Mul(playerSpeed * deltaTime)
+		front := mgl32.Vec3{game.Player.CameraComponent.Front.X(), 0, game.Player.CameraComponent.Front.Z()}
+		right := mgl32.Vec3{game.Player.CameraComponent.Right.X(), 0, game.Player.CameraComponent.Right.Z()}
+		transform := game.Player.TransformComponent
+		transform.Position = transform.Position.Add(front.Mul(move.Y())).Add(right.Mul(move.X()))
+
+		grounded := transform.Position.Y() <= groundHeight
+		if grounded {
+			transform.Position[1] = groundHeight
+			game.Player.PhysicsComponent.Velocity[1] = 0
+		}
 
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyA, StrafeLeft, func() {
-		// Calculate the new left strafe movement only in the X/Z plane
-		newPosition := game.Player.TransformComponent.Position.Sub(mgl32.Vec3{game.Player.CameraComponent.Right.X(), 0, game.Player.CameraComponent.Right.Z()}.Mul(playerSpeed))
-		game.Player.TransformComponent.Position = newPosition
+		if grounded && actions.JustPressed("jump") {
+			game.Player.PhysicsComponent.Velocity[1] = jumpSpeed
+		}
 	})
+	if err != nil {
+		log.Fatalf("Error registering update: %v", err)
+	}
 
 	// Mouse Inputs
-	game.Engine.InputManager.RegisterMouseMoveHandler(func(xpos, ypos float64) {
-		if game.Engine.InputManager.FirstMouse { // Handle the initial jump in mouse position
-			game.Engine.InputManager.LastX = xpos
-			game.Engine.InputManager.LastY = ypos
-			game.Engine.InputManager.FirstMouse = false
+	err = game.Engine.RegisterUpdate(func(deltaTime float32) {
+		if game.Paused {
+			return
 		}
 
-		xOffset := float32(xpos - game.Engine.InputManager.LastX)
-		yOffset := float32(ypos - game.Engine.InputManager.LastY)
+		mouseDelta := game.Engine.InputManager.MouseDelta()
+		game.Player.Rotate(mouseDelta.X()*0.05, mouseDelta.Y()*0.05)
 
-		game.Player.Rotate(xOffset*0.05, yOffset*0.05)
-		game.Engine.InputManager.LastX = xpos
-		game.Engine.InputManager.LastY = ypos
-	})
-
-	game.Engine.InputManager.RegisterMouseScrollHandler(func(xoffset, yoffset float64) {
-		fov := cameraComp.FieldOfView
-		cameraComp.FieldOfView = fov - float32(yoffset)
-
-		if fov < 1.0 {
-			cameraComp.FieldOfView = 1.0
-		} else if fov > 45.0 {
-			cameraComp.FieldOfView = 45.0
-		}
+		// Zoom, keeping the field of view between 1 and 45 degrees
+		fov := cameraComp.FieldOfView - game.Engine.InputManager.ScrollDelta().Y()
+		cameraComp.FieldOfView = mgl32.Clamp(fov, 1, 45)
 	})
+	if err != nil {
+		log.Fatalf("Error registering update: %v", err)
+	}
 
 	// Loop
 	game.Engine.Run(game.MainLoop)
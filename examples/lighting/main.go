@@ -4,8 +4,10 @@ import (
 	"0xKowalski/game/components"
 	"0xKowalski/game/engine"
 	"0xKowalski/game/entities"
+	"0xKowalski/game/input"
+	"0xKowalski/game/scene"
 	"log"
-	"math"
+	"os"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
@@ -33,7 +35,13 @@ func (g *Game) MainLoop() {
 func main() {
 	game := Game{}
 
-	eng, err := engine.InitEngine()
+	cfg, err := engine.ParseConfig(os.Args[1:])
+	if err != nil {
+		log.Printf("Error reading config: %v", err)
+		panic(err)
+	}
+
+	eng, err := engine.InitEngine(cfg)
 	if err != nil {
 		log.Printf("Error starting engine: %v", err)
 		panic(err)
@@ -45,92 +53,51 @@ func main() {
 	freeCam := game.Engine.EntityStore.NewFreecamEntity(mgl32.Vec3{0, 0, 5})
 	game.Freecam = freeCam
 
-	// Cubes
-	game.Engine.EntityStore.NewCubeEntity(mgl32.Vec3{-1.0, -1.0, -2.0}, 1)
-
-	// LIGHTING
-
-	// Ambient
-	ambientLightEntity := game.Engine.EntityStore.NewEntity()
-	ambientLightComponent := components.NewAmbientLightComponent(mgl32.Vec3{1.0, 1.0, 1.0}, 0.1)
-	game.Engine.EntityStore.AddComponent(ambientLightEntity, ambientLightComponent)
-
-	// Directional
-	directionalLightEntity := game.Engine.EntityStore.NewEntity()
-	directionalLightComponent := components.NewDirectionalLightComponent(mgl32.Vec3{-0.2, -1.0, -0.3}, mgl32.Vec3{1.0, 1.0, 1.0}, 1)
-	game.Engine.EntityStore.AddComponent(directionalLightEntity, directionalLightComponent)
-
-	// Point
-	pointLightEntity := game.Engine.EntityStore.NewEntity()
-	pointLightComponent := components.NewPointLightComponent(mgl32.Vec3{0.0, 0.0, 0.0}, mgl32.Vec3{1.0, 0.8, 0.7}, 1.0, 1.0, 0.09, 0.032)
-	game.Engine.EntityStore.AddComponent(pointLightEntity, pointLightComponent)
-
-	// Spot
-	spotLightEntity := game.Engine.EntityStore.NewEntity()
-	spotLightComponent := components.NewSpotLightComponent(freeCam.TransformComponent.Position, mgl32.Vec3{1.0, 1.0, 1.0}, freeCam.CameraComponent.Front, float32(math.Cos(float64(mgl32.DegToRad(12.5)))), float32(math.Cos(float64(mgl32.DegToRad(17.5)))), 1.0, 1.0, 0.09, 0.032)
-	game.Engine.EntityStore.AddComponent(spotLightEntity, spotLightComponent)
-	game.SpotLightComp = spotLightComponent
-
-	// END LIGHTING
-
-	// Inputs
-	const (
-		CloseApp = iota
-
-		MoveForward
-		MoveBackward
-		StrafeRight
-		StrafeLeft
-	)
-
-	// Key Inputs
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyEscape, CloseApp, func() { game.Engine.Window.GlfwWindow.SetShouldClose(true) })
-
-	currentFrame := glfw.GetTime()
-	deltaTime := currentFrame - game.Engine.LastFrame
-	cameraSpeed := float32(1 * deltaTime)
+	// Cube and lights
+	if _, err := scene.Load(game.Engine.EntityStore, "examples/lighting/scene.json"); err != nil {
+		log.Printf("Error loading scene: %v", err)
+		panic(err)
+	}
 
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyW, MoveForward, func() {
-		freeCam.Move(freeCam.CameraComponent.Front, cameraSpeed)
-	})
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyS, MoveBackward, func() {
-		freeCam.Move(freeCam.CameraComponent.Front.Mul(-1), cameraSpeed)
-	})
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyD, StrafeRight, func() {
-		freeCam.Move(freeCam.CameraComponent.Right, cameraSpeed)
+	// The spot light follows the camera
+	game.SpotLightComp = entities.GetAll[components.SpotLightComponent](game.Engine.EntityStore)[0]
 
-	})
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyA, StrafeLeft, func() {
-		freeCam.Move(freeCam.CameraComponent.Right.Mul(-1), cameraSpeed)
+	// Inputs, players can override these in the bindings file
+	actions := game.Engine.InputManager.Actions
+	actions.Bind("close", input.Key(glfw.KeyEscape))
+	actions.Bind("forward", input.Key(glfw.KeyW))
+	actions.Bind("backward", input.Key(glfw.KeyS))
+	actions.Bind("left", input.Key(glfw.KeyA))
+	actions.Bind("right", input.Key(glfw.KeyD))
+	actions.BindAxis2D("move", "forward", "backward", "left", "right")
 
-	})
+	const cameraSpeed = 5
 
-	// Mouse Inputs
-	game.Engine.InputManager.RegisterMouseMoveHandler(func(xpos, ypos float64) {
-		if game.Engine.InputManager.FirstMouse { // Handle the initial jump in mouse position
-			game.Engine.InputManager.LastX = xpos
-			game.Engine.InputManager.LastY = ypos
-			game.Engine.InputManager.FirstMouse = false
+	err = game.Engine.RegisterUpdate(func(deltaTime float32) {
+		if actions.JustPressed("close") {
+			game.Engine.Close()
 		}
 
-		xOffset := float32(xpos - game.Engine.InputManager.LastX)
-		yOffset := float32(ypos - game.Engine.InputManager.LastY)
-
-		freeCam.Rotate(xOffset*0.05, yOffset*0.05)
-		game.Engine.InputManager.LastX = xpos
-		game.Engine.InputManager.LastY = ypos
+		move := actions.Axis2D("move").Mul(cameraSpeed * deltaTime)
+		freeCam.Move(freeCam.CameraComponent.Front, move.Y())
+		freeCam.Move(freeCam.CameraComponent.Right, move.X())
 	})
+	if err != nil {
+		log.Fatalf("Error registering update: %v", err)
+	}
 
-	game.Engine.InputManager.RegisterMouseScrollHandler(func(xoffset, yoffset float64) {
-		fov := freeCam.CameraComponent.FieldOfView
-		freeCam.CameraComponent.FieldOfView = fov - float32(yoffset)
+	// Mouse Inputs
+	err = game.Engine.RegisterUpdate(func(deltaTime float32) {
+		mouseDelta := game.Engine.InputManager.MouseDelta()
+		freeCam.Rotate(mouseDelta.X()*0.05, mouseDelta.Y()*0.05)
 
-		if fov < 1.0 {
-			freeCam.CameraComponent.FieldOfView = 1.0
-		} else if fov > 45.0 {
-			freeCam.CameraComponent.FieldOfView = 45.0
-		}
+		// Zoom, keeping the field of view between 1 and 45 degrees
+		fov := freeCam.CameraComponent.FieldOfView - game.Engine.InputManager.ScrollDelta().Y()
+		freeCam.CameraComponent.FieldOfView = mgl32.Clamp(fov, 1, 45)
 	})
+	if err != nil {
+		log.Fatalf("Error registering update: %v", err)
+	}
 
 	// Loop
 	game.Engine.Run(game.MainLoop)
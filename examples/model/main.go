@@ -3,7 +3,9 @@ package main
 import (
 	"0xKowalski/game/components"
 	"0xKowalski/game/engine"
+	"0xKowalski/game/input"
 	"log"
+	"os"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
@@ -20,7 +22,13 @@ func (g *Game) MainLoop() {
 func main() {
 	game := Game{}
 
-	eng, err := engine.InitEngine()
+	cfg, err := engine.ParseConfig(os.Args[1:])
+	if err != nil {
+		log.Printf("Error reading config: %v", err)
+		panic(err)
+	}
+
+	eng, err := engine.InitEngine(cfg)
 	if err != nil {
 		log.Printf("Error starting engine: %v", err)
 		panic(err)
@@ -54,64 +62,42 @@ func main() {
 
 	// END LIGHTING
 
-	// Inputs
-	const (
-		CloseApp = iota
-
-		MoveForward
-		MoveBackward
-		StrafeRight
-		StrafeLeft
-	)
-
-	// Key Inputs
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyEscape, CloseApp, func() { game.Engine.Window.GlfwWindow.SetShouldClose(true) })
+	// Inputs, players can override these in the bindings file
+	actions := game.Engine.InputManager.Actions
+	actions.Bind("close", input.Key(glfw.KeyEscape))
+	actions.Bind("forward", input.Key(glfw.KeyW))
+	actions.Bind("backward", input.Key(glfw.KeyS))
+	actions.Bind("left", input.Key(glfw.KeyA))
+	actions.Bind("right", input.Key(glfw.KeyD))
+	actions.BindAxis2D("move", "forward", "backward", "left", "right")
 
-	currentFrame := glfw.GetTime()
-	deltaTime := currentFrame - game.Engine.LastFrame
-	cameraSpeed := float32(1 * deltaTime)
-
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyW, MoveForward, func() {
-		freeCam.Move(freeCam.CameraComponent.Front, cameraSpeed)
-	})
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyS, MoveBackward, func() {
-		freeCam.Move(freeCam.CameraComponent.Front.Mul(-1), cameraSpeed)
-	})
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyD, StrafeRight, func() {
-		freeCam.Move(freeCam.CameraComponent.Right, cameraSpeed)
-
-	})
-	game.Engine.InputManager.RegisterKeyAction(glfw.KeyA, StrafeLeft, func() {
-		freeCam.Move(freeCam.CameraComponent.Right.Mul(-1), cameraSpeed)
+	const cameraSpeed = 5
 
-	})
-
-	// Mouse Inputs
-	game.Engine.InputManager.RegisterMouseMoveHandler(func(xpos, ypos float64) {
-		if game.Engine.InputManager.FirstMouse { // Handle the initial jump in mouse position
-			game.Engine.InputManager.LastX = xpos
-			game.Engine.InputManager.LastY = ypos
-			game.Engine.InputManager.FirstMouse = false
+	err = game.Engine.RegisterUpdate(func(deltaTime float32) {
+		if actions.JustPressed("close") {
+			game.Engine.Close()
 		}
 
-		xOffset := float32(xpos - game.Engine.InputManager.LastX)
-		yOffset := float32(ypos - game.Engine.InputManager.LastY)
-
-		freeCam.Rotate(xOffset*0.05, yOffset*0.05)
-		game.Engine.InputManager.LastX = xpos
-		game.Engine.InputManager.LastY = ypos
+		move := actions.Axis2D("move").Mul(cameraSpeed * deltaTime)
+		freeCam.Move(freeCam.CameraComponent.Front, move.Y())
+		freeCam.Move(freeCam.CameraComponent.Right, move.X())
 	})
+	if err != nil {
+		log.Fatalf("Error registering update: %v", err)
+	}
 
-	game.Engine.InputManager.RegisterMouseScrollHandler(func(xoffset, yoffset float64) {
-		fov := freeCam.CameraComponent.FieldOfView
-		freeCam.CameraComponent.FieldOfView = fov - float32(yoffset)
+	// Mouse Inputs
+	err = game.Engine.RegisterUpdate(func(deltaTime float32) {
+		mouseDelta := game.Engine.InputManager.MouseDelta()
+		freeCam.Rotate(mouseDelta.X()*0.05, mouseDelta.Y()*0.05)
 
-		if fov < 1.0 {
-			freeCam.CameraComponent.FieldOfView = 1.0
-		} else if fov > 45.0 {
-			freeCam.CameraComponent.FieldOfView = 45.0
-		}
+		// Zoom, keeping the field of view between 1 and 45 degrees
+		fov := freeCam.CameraComponent.FieldOfView - game.Engine.InputManager.ScrollDelta().Y()
+		freeCam.CameraComponent.FieldOfView = mgl32.Clamp(fov, 1, 45)
 	})
+	if err != nil {
+		log.Fatalf("Error registering update: %v", err)
+	}
 
 	// Loop
 	game.Engine.Run(game.MainLoop)
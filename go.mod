@@ -9,6 +9,7 @@ require (
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20240307211618-a69d953ea142
 	github.com/go-gl/mathgl v1.1.0
 	github.com/udhos/gwob v1.0.0
+	sigs.k8s.io/yaml v1.4.0
 )
 
 require golang.org/x/image v0.15.0 // indirect
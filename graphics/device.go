@@ -0,0 +1,127 @@
+package graphics
+
+import (
+	"image"
+	"reflect"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Handles to resources created by a Device, zero is never a valid handle
+type (
+	Buffer      uint32
+	VertexArray uint32
+	Texture     uint32
+	Shader      uint32
+	Framebuffer uint32
+)
+
+type BufferTarget int
+
+const (
+	VertexBuffer BufferTarget = iota
+	IndexBuffer
+	UniformBuffer // Backs a uniform block, see BindUniformBuffer
+	TextureBuffer // Read by shaders through CreateBufferTexture
+)
+
+// TexelFormat is how a shader reads a texture buffer's data
+type TexelFormat int
+
+const (
+	RGBA32F  TexelFormat = iota // samplerBuffer, four floats per texel
+	RGBA32UI                    // usamplerBuffer, four uint32s per texel
+	R32UI                       // usamplerBuffer, one uint32 per texel
+)
+
+type Capability int
+
+const (
+	DepthTest Capability = iota
+	Multisample
+	CullFace
+)
+
+// VertexAttribute describes one float attribute of an interleaved vertex,
+// Offset and the layout's Stride are in bytes.
+type VertexAttribute struct {
+	Location   uint32
+	Components int32
+	Offset     int
+}
+
+type VertexLayout struct {
+	Stride     int
+	Attributes []VertexAttribute
+}
+
+// Device is everything the renderer needs from the GPU. GLDevice talks to
+// OpenGL, RecordingDevice keeps everything in memory so rendering can be tested
+// without a context.
+//
+// Buffer data is a slice of fixed size values, e.g []components.Vertex or
+// []uint32. Uniforms are set on the shader in use.
+type Device interface {
+	CreateBuffer(target BufferTarget, data any) Buffer
+	UpdateBuffer(buffer Buffer, data any)
+	DeleteBuffer(buffer Buffer)
+
+	CreateVertexArray(vertices, indices Buffer, layout VertexLayout) VertexArray
+	DeleteVertexArray(vertexArray VertexArray)
+
+	// CreateTexture uploads a repeating, mipmapped texture
+	CreateTexture(img *image.RGBA) Texture
+	// CreateDepthTextureArray creates layers of depth textures, e.g shadow maps.
+	// They are sampled unfiltered and read as the far plane outside the texture.
+	CreateDepthTextureArray(width, height, layers int) Texture
+	// CreateDepthCubeMap creates a cube of depth textures, e.g a point light's
+	// shadow map. Framebuffers draw to its faces as layers, in the order +X, -X,
+	// +Y, -Y, +Z, -Z.
+	CreateDepthCubeMap(size int) Texture
+	// CreateBufferTexture lets shaders fetch a buffer's data as texels. The
+	// texture follows the buffer as it is updated.
+	CreateBufferTexture(buffer Buffer, format TexelFormat) Texture
+	// MaxBufferTextureSize is how many texels a buffer texture can fetch, GL
+	// promises at least 65536.
+	MaxBufferTextureSize() int
+	DeleteTexture(texture Texture)
+	BindTexture(unit int, texture Texture)
+
+	// CreateFramebuffer creates a depth only framebuffer drawing to one layer of
+	// a depth texture array or face of a cube map. Framebuffer 0 is the window.
+	CreateFramebuffer(depth Texture, layer int) Framebuffer
+	DeleteFramebuffer(framebuffer Framebuffer)
+	BindFramebuffer(framebuffer Framebuffer)
+
+	CreateShader(vertexSource, fragmentSource string) (Shader, error)
+	DeleteShader(shader Shader)
+	UseShader(shader Shader)
+
+	// SetUniformBlock points the named uniform block of the shader in use at the
+	// buffer bound to a binding with BindUniformBuffer.
+	SetUniformBlock(name string, binding int) error
+	BindUniformBuffer(binding int, buffer Buffer)
+
+	SetUniformInt(name string, value int32) error
+	SetUniformFloat(name string, value float32) error
+	SetUniformVec3(name string, value mgl32.Vec3) error
+	SetUniformMat4(name string, value mgl32.Mat4) error
+
+	DrawIndexed(vertexArray VertexArray, indexCount int)
+
+	Enable(capability Capability)
+	Disable(capability Capability)
+	SetViewport(x, y, width, height int)
+	Viewport() (x, y, width, height int)
+	SetClearColor(r, g, b, a float32)
+	Clear()
+}
+
+// dataSize is the size in bytes of a slice of fixed size values
+func dataSize(data any) int {
+	value := reflect.ValueOf(data)
+	if value.Kind() != reflect.Slice {
+		return 0
+	}
+	return value.Len() * int(value.Type().Elem().Size())
+}
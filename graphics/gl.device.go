@@ -0,0 +1,417 @@
+package graphics
+
+import (
+	"0xKowalski/game/window"
+	"fmt"
+	"image"
+	"log"
+	"strings"
+
+	"github.com/go-gl/gl/v4.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// GLDevice is the OpenGL Device, it must only be used from the thread that
+// owns the window's context.
+type GLDevice struct {
+	shader   Shader
+	uniforms map[Shader]map[string]int32 // Cached uniform locations
+	targets  map[Texture]uint32          // Texture arrays, cube maps and buffer textures aren't bound to TEXTURE_2D
+	viewport [4]int
+
+	maxBufferTextureSize int
+}
+
+// NewGLDevice makes the window's context current and sets up the default state
+func NewGLDevice(win *window.Window) (*GLDevice, error) {
+	win.GlfwWindow.MakeContextCurrent()
+
+	if err := gl.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize OpenGL: %w", err)
+	}
+
+	if errorCode := gl.GetError(); errorCode != gl.NO_ERROR {
+		return nil, fmt.Errorf("OpenGL error after initialization: %d", errorCode)
+	}
+
+	device := &GLDevice{
+		uniforms: make(map[Shader]map[string]int32),
+		targets:  make(map[Texture]uint32),
+	}
+
+	var maxBufferTextureSize int32
+	gl.GetIntegerv(gl.MAX_TEXTURE_BUFFER_SIZE, &maxBufferTextureSize)
+	device.maxBufferTextureSize = int(maxBufferTextureSize)
+
+	// Viewport follows the window size
+	width, height := win.GetWidthAndHeight()
+	device.SetViewport(0, 0, width, height)
+	win.OnResize(func(width int, height int) {
+		device.SetViewport(0, 0, width, height)
+	})
+
+	device.Enable(DepthTest)
+	if win.WindowConfig.Samples > 0 {
+		device.Enable(Multisample)
+	}
+
+	return device, nil
+}
+
+var bufferTargets = map[BufferTarget]uint32{
+	VertexBuffer:  gl.ARRAY_BUFFER,
+	IndexBuffer:   gl.ELEMENT_ARRAY_BUFFER,
+	UniformBuffer: gl.UNIFORM_BUFFER,
+	TextureBuffer: gl.TEXTURE_BUFFER,
+}
+
+var texelFormats = map[TexelFormat]uint32{
+	RGBA32F:  gl.RGBA32F,
+	RGBA32UI: gl.RGBA32UI,
+	R32UI:    gl.R32UI,
+}
+
+var capabilities = map[Capability]uint32{
+	DepthTest:   gl.DEPTH_TEST,
+	Multisample: gl.MULTISAMPLE,
+	CullFace:    gl.CULL_FACE,
+}
+
+func glPtr(data any) (int, any) {
+	size := dataSize(data)
+	if size == 0 {
+		return 0, nil
+	}
+	return size, data
+}
+
+func (device *GLDevice) CreateBuffer(target BufferTarget, data any) Buffer {
+	var buffer uint32
+	gl.GenBuffers(1, &buffer)
+	gl.BindBuffer(bufferTargets[target], buffer)
+
+	size, ptr := glPtr(data)
+	gl.BufferData(bufferTargets[target], size, gl.Ptr(ptr), gl.STATIC_DRAW)
+
+	return Buffer(buffer)
+}
+
+func (device *GLDevice) UpdateBuffer(buffer Buffer, data any) {
+	size, ptr := glPtr(data)
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, uint32(buffer))
+	gl.BufferData(gl.COPY_WRITE_BUFFER, size, gl.Ptr(ptr), gl.DYNAMIC_DRAW)
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, 0)
+}
+
+func (device *GLDevice) DeleteBuffer(buffer Buffer) {
+	id := uint32(buffer)
+	gl.DeleteBuffers(1, &id)
+}
+
+func (device *GLDevice) CreateVertexArray(vertices, indices Buffer, layout VertexLayout) VertexArray {
+	var vertexArray uint32
+	gl.GenVertexArrays(1, &vertexArray)
+	gl.BindVertexArray(vertexArray)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, uint32(vertices))
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, uint32(indices))
+
+	for _, attribute := range layout.Attributes {
+		gl.VertexAttribPointerWithOffset(attribute.Location, attribute.Components, gl.FLOAT, false, int32(layout.Stride), uintptr(attribute.Offset))
+		gl.EnableVertexAttribArray(attribute.Location)
+	}
+
+	gl.BindVertexArray(0)
+
+	return VertexArray(vertexArray)
+}
+
+func (device *GLDevice) DeleteVertexArray(vertexArray VertexArray) {
+	id := uint32(vertexArray)
+	gl.DeleteVertexArrays(1, &id)
+}
+
+func (device *GLDevice) CreateTexture(img *image.RGBA) Texture {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+
+	width, height := img.Rect.Size().X, img.Rect.Size().Y
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(width), int32(height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+
+	return Texture(texture)
+}
+
+func (device *GLDevice) CreateDepthTextureArray(width, height, layers int) Texture {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, texture)
+
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.DEPTH_COMPONENT24, int32(width), int32(height), int32(layers), 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	borderColor := [4]float32{1, 1, 1, 1}
+	gl.TexParameterfv(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_BORDER_COLOR, &borderColor[0])
+
+	device.targets[Texture(texture)] = gl.TEXTURE_2D_ARRAY
+	return Texture(texture)
+}
+
+func (device *GLDevice) CreateDepthCubeMap(size int) Texture {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, texture)
+
+	for face := uint32(0); face < 6; face++ {
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+face, 0, gl.DEPTH_COMPONENT24, int32(size), int32(size), 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+	}
+
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+
+	device.targets[Texture(texture)] = gl.TEXTURE_CUBE_MAP
+	return Texture(texture)
+}
+
+func (device *GLDevice) CreateBufferTexture(buffer Buffer, format TexelFormat) Texture {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_BUFFER, texture)
+	gl.TexBuffer(gl.TEXTURE_BUFFER, texelFormats[format], uint32(buffer))
+
+	device.targets[Texture(texture)] = gl.TEXTURE_BUFFER
+	return Texture(texture)
+}
+
+func (device *GLDevice) MaxBufferTextureSize() int {
+	return device.maxBufferTextureSize
+}
+
+func (device *GLDevice) DeleteTexture(texture Texture) {
+	id := uint32(texture)
+	gl.DeleteTextures(1, &id)
+	delete(device.targets, texture)
+}
+
+func (device *GLDevice) BindTexture(unit int, texture Texture) {
+	target, ok := device.targets[texture]
+	if !ok {
+		target = gl.TEXTURE_2D
+	}
+
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+	gl.BindTexture(target, uint32(texture))
+}
+
+func (device *GLDevice) CreateFramebuffer(depth Texture, layer int) Framebuffer {
+	var framebuffer uint32
+	gl.GenFramebuffers(1, &framebuffer)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, framebuffer)
+
+	if device.targets[depth] == gl.TEXTURE_CUBE_MAP {
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(layer), uint32(depth), 0)
+	} else {
+		gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, uint32(depth), 0, int32(layer))
+	}
+	// No color is drawn
+	gl.DrawBuffer(gl.NONE)
+	gl.ReadBuffer(gl.NONE)
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		log.Printf("Framebuffer is incomplete: 0x%x", status)
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	return Framebuffer(framebuffer)
+}
+
+func (device *GLDevice) DeleteFramebuffer(framebuffer Framebuffer) {
+	id := uint32(framebuffer)
+	gl.DeleteFramebuffers(1, &id)
+}
+
+func (device *GLDevice) BindFramebuffer(framebuffer Framebuffer) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(framebuffer))
+}
+
+func (device *GLDevice) CreateShader(vertexSource, fragmentSource string) (Shader, error) {
+	vertexShader, err := compileShader(vertexSource+"\x00", gl.VERTEX_SHADER)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compile vertex shader: %w", err)
+	}
+
+	fragmentShader, err := compileShader(fragmentSource+"\x00", gl.FRAGMENT_SHADER)
+	if err != nil {
+		gl.DeleteShader(vertexShader) // Clean up vertex shader if fragment shader fails to compile
+		return 0, fmt.Errorf("failed to compile fragment shader: %w", err)
+	}
+
+	program := gl.CreateProgram()
+	gl.AttachShader(program, vertexShader)
+	gl.AttachShader(program, fragmentShader)
+	gl.LinkProgram(program)
+
+	gl.DeleteShader(vertexShader)   // Don't need the shader after linking
+	gl.DeleteShader(fragmentShader) // Don't need the shader after linking
+
+	// Check for linking errors
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(program)
+		return 0, fmt.Errorf("failed to link program: %s", log)
+	}
+
+	return Shader(program), nil
+}
+
+func compileShader(source string, shaderType uint32) (uint32, error) {
+	shader := gl.CreateShader(shaderType)
+	csource, free := gl.Strs(source)
+	gl.ShaderSource(shader, 1, csource, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+
+		return 0, fmt.Errorf("failed to compile %v: %v", shaderType, log)
+	}
+
+	return shader, nil
+}
+
+func (device *GLDevice) DeleteShader(shader Shader) {
+	gl.DeleteProgram(uint32(shader))
+	delete(device.uniforms, shader)
+}
+
+func (device *GLDevice) UseShader(shader Shader) {
+	device.shader = shader
+	gl.UseProgram(uint32(shader))
+}
+
+func (device *GLDevice) uniformLocation(name string) (int32, error) {
+	locations, ok := device.uniforms[device.shader]
+	if !ok {
+		locations = make(map[string]int32)
+		device.uniforms[device.shader] = locations
+	}
+
+	location, ok := locations[name]
+	if !ok {
+		location = gl.GetUniformLocation(uint32(device.shader), gl.Str(name+"\x00"))
+		locations[name] = location
+	}
+
+	if location == -1 {
+		return -1, fmt.Errorf("Could not find the '%s' uniform location", name)
+	}
+	return location, nil
+}
+
+func (device *GLDevice) SetUniformBlock(name string, binding int) error {
+	index := gl.GetUniformBlockIndex(uint32(device.shader), gl.Str(name+"\x00"))
+	if index == gl.INVALID_INDEX {
+		return fmt.Errorf("Could not find the '%s' uniform block", name)
+	}
+
+	gl.UniformBlockBinding(uint32(device.shader), index, uint32(binding))
+	return nil
+}
+
+func (device *GLDevice) BindUniformBuffer(binding int, buffer Buffer) {
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, uint32(binding), uint32(buffer))
+}
+
+func (device *GLDevice) SetUniformInt(name string, value int32) error {
+	location, err := device.uniformLocation(name)
+	if err != nil {
+		return err
+	}
+	gl.Uniform1i(location, value)
+	return nil
+}
+
+func (device *GLDevice) SetUniformFloat(name string, value float32) error {
+	location, err := device.uniformLocation(name)
+	if err != nil {
+		return err
+	}
+	gl.Uniform1f(location, value)
+	return nil
+}
+
+func (device *GLDevice) SetUniformVec3(name string, value mgl32.Vec3) error {
+	location, err := device.uniformLocation(name)
+	if err != nil {
+		return err
+	}
+	gl.Uniform3f(location, value.X(), value.Y(), value.Z())
+	return nil
+}
+
+func (device *GLDevice) SetUniformMat4(name string, value mgl32.Mat4) error {
+	location, err := device.uniformLocation(name)
+	if err != nil {
+		return err
+	}
+	gl.UniformMatrix4fv(location, 1, false, &value[0])
+	return nil
+}
+
+func (device *GLDevice) DrawIndexed(vertexArray VertexArray, indexCount int) {
+	gl.BindVertexArray(uint32(vertexArray))
+	gl.DrawElements(gl.TRIANGLES, int32(indexCount), gl.UNSIGNED_INT, gl.Ptr(nil))
+	gl.BindVertexArray(0)
+}
+
+func (device *GLDevice) Enable(capability Capability) {
+	gl.Enable(capabilities[capability])
+}
+
+func (device *GLDevice) Disable(capability Capability) {
+	gl.Disable(capabilities[capability])
+}
+
+func (device *GLDevice) SetViewport(x, y, width, height int) {
+	device.viewport = [4]int{x, y, width, height}
+	gl.Viewport(int32(x), int32(y), int32(width), int32(height))
+}
+
+func (device *GLDevice) Viewport() (x, y, width, height int) {
+	return device.viewport[0], device.viewport[1], device.viewport[2], device.viewport[3]
+}
+
+func (device *GLDevice) SetClearColor(r, g, b, a float32) {
+	gl.ClearColor(r, g, b, a)
+}
+
+func (device *GLDevice) Clear() {
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+}
@@ -0,0 +1,86 @@
+package graphics
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+)
+
+// ImageDiff is how far an image is from the one it was compared against
+type ImageDiff struct {
+	Pixels    int
+	Differing int   // Pixels with a channel further apart than the tolerance
+	MaxDelta  uint8 // Largest difference of any channel
+}
+
+// Fraction of pixels that differ
+func (diff ImageDiff) Fraction() float64 {
+	if diff.Pixels == 0 {
+		return 0
+	}
+	return float64(diff.Differing) / float64(diff.Pixels)
+}
+
+// CompareImages compares two images of the same size channel by channel,
+// allowing each channel to be off by up to tolerance.
+func CompareImages(got, want image.Image, tolerance uint8) (ImageDiff, error) {
+	if got.Bounds().Size() != want.Bounds().Size() {
+		return ImageDiff{}, fmt.Errorf("image size %v does not match %v", got.Bounds().Size(), want.Bounds().Size())
+	}
+
+	diff := ImageDiff{Pixels: got.Bounds().Dx() * got.Bounds().Dy()}
+	gotMin, wantMin := got.Bounds().Min, want.Bounds().Min
+	for y := 0; y < got.Bounds().Dy(); y++ {
+		for x := 0; x < got.Bounds().Dx(); x++ {
+			r1, g1, b1, a1 := got.At(gotMin.X+x, gotMin.Y+y).RGBA()
+			r2, g2, b2, a2 := want.At(wantMin.X+x, wantMin.Y+y).RGBA()
+
+			differs := false
+			for _, channels := range [4][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}, {a1, a2}} {
+				delta := channelDelta(channels[0], channels[1])
+				diff.MaxDelta = max(diff.MaxDelta, delta)
+				if delta > tolerance {
+					differs = true
+				}
+			}
+			if differs {
+				diff.Differing++
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// channelDelta is the difference of two 16 bit channels in 8 bit steps
+func channelDelta(a, b uint32) uint8 {
+	a, b = a>>8, b>>8
+	if a > b {
+		return uint8(a - b)
+	}
+	return uint8(b - a)
+}
+
+func LoadPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+func SavePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
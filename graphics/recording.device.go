@@ -0,0 +1,415 @@
+package graphics
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// RecordingDevice is a Device that keeps every call, resource and draw in
+// memory instead of talking to a GPU, so the render system can be tested
+// without a window.
+type RecordingDevice struct {
+	Calls  []Call
+	Draws  []DrawCall
+	Errors []error // Misuse such as drawing with a deleted vertex array
+
+	Buffers      map[Buffer]RecordedBuffer
+	VertexArrays map[VertexArray]RecordedVertexArray
+	Textures     map[Texture]RecordedTexture
+	Shaders      map[Shader]RecordedShader
+	Framebuffers map[Framebuffer]RecordedFramebuffer
+
+	Shader         Shader
+	Framebuffer    Framebuffer
+	BoundTextures  map[int]Texture
+	UniformBuffers map[int]Buffer // By binding
+	Capabilities   map[Capability]bool
+	ClearColor     [4]float32
+
+	BufferTextureSize int // Texels a buffer texture can fetch, GL's minimum by default
+
+	viewport      [4]int
+	uniforms      map[Shader]map[string]any
+	uniformBlocks map[Shader]map[string]int
+	next          uint32
+}
+
+type Call struct {
+	Name string
+	Args []any
+}
+
+func (call Call) String() string {
+	return fmt.Sprintf("%s%v", call.Name, call.Args)
+}
+
+// DrawCall is the state a draw was issued with
+type DrawCall struct {
+	Framebuffer    Framebuffer
+	Shader         Shader
+	VertexArray    VertexArray
+	IndexCount     int
+	Textures       map[int]Texture
+	Uniforms       map[string]any
+	UniformBuffers map[int]Buffer
+}
+
+type RecordedBuffer struct {
+	Target BufferTarget
+	Size   int
+}
+
+type RecordedVertexArray struct {
+	Vertices Buffer
+	Indices  Buffer
+	Layout   VertexLayout
+}
+
+type RecordedTexture struct {
+	Width  int
+	Height int
+	Layers int  // Depth texture arrays and cube maps only
+	Cube   bool // Layers are the faces of a cube map
+
+	// Buffer textures only
+	Buffer Buffer
+	Format TexelFormat
+}
+
+type RecordedFramebuffer struct {
+	Depth Texture
+	Layer int
+}
+
+type RecordedShader struct {
+	VertexSource   string
+	FragmentSource string
+}
+
+func NewRecordingDevice() *RecordingDevice {
+	return &RecordingDevice{
+		Buffers:        make(map[Buffer]RecordedBuffer),
+		VertexArrays:   make(map[VertexArray]RecordedVertexArray),
+		Textures:       make(map[Texture]RecordedTexture),
+		Shaders:        make(map[Shader]RecordedShader),
+		Framebuffers:   make(map[Framebuffer]RecordedFramebuffer),
+		BoundTextures:  make(map[int]Texture),
+		UniformBuffers: make(map[int]Buffer),
+		Capabilities:   make(map[Capability]bool),
+		uniforms:       make(map[Shader]map[string]any),
+		uniformBlocks:  make(map[Shader]map[string]int),
+
+		BufferTextureSize: 65536,
+	}
+}
+
+// Reset forgets recorded calls, draws and errors but keeps resources and state
+func (device *RecordingDevice) Reset() {
+	device.Calls = nil
+	device.Draws = nil
+	device.Errors = nil
+}
+
+// Live is the number of resources created and not yet deleted
+func (device *RecordingDevice) Live() int {
+	return len(device.Buffers) + len(device.VertexArrays) + len(device.Textures) + len(device.Shaders) + len(device.Framebuffers)
+}
+
+// UniformBlock is the binding a shader's uniform block was pointed at
+func (device *RecordingDevice) UniformBlock(shader Shader, name string) (int, bool) {
+	binding, ok := device.uniformBlocks[shader][name]
+	return binding, ok
+}
+
+// Uniform is the last value set for a uniform on a shader
+func (device *RecordingDevice) Uniform(shader Shader, name string) (any, bool) {
+	value, ok := device.uniforms[shader][name]
+	return value, ok
+}
+
+func (device *RecordingDevice) record(name string, args ...any) {
+	device.Calls = append(device.Calls, Call{Name: name, Args: args})
+}
+
+func (device *RecordingDevice) errorf(format string, args ...any) {
+	device.Errors = append(device.Errors, fmt.Errorf(format, args...))
+}
+
+func (device *RecordingDevice) handle() uint32 {
+	device.next++
+	return device.next
+}
+
+func (device *RecordingDevice) CreateBuffer(target BufferTarget, data any) Buffer {
+	buffer := Buffer(device.handle())
+	device.Buffers[buffer] = RecordedBuffer{Target: target, Size: dataSize(data)}
+	device.record("CreateBuffer", target, buffer)
+	return buffer
+}
+
+func (device *RecordingDevice) UpdateBuffer(buffer Buffer, data any) {
+	recorded, ok := device.Buffers[buffer]
+	if !ok {
+		device.errorf("UpdateBuffer: unknown buffer %d", buffer)
+		return
+	}
+	recorded.Size = dataSize(data)
+	device.Buffers[buffer] = recorded
+	device.record("UpdateBuffer", buffer)
+}
+
+func (device *RecordingDevice) DeleteBuffer(buffer Buffer) {
+	if _, ok := device.Buffers[buffer]; !ok {
+		device.errorf("DeleteBuffer: unknown buffer %d", buffer)
+	}
+	delete(device.Buffers, buffer)
+	device.record("DeleteBuffer", buffer)
+}
+
+func (device *RecordingDevice) CreateVertexArray(vertices, indices Buffer, layout VertexLayout) VertexArray {
+	if _, ok := device.Buffers[vertices]; !ok {
+		device.errorf("CreateVertexArray: unknown vertex buffer %d", vertices)
+	}
+	if _, ok := device.Buffers[indices]; !ok {
+		device.errorf("CreateVertexArray: unknown index buffer %d", indices)
+	}
+
+	vertexArray := VertexArray(device.handle())
+	device.VertexArrays[vertexArray] = RecordedVertexArray{Vertices: vertices, Indices: indices, Layout: layout}
+	device.record("CreateVertexArray", vertexArray)
+	return vertexArray
+}
+
+func (device *RecordingDevice) DeleteVertexArray(vertexArray VertexArray) {
+	if _, ok := device.VertexArrays[vertexArray]; !ok {
+		device.errorf("DeleteVertexArray: unknown vertex array %d", vertexArray)
+	}
+	delete(device.VertexArrays, vertexArray)
+	device.record("DeleteVertexArray", vertexArray)
+}
+
+func (device *RecordingDevice) CreateTexture(img *image.RGBA) Texture {
+	texture := Texture(device.handle())
+	size := img.Rect.Size()
+	device.Textures[texture] = RecordedTexture{Width: size.X, Height: size.Y}
+	device.record("CreateTexture", texture)
+	return texture
+}
+
+func (device *RecordingDevice) CreateDepthTextureArray(width, height, layers int) Texture {
+	texture := Texture(device.handle())
+	device.Textures[texture] = RecordedTexture{Width: width, Height: height, Layers: layers}
+	device.record("CreateDepthTextureArray", texture, width, height, layers)
+	return texture
+}
+
+func (device *RecordingDevice) CreateDepthCubeMap(size int) Texture {
+	texture := Texture(device.handle())
+	device.Textures[texture] = RecordedTexture{Width: size, Height: size, Layers: 6, Cube: true}
+	device.record("CreateDepthCubeMap", texture, size)
+	return texture
+}
+
+func (device *RecordingDevice) CreateBufferTexture(buffer Buffer, format TexelFormat) Texture {
+	if recorded, ok := device.Buffers[buffer]; !ok || recorded.Target != TextureBuffer {
+		device.errorf("CreateBufferTexture: %d is not a texture buffer", buffer)
+	}
+
+	texture := Texture(device.handle())
+	device.Textures[texture] = RecordedTexture{Buffer: buffer, Format: format}
+	device.record("CreateBufferTexture", texture, buffer, format)
+	return texture
+}
+
+func (device *RecordingDevice) MaxBufferTextureSize() int {
+	return device.BufferTextureSize
+}
+
+// Bytes per texel of each format
+var texelSizes = map[TexelFormat]int{
+	RGBA32F:  16,
+	RGBA32UI: 16,
+	R32UI:    4,
+}
+
+func (device *RecordingDevice) DeleteTexture(texture Texture) {
+	if _, ok := device.Textures[texture]; !ok {
+		device.errorf("DeleteTexture: unknown texture %d", texture)
+	}
+	delete(device.Textures, texture)
+	device.record("DeleteTexture", texture)
+}
+
+func (device *RecordingDevice) BindTexture(unit int, texture Texture) {
+	if _, ok := device.Textures[texture]; !ok && texture != 0 {
+		device.errorf("BindTexture: unknown texture %d", texture)
+	}
+	device.BoundTextures[unit] = texture
+	device.record("BindTexture", unit, texture)
+}
+
+func (device *RecordingDevice) CreateFramebuffer(depth Texture, layer int) Framebuffer {
+	texture, ok := device.Textures[depth]
+	if !ok || layer < 0 || layer >= texture.Layers {
+		device.errorf("CreateFramebuffer: no layer %d in depth texture %d", layer, depth)
+	}
+
+	framebuffer := Framebuffer(device.handle())
+	device.Framebuffers[framebuffer] = RecordedFramebuffer{Depth: depth, Layer: layer}
+	device.record("CreateFramebuffer", framebuffer, depth, layer)
+	return framebuffer
+}
+
+func (device *RecordingDevice) DeleteFramebuffer(framebuffer Framebuffer) {
+	if _, ok := device.Framebuffers[framebuffer]; !ok {
+		device.errorf("DeleteFramebuffer: unknown framebuffer %d", framebuffer)
+	}
+	delete(device.Framebuffers, framebuffer)
+	device.record("DeleteFramebuffer", framebuffer)
+}
+
+func (device *RecordingDevice) BindFramebuffer(framebuffer Framebuffer) {
+	if _, ok := device.Framebuffers[framebuffer]; !ok && framebuffer != 0 {
+		device.errorf("BindFramebuffer: unknown framebuffer %d", framebuffer)
+	}
+	device.Framebuffer = framebuffer
+	device.record("BindFramebuffer", framebuffer)
+}
+
+func (device *RecordingDevice) CreateShader(vertexSource, fragmentSource string) (Shader, error) {
+	shader := Shader(device.handle())
+	device.Shaders[shader] = RecordedShader{VertexSource: vertexSource, FragmentSource: fragmentSource}
+	device.uniforms[shader] = make(map[string]any)
+	device.uniformBlocks[shader] = make(map[string]int)
+	device.record("CreateShader", shader)
+	return shader, nil
+}
+
+func (device *RecordingDevice) DeleteShader(shader Shader) {
+	if _, ok := device.Shaders[shader]; !ok {
+		device.errorf("DeleteShader: unknown shader %d", shader)
+	}
+	delete(device.Shaders, shader)
+	delete(device.uniforms, shader)
+	delete(device.uniformBlocks, shader)
+	device.record("DeleteShader", shader)
+}
+
+func (device *RecordingDevice) UseShader(shader Shader) {
+	if _, ok := device.Shaders[shader]; !ok {
+		device.errorf("UseShader: unknown shader %d", shader)
+	}
+	device.Shader = shader
+	device.record("UseShader", shader)
+}
+
+func (device *RecordingDevice) setUniform(name string, value any) error {
+	uniforms, ok := device.uniforms[device.Shader]
+	if !ok {
+		return fmt.Errorf("Could not set the '%s' uniform, no shader in use", name)
+	}
+	uniforms[name] = value
+	device.record("SetUniform", name, value)
+	return nil
+}
+
+func (device *RecordingDevice) SetUniformBlock(name string, binding int) error {
+	blocks, ok := device.uniformBlocks[device.Shader]
+	if !ok {
+		return fmt.Errorf("Could not set the '%s' uniform block, no shader in use", name)
+	}
+	blocks[name] = binding
+	device.record("SetUniformBlock", name, binding)
+	return nil
+}
+
+func (device *RecordingDevice) BindUniformBuffer(binding int, buffer Buffer) {
+	if recorded, ok := device.Buffers[buffer]; !ok || recorded.Target != UniformBuffer {
+		device.errorf("BindUniformBuffer: %d is not a uniform buffer", buffer)
+	}
+	device.UniformBuffers[binding] = buffer
+	device.record("BindUniformBuffer", binding, buffer)
+}
+
+func (device *RecordingDevice) SetUniformInt(name string, value int32) error {
+	return device.setUniform(name, value)
+}
+
+func (device *RecordingDevice) SetUniformFloat(name string, value float32) error {
+	return device.setUniform(name, value)
+}
+
+func (device *RecordingDevice) SetUniformVec3(name string, value mgl32.Vec3) error {
+	return device.setUniform(name, value)
+}
+
+func (device *RecordingDevice) SetUniformMat4(name string, value mgl32.Mat4) error {
+	return device.setUniform(name, value)
+}
+
+func (device *RecordingDevice) DrawIndexed(vertexArray VertexArray, indexCount int) {
+	if _, ok := device.VertexArrays[vertexArray]; !ok {
+		device.errorf("DrawIndexed: unknown vertex array %d", vertexArray)
+	}
+	if _, ok := device.Shaders[device.Shader]; !ok {
+		device.errorf("DrawIndexed: no shader in use")
+	}
+
+	draw := DrawCall{
+		Framebuffer:    device.Framebuffer,
+		Shader:         device.Shader,
+		VertexArray:    vertexArray,
+		IndexCount:     indexCount,
+		Textures:       make(map[int]Texture, len(device.BoundTextures)),
+		Uniforms:       make(map[string]any, len(device.uniforms[device.Shader])),
+		UniformBuffers: make(map[int]Buffer, len(device.UniformBuffers)),
+	}
+	for unit, texture := range device.BoundTextures {
+		draw.Textures[unit] = texture
+
+		// Shaders can't fetch past the end of a buffer texture
+		recorded := device.Textures[texture]
+		if buffer, ok := device.Buffers[recorded.Buffer]; ok && recorded.Buffer != 0 && buffer.Size/texelSizes[recorded.Format] > device.BufferTextureSize {
+			device.errorf("DrawIndexed: buffer texture %d holds %d texels, more than %d", texture, buffer.Size/texelSizes[recorded.Format], device.BufferTextureSize)
+		}
+	}
+	for binding, buffer := range device.UniformBuffers {
+		draw.UniformBuffers[binding] = buffer
+	}
+	for name, value := range device.uniforms[device.Shader] {
+		draw.Uniforms[name] = value
+	}
+
+	device.Draws = append(device.Draws, draw)
+	device.record("DrawIndexed", vertexArray, indexCount)
+}
+
+func (device *RecordingDevice) Enable(capability Capability) {
+	device.Capabilities[capability] = true
+	device.record("Enable", capability)
+}
+
+func (device *RecordingDevice) Disable(capability Capability) {
+	device.Capabilities[capability] = false
+	device.record("Disable", capability)
+}
+
+func (device *RecordingDevice) SetViewport(x, y, width, height int) {
+	device.viewport = [4]int{x, y, width, height}
+	device.record("SetViewport", x, y, width, height)
+}
+
+func (device *RecordingDevice) Viewport() (x, y, width, height int) {
+	return device.viewport[0], device.viewport[1], device.viewport[2], device.viewport[3]
+}
+
+func (device *RecordingDevice) SetClearColor(r, g, b, a float32) {
+	device.ClearColor = [4]float32{r, g, b, a}
+	device.record("SetClearColor", r, g, b, a)
+}
+
+func (device *RecordingDevice) Clear() {
+	device.record("Clear")
+}
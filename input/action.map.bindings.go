@@ -0,0 +1,38 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadBindings overrides actions' bindings with the ones in a JSON file of
+// action names to bindings, e.g {"jump": ["Space", "GamepadA"]}. Actions the
+// file doesn't mention keep the bindings the game set up.
+func (am *ActionMap) LoadBindings(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var bindings map[string][]Binding
+	if err := json.Unmarshal(data, &bindings); err != nil {
+		return fmt.Errorf("error parsing bindings %s: %w", path, err)
+	}
+
+	for action, actionBindings := range bindings {
+		am.SetBindings(action, actionBindings...)
+	}
+
+	return nil
+}
+
+// SaveBindings writes every action's bindings to path
+func (am *ActionMap) SaveBindings(path string) error {
+	data, err := json.MarshalIndent(am.bindings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
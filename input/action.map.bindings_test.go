@@ -0,0 +1,183 @@
+package input
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+func TestBindingNames(t *testing.T) {
+	cases := []struct {
+		binding Binding
+		name    string
+	}{
+		{Key(glfw.KeyW), "W"},
+		{Key(glfw.KeySpace), "Space"},
+		{MouseButton(glfw.MouseButtonLeft), "MouseLeft"},
+		{GamepadButton(glfw.ButtonA), "GamepadA"},
+		{GamepadAxis(glfw.AxisLeftY, -1), "GamepadLeftY-"},
+		{GamepadAxis(glfw.AxisLeftTrigger, 1), "GamepadLeftTrigger+"},
+		{Binding{Device: Keyboard, Code: 9999}, "Keyboard:9999"},
+	}
+	for _, c := range cases {
+		if name := c.binding.String(); name != c.name {
+			t.Errorf("%+v is named %q, want %q", c.binding, name, c.name)
+		}
+
+		parsed, err := ParseBinding(c.name)
+		if err != nil {
+			t.Errorf("parsing %q: %v", c.name, err)
+		} else if parsed != c.binding {
+			t.Errorf("%q parsed as %+v, want %+v", c.name, parsed, c.binding)
+		}
+	}
+
+	for _, invalid := range []string{"", "NotAKey", "GamepadLeftY", "Keyboard:W", "Joystick:1"} {
+		if _, err := ParseBinding(invalid); err == nil {
+			t.Errorf("parsed %q", invalid)
+		}
+	}
+}
+
+func TestBindingConflicts(t *testing.T) {
+	actions := NewActionMap()
+	actions.Bind("jump", Key(glfw.KeySpace), GamepadButton(glfw.ButtonA))
+	actions.Bind("confirm", Key(glfw.KeyEnter), GamepadButton(glfw.ButtonA))
+	actions.Bind("interact", Key(glfw.KeyE))
+
+	if bound := actions.ActionsBoundTo(GamepadButton(glfw.ButtonA)); !reflect.DeepEqual(bound, []string{"confirm", "jump"}) {
+		t.Errorf("GamepadA is bound to %q", bound)
+	}
+
+	want := []Conflict{{Binding: GamepadButton(glfw.ButtonA), Actions: []string{"confirm", "jump"}}}
+	if conflicts := actions.Conflicts(); !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("conflicts %+v, want %+v", conflicts, want)
+	}
+
+	actions.SetBindings("confirm", Key(glfw.KeyEnter))
+	if conflicts := actions.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("conflicts %+v after rebinding", conflicts)
+	}
+}
+
+func TestRebindNextKey(t *testing.T) {
+	im, fake := newFakeInputManager()
+	actions := im.Actions
+	actions.Bind("jump", Key(glfw.KeySpace))
+	actions.Bind("interact", Key(glfw.KeyE))
+
+	var rebound Binding
+	var conflicts []string
+	actions.RebindNext("jump", func(binding Binding, conflicting []string) {
+		rebound, conflicts = binding, conflicting
+	})
+	if !actions.Listening() {
+		t.Fatal("not listening after RebindNext")
+	}
+
+	// The captured key doesn't trigger the action it's shared with
+	fake.PressKey(glfw.KeyE)
+	im.Update()
+	if rebound != Key(glfw.KeyE) || !reflect.DeepEqual(conflicts, []string{"interact"}) {
+		t.Errorf("rebound to %v with conflicts %q", rebound, conflicts)
+	}
+	if actions.Listening() {
+		t.Error("still listening after capturing a key")
+	}
+	if bindings := actions.Bindings("jump"); !reflect.DeepEqual(bindings, []Binding{Key(glfw.KeyE)}) {
+		t.Errorf("jump is bound to %v", bindings)
+	}
+	checkAction(t, actions, "jump", actionFrame{})
+	checkAction(t, actions, "interact", actionFrame{})
+
+	// Nor does releasing it, the next press works as normal
+	fake.ReleaseKey(glfw.KeyE)
+	im.Update()
+	checkAction(t, actions, "jump", actionFrame{})
+
+	fake.PressKey(glfw.KeyE)
+	im.Update()
+	checkAction(t, actions, "jump", actionFrame{justPressed: true, held: true})
+	checkAction(t, actions, "interact", actionFrame{justPressed: true, held: true})
+}
+
+func TestRebindNextGamepadButton(t *testing.T) {
+	im, fake := newFakeInputManager()
+	fake.ConnectGamepad(glfw.Joystick1, "Pad")
+	actions := im.Actions
+	actions.Bind("jump", Key(glfw.KeySpace))
+
+	// Held before listening started, so it's not what gets captured
+	fake.SetGamepadButton(glfw.Joystick1, glfw.ButtonB, true)
+	im.Update()
+
+	var rebound []Binding
+	actions.RebindNext("jump", func(binding Binding, conflicts []string) {
+		rebound = append(rebound, binding)
+	})
+
+	im.Update()
+	fake.SetGamepadButton(glfw.Joystick1, glfw.ButtonA, true)
+	im.Update()
+	if !reflect.DeepEqual(rebound, []Binding{GamepadButton(glfw.ButtonA)}) {
+		t.Fatalf("rebound to %v", rebound)
+	}
+	checkAction(t, actions, "jump", actionFrame{})
+
+	// Suppressed until released
+	im.Update()
+	checkAction(t, actions, "jump", actionFrame{})
+	fake.SetGamepadButton(glfw.Joystick1, glfw.ButtonA, false)
+	im.Update()
+	fake.SetGamepadButton(glfw.Joystick1, glfw.ButtonA, true)
+	im.Update()
+	checkAction(t, actions, "jump", actionFrame{justPressed: true, held: true})
+}
+
+func TestCancelListening(t *testing.T) {
+	im, fake := newFakeInputManager()
+	actions := im.Actions
+	actions.Bind("jump", Key(glfw.KeySpace))
+
+	actions.RebindNext("jump", func(binding Binding, conflicts []string) {
+		t.Errorf("rebound to %v after cancelling", binding)
+	})
+	actions.CancelListening()
+
+	fake.PressKey(glfw.KeySpace)
+	im.Update()
+	checkAction(t, actions, "jump", actionFrame{justPressed: true, held: true})
+}
+
+func TestSaveAndLoadBindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bindings.json")
+
+	saved := NewActionMap()
+	saved.Bind("jump", Key(glfw.KeySpace), GamepadButton(glfw.ButtonA))
+	saved.Bind("forward", Key(glfw.KeyUp), GamepadAxis(glfw.AxisLeftY, -1))
+	if err := saved.SaveBindings(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewActionMap()
+	loaded.Bind("jump", Key(glfw.KeyJ))
+	loaded.Bind("crouch", Key(glfw.KeyC)) // Not in the file, so kept
+	if err := loaded.LoadBindings(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range saved.Actions() {
+		if got, want := loaded.Bindings(action), saved.Bindings(action); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s loaded as %v, want %v", action, got, want)
+		}
+	}
+	if got := loaded.Bindings("crouch"); !reflect.DeepEqual(got, []Binding{Key(glfw.KeyC)}) {
+		t.Errorf("crouch is %v after loading", got)
+	}
+
+	if err := loaded.LoadBindings(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loaded a missing file")
+	}
+}
@@ -0,0 +1,410 @@
+package input
+
+import (
+	"sort"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type Device int
+
+const (
+	Keyboard Device = iota
+	Mouse
+	GamepadButtons
+	GamepadAxes
+)
+
+// AnyGamepad lets an action map read every connected gamepad
+const AnyGamepad glfw.Joystick = -1
+
+// DefaultPressPoint is how far an analog input has to move to count as held
+const DefaultPressPoint float32 = 0.5
+
+// Binding is a single physical input an action can be triggered by. Direction
+// picks which half of a gamepad axis the binding reads, 1 or -1.
+type Binding struct {
+	Device    Device
+	Code      int
+	Direction int `json:",omitempty"`
+}
+
+func Key(key glfw.Key) Binding {
+	return Binding{Device: Keyboard, Code: int(key)}
+}
+
+func MouseButton(button glfw.MouseButton) Binding {
+	return Binding{Device: Mouse, Code: int(button)}
+}
+
+func GamepadButton(button glfw.GamepadButton) Binding {
+	return Binding{Device: GamepadButtons, Code: int(button)}
+}
+
+// GamepadAxis binds one direction of an axis, e.g the left stick pushed up is
+// GamepadAxis(glfw.AxisLeftY, -1)
+func GamepadAxis(axis glfw.GamepadAxis, direction int) Binding {
+	if direction < 0 {
+		direction = -1
+	} else {
+		direction = 1
+	}
+	return Binding{Device: GamepadAxes, Code: int(axis), Direction: direction}
+}
+
+type actionState struct {
+	value        float32
+	held         bool
+	justPressed  bool
+	justReleased bool
+}
+
+// axis2D builds a vector out of four actions, e.g WASD
+type axis2D struct {
+	up, down, left, right string
+}
+
+// ActionMap maps named actions to any number of bindings. Raw events update the
+// bindings as they arrive and the InputManager turns them into per frame action
+// state, so JustPressed and JustReleased are true for exactly one frame.
+//
+// Analog bindings give actions a value between 0 and 1, digital ones are 0 or
+// 1. An action is held while its value is at least PressPoint.
+type ActionMap struct {
+	Gamepad    glfw.Joystick // The gamepad this map reads, or AnyGamepad
+	PressPoint float32
+
+	bindings map[string][]Binding
+	axes     map[string]axis2D
+	states   map[string]*actionState
+
+	down     map[Binding]bool
+	pressed  map[Binding]bool // Pressed since the last Update, even if already released
+	released map[Binding]bool
+
+	// Set while waiting for the next input to rebind an action with, the input
+	// it catches is kept from triggering actions until it's released.
+	listener   func(binding Binding)
+	listenBase map[Binding]bool // Gamepad inputs already active when listening started, nil until polled
+	suppressed map[Binding]bool
+}
+
+func NewActionMap() *ActionMap {
+	return &ActionMap{
+		Gamepad:    AnyGamepad,
+		PressPoint: DefaultPressPoint,
+		bindings:   make(map[string][]Binding),
+		axes:       make(map[string]axis2D),
+		states:     make(map[string]*actionState),
+		down:       make(map[Binding]bool),
+		pressed:    make(map[Binding]bool),
+		released:   make(map[Binding]bool),
+		suppressed: make(map[Binding]bool),
+	}
+}
+
+// Bind adds bindings to an action, creating it if needed
+func (am *ActionMap) Bind(action string, bindings ...Binding) {
+	am.bindings[action] = append(am.bindings[action], bindings...)
+	if _, ok := am.states[action]; !ok {
+		am.states[action] = &actionState{}
+	}
+}
+
+// Unbind removes every binding from an action
+func (am *ActionMap) Unbind(action string) {
+	delete(am.bindings, action)
+}
+
+// SetBindings replaces an action's bindings
+func (am *ActionMap) SetBindings(action string, bindings ...Binding) {
+	am.bindings[action] = nil
+	am.Bind(action, bindings...)
+}
+
+func (am *ActionMap) Bindings(action string) []Binding {
+	return am.bindings[action]
+}
+
+// Actions returns the name of every action with bindings, sorted
+func (am *ActionMap) Actions() []string {
+	actions := make([]string, 0, len(am.bindings))
+	for action := range am.bindings {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// ActionsBoundTo returns the actions a binding triggers, sorted
+func (am *ActionMap) ActionsBoundTo(binding Binding) []string {
+	var actions []string
+	for _, action := range am.Actions() {
+		for _, bound := range am.bindings[action] {
+			if bound == binding {
+				actions = append(actions, action)
+				break
+			}
+		}
+	}
+	return actions
+}
+
+// Conflict is a binding shared by more than one action
+type Conflict struct {
+	Binding Binding
+	Actions []string
+}
+
+// Conflicts lists every binding used by more than one action
+func (am *ActionMap) Conflicts() []Conflict {
+	var bindings []Binding
+	seen := make(map[Binding]bool)
+	for _, action := range am.Actions() {
+		for _, binding := range am.bindings[action] {
+			if !seen[binding] {
+				seen[binding] = true
+				bindings = append(bindings, binding)
+			}
+		}
+	}
+
+	var conflicts []Conflict
+	for _, binding := range bindings {
+		if actions := am.ActionsBoundTo(binding); len(actions) > 1 {
+			conflicts = append(conflicts, Conflict{Binding: binding, Actions: actions})
+		}
+	}
+	return conflicts
+}
+
+// ListenForInput calls callback once with the next key, mouse button, gamepad
+// button or gamepad axis pressed, instead of it triggering any actions. Gamepad
+// inputs already held when listening starts are ignored until released.
+func (am *ActionMap) ListenForInput(callback func(binding Binding)) {
+	am.listener = callback
+	am.listenBase = nil
+}
+
+func (am *ActionMap) CancelListening() {
+	am.listener = nil
+	am.listenBase = nil
+}
+
+func (am *ActionMap) Listening() bool {
+	return am.listener != nil
+}
+
+// RebindNext replaces an action's bindings with the next input pressed.
+// onRebind gets the new binding and any other actions already using it.
+func (am *ActionMap) RebindNext(action string, onRebind func(binding Binding, conflicts []string)) {
+	am.ListenForInput(func(binding Binding) {
+		conflicts := am.ActionsBoundTo(binding)
+		am.SetBindings(action, binding)
+
+		if onRebind != nil {
+			onRebind(binding, conflicts)
+		}
+	})
+}
+
+// BindAxis2D creates a composite axis out of four actions. Its x is right minus
+// left and its y is up minus down.
+func (am *ActionMap) BindAxis2D(name, up, down, left, right string) {
+	am.axes[name] = axis2D{up: up, down: down, left: left, right: right}
+}
+
+// Value is how far the action is pressed, from 0 to 1
+func (am *ActionMap) Value(action string) float32 {
+	state, ok := am.states[action]
+	if !ok {
+		return 0
+	}
+	return state.value
+}
+
+func (am *ActionMap) Held(action string) bool {
+	state, ok := am.states[action]
+	return ok && state.held
+}
+
+func (am *ActionMap) JustPressed(action string) bool {
+	state, ok := am.states[action]
+	return ok && state.justPressed
+}
+
+func (am *ActionMap) JustReleased(action string) bool {
+	state, ok := am.states[action]
+	return ok && state.justReleased
+}
+
+// Axis2D returns a composite axis' value, normalised so diagonals aren't faster
+func (am *ActionMap) Axis2D(name string) mgl32.Vec2 {
+	axis, ok := am.axes[name]
+	if !ok {
+		return mgl32.Vec2{}
+	}
+
+	value := mgl32.Vec2{
+		am.Value(axis.right) - am.Value(axis.left),
+		am.Value(axis.up) - am.Value(axis.down),
+	}
+	if value.Len() > 1 {
+		value = value.Normalize()
+	}
+
+	return value
+}
+
+// update works out this frame's action state from the events since the last
+// call and the gamepads' polled state.
+func (am *ActionMap) update(gamepads []*Gamepad) {
+	am.listenGamepads(gamepads)
+
+	for binding := range am.suppressed {
+		if binding.Device != Keyboard && binding.Device != Mouse && am.gamepadValue(binding, gamepads) < am.PressPoint {
+			delete(am.suppressed, binding)
+		}
+	}
+
+	for action, state := range am.states {
+		wasHeld := state.held
+		state.value = 0
+
+		pressed, released := false, false
+		for _, binding := range am.bindings[action] {
+			state.value = max(state.value, am.bindingValue(binding, gamepads))
+			pressed = pressed || am.pressed[binding]
+			released = released || am.released[binding]
+		}
+		state.held = state.value > 0 && state.value >= am.PressPoint
+
+		// A press and release between updates still counts as both, pressing
+		// another binding of an action that's already held doesn't
+		state.justPressed = (state.held || pressed) && !wasHeld
+		state.justReleased = (!state.held && wasHeld) || (released && !state.held)
+	}
+
+	clear(am.pressed)
+	clear(am.released)
+}
+
+// Reset releases every binding, e.g when the window loses focus
+func (am *ActionMap) Reset() {
+	clear(am.suppressed)
+	clear(am.down)
+	clear(am.pressed)
+	clear(am.released)
+}
+
+func (am *ActionMap) bindingValue(binding Binding, gamepads []*Gamepad) float32 {
+	if am.suppressed[binding] {
+		return 0
+	}
+
+	switch binding.Device {
+	case Keyboard, Mouse:
+		if am.down[binding] {
+			return 1
+		}
+	case GamepadButtons, GamepadAxes:
+		return am.gamepadValue(binding, gamepads)
+	}
+	return 0
+}
+
+func (am *ActionMap) gamepadValue(binding Binding, gamepads []*Gamepad) float32 {
+	value := float32(0)
+	for _, gamepad := range gamepads {
+		if am.Gamepad != AnyGamepad && gamepad.Joystick != am.Gamepad {
+			continue
+		}
+
+		if binding.Device == GamepadButtons {
+			if gamepad.Button(glfw.GamepadButton(binding.Code)) {
+				value = 1
+			}
+		} else {
+			value = max(value, gamepad.Axis(glfw.GamepadAxis(binding.Code))*float32(binding.Direction))
+		}
+	}
+	return value
+}
+
+// listenGamepads catches a gamepad input for ListenForInput, keys and mouse
+// buttons are caught as their events arrive.
+func (am *ActionMap) listenGamepads(gamepads []*Gamepad) {
+	if am.listener == nil {
+		return
+	}
+
+	active := make(map[Binding]bool)
+	var pressed []Binding
+	for _, gamepad := range gamepads {
+		if am.Gamepad != AnyGamepad && gamepad.Joystick != am.Gamepad {
+			continue
+		}
+
+		for button := range gamepad.Buttons {
+			if gamepad.Buttons[button] {
+				pressed = append(pressed, GamepadButton(glfw.GamepadButton(button)))
+			}
+		}
+		for axis, value := range gamepad.Axes {
+			if value >= am.PressPoint {
+				pressed = append(pressed, GamepadAxis(glfw.GamepadAxis(axis), 1))
+			} else if -value >= am.PressPoint {
+				pressed = append(pressed, GamepadAxis(glfw.GamepadAxis(axis), -1))
+			}
+		}
+	}
+	for _, binding := range pressed {
+		active[binding] = true
+	}
+
+	if am.listenBase == nil {
+		am.listenBase = active
+		return
+	}
+
+	for binding := range am.listenBase {
+		if !active[binding] {
+			delete(am.listenBase, binding)
+		}
+	}
+
+	for _, binding := range pressed {
+		if !am.listenBase[binding] {
+			am.capture(binding)
+			return
+		}
+	}
+}
+
+func (am *ActionMap) capture(binding Binding) {
+	listener := am.listener
+	am.CancelListening()
+	am.suppressed[binding] = true
+
+	listener(binding)
+}
+
+func (am *ActionMap) handle(binding Binding, action glfw.Action) {
+	switch action {
+	case glfw.Press:
+		if am.listener != nil {
+			am.capture(binding)
+			return
+		}
+		am.down[binding] = true
+		am.pressed[binding] = true
+	case glfw.Release:
+		if am.suppressed[binding] {
+			delete(am.suppressed, binding)
+			return
+		}
+		am.down[binding] = false
+		am.released[binding] = true
+	}
+}
@@ -0,0 +1,153 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func newFakeInputManager() (*InputManager, *FakeInput) {
+	fake := NewFakeInput()
+	return NewInputManagerFromSource(fake), fake
+}
+
+type actionFrame struct {
+	justPressed, held, justReleased bool
+}
+
+func checkAction(t *testing.T, actions *ActionMap, action string, want actionFrame) {
+	t.Helper()
+
+	got := actionFrame{actions.JustPressed(action), actions.Held(action), actions.JustReleased(action)}
+	if got != want {
+		t.Errorf("%s is %+v, want %+v", action, got, want)
+	}
+}
+
+func TestActionPressHoldRelease(t *testing.T) {
+	im, fake := newFakeInputManager()
+	im.Actions.Bind("jump", Key(glfw.KeySpace))
+
+	fake.PressKey(glfw.KeySpace)
+	im.Update()
+	checkAction(t, im.Actions, "jump", actionFrame{justPressed: true, held: true})
+
+	im.Update()
+	checkAction(t, im.Actions, "jump", actionFrame{held: true})
+
+	fake.ReleaseKey(glfw.KeySpace)
+	im.Update()
+	checkAction(t, im.Actions, "jump", actionFrame{justReleased: true})
+
+	im.Update()
+	checkAction(t, im.Actions, "jump", actionFrame{})
+}
+
+// A tap shorter than a frame is still seen as a press and a release
+func TestActionTapWithinFrame(t *testing.T) {
+	im, fake := newFakeInputManager()
+	im.Actions.Bind("jump", Key(glfw.KeySpace))
+
+	fake.PressKey(glfw.KeySpace)
+	fake.ReleaseKey(glfw.KeySpace)
+	im.Update()
+	checkAction(t, im.Actions, "jump", actionFrame{justPressed: true, justReleased: true})
+
+	im.Update()
+	checkAction(t, im.Actions, "jump", actionFrame{})
+}
+
+func TestActionMultipleBindings(t *testing.T) {
+	im, fake := newFakeInputManager()
+	im.Actions.Bind("fire", Key(glfw.KeyF), MouseButton(glfw.MouseButtonLeft))
+	im.Actions.Bind("use", Key(glfw.KeyF))
+
+	fake.PressKey(glfw.KeyF)
+	im.Update()
+	checkAction(t, im.Actions, "fire", actionFrame{justPressed: true, held: true})
+	checkAction(t, im.Actions, "use", actionFrame{justPressed: true, held: true})
+
+	// Still held through the mouse button, so releasing the key changes nothing
+	fake.PressMouseButton(glfw.MouseButtonLeft)
+	fake.ReleaseKey(glfw.KeyF)
+	im.Update()
+	checkAction(t, im.Actions, "fire", actionFrame{held: true})
+	checkAction(t, im.Actions, "use", actionFrame{justReleased: true})
+
+	fake.ReleaseMouseButton(glfw.MouseButtonLeft)
+	im.Update()
+	checkAction(t, im.Actions, "fire", actionFrame{justReleased: true})
+}
+
+func TestActionAxis2D(t *testing.T) {
+	im, fake := newFakeInputManager()
+	im.Actions.Bind("forward", Key(glfw.KeyW))
+	im.Actions.Bind("backward", Key(glfw.KeyS))
+	im.Actions.Bind("left", Key(glfw.KeyA))
+	im.Actions.Bind("right", Key(glfw.KeyD))
+	im.Actions.BindAxis2D("move", "forward", "backward", "left", "right")
+
+	fake.PressKey(glfw.KeyW)
+	im.Update()
+	if move := im.Actions.Axis2D("move"); move != (mgl32.Vec2{0, 1}) {
+		t.Errorf("forward is %v", move)
+	}
+
+	// Diagonals are normalised
+	fake.PressKey(glfw.KeyD)
+	im.Update()
+	if move := im.Actions.Axis2D("move"); !move.ApproxEqual(mgl32.Vec2{1, 1}.Normalize()) {
+		t.Errorf("forward right is %v", move)
+	}
+
+	// Opposites cancel out
+	fake.ReleaseKey(glfw.KeyW)
+	fake.PressKey(glfw.KeyA)
+	im.Update()
+	if move := im.Actions.Axis2D("move"); move != (mgl32.Vec2{}) {
+		t.Errorf("left and right is %v", move)
+	}
+
+	if move := im.Actions.Axis2D("missing"); move != (mgl32.Vec2{}) {
+		t.Errorf("unknown axis is %v", move)
+	}
+}
+
+// Key events reach every action map, not only the default one
+func TestActionMapsShareInput(t *testing.T) {
+	im, fake := newFakeInputManager()
+	menu := im.NewActionMap()
+	menu.Bind("back", Key(glfw.KeyEscape))
+	im.Actions.Bind("close", Key(glfw.KeyEscape))
+
+	fake.PressKey(glfw.KeyEscape)
+	im.Update()
+	checkAction(t, menu, "back", actionFrame{justPressed: true, held: true})
+	checkAction(t, im.Actions, "close", actionFrame{justPressed: true, held: true})
+}
+
+// Legacy key handlers run in registration order, so replays call them the same way
+func TestRegisterKeyActionOrder(t *testing.T) {
+	im, fake := newFakeInputManager()
+
+	var order []int
+	keys := []glfw.Key{glfw.KeyD, glfw.KeyA, glfw.KeyW, glfw.KeyS, glfw.KeySpace, glfw.KeyE}
+	for action, key := range keys {
+		im.RegisterKeyAction(key, action, func() { order = append(order, action) })
+		fake.PressKey(key)
+	}
+
+	for frame := 0; frame < 20; frame++ {
+		order = order[:0]
+		im.Update()
+		if len(order) != len(keys) {
+			t.Fatalf("frame %d ran handlers %v, want all %d", frame, order, len(keys))
+		}
+		for i, action := range order {
+			if action != i {
+				t.Fatalf("frame %d ran handlers in order %v", frame, order)
+			}
+		}
+	}
+}
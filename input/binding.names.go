@@ -0,0 +1,303 @@
+package input
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Bindings are written by name so config files can be edited by hand, e.g "W",
+// "MouseLeft", "GamepadA" or "GamepadLeftY-". Inputs without a name are written
+// as their device and code, e.g "Keyboard:348".
+
+var keyNames = map[string]glfw.Key{
+	"0":            glfw.Key0,
+	"1":            glfw.Key1,
+	"2":            glfw.Key2,
+	"3":            glfw.Key3,
+	"4":            glfw.Key4,
+	"5":            glfw.Key5,
+	"6":            glfw.Key6,
+	"7":            glfw.Key7,
+	"8":            glfw.Key8,
+	"9":            glfw.Key9,
+	"A":            glfw.KeyA,
+	"B":            glfw.KeyB,
+	"C":            glfw.KeyC,
+	"D":            glfw.KeyD,
+	"E":            glfw.KeyE,
+	"F":            glfw.KeyF,
+	"G":            glfw.KeyG,
+	"H":            glfw.KeyH,
+	"I":            glfw.KeyI,
+	"J":            glfw.KeyJ,
+	"K":            glfw.KeyK,
+	"L":            glfw.KeyL,
+	"M":            glfw.KeyM,
+	"N":            glfw.KeyN,
+	"O":            glfw.KeyO,
+	"P":            glfw.KeyP,
+	"Q":            glfw.KeyQ,
+	"R":            glfw.KeyR,
+	"S":            glfw.KeyS,
+	"T":            glfw.KeyT,
+	"U":            glfw.KeyU,
+	"V":            glfw.KeyV,
+	"W":            glfw.KeyW,
+	"X":            glfw.KeyX,
+	"Y":            glfw.KeyY,
+	"Z":            glfw.KeyZ,
+	"Space":        glfw.KeySpace,
+	"Apostrophe":   glfw.KeyApostrophe,
+	"Comma":        glfw.KeyComma,
+	"Minus":        glfw.KeyMinus,
+	"Period":       glfw.KeyPeriod,
+	"Slash":        glfw.KeySlash,
+	"Semicolon":    glfw.KeySemicolon,
+	"Equal":        glfw.KeyEqual,
+	"LeftBracket":  glfw.KeyLeftBracket,
+	"Backslash":    glfw.KeyBackslash,
+	"RightBracket": glfw.KeyRightBracket,
+	"GraveAccent":  glfw.KeyGraveAccent,
+	"World1":       glfw.KeyWorld1,
+	"World2":       glfw.KeyWorld2,
+	"Escape":       glfw.KeyEscape,
+	"Enter":        glfw.KeyEnter,
+	"Tab":          glfw.KeyTab,
+	"Backspace":    glfw.KeyBackspace,
+	"Insert":       glfw.KeyInsert,
+	"Delete":       glfw.KeyDelete,
+	"Right":        glfw.KeyRight,
+	"Left":         glfw.KeyLeft,
+	"Down":         glfw.KeyDown,
+	"Up":           glfw.KeyUp,
+	"PageUp":       glfw.KeyPageUp,
+	"PageDown":     glfw.KeyPageDown,
+	"Home":         glfw.KeyHome,
+	"End":          glfw.KeyEnd,
+	"CapsLock":     glfw.KeyCapsLock,
+	"ScrollLock":   glfw.KeyScrollLock,
+	"NumLock":      glfw.KeyNumLock,
+	"PrintScreen":  glfw.KeyPrintScreen,
+	"Pause":        glfw.KeyPause,
+	"F1":           glfw.KeyF1,
+	"F2":           glfw.KeyF2,
+	"F3":           glfw.KeyF3,
+	"F4":           glfw.KeyF4,
+	"F5":           glfw.KeyF5,
+	"F6":           glfw.KeyF6,
+	"F7":           glfw.KeyF7,
+	"F8":           glfw.KeyF8,
+	"F9":           glfw.KeyF9,
+	"F10":          glfw.KeyF10,
+	"F11":          glfw.KeyF11,
+	"F12":          glfw.KeyF12,
+	"F13":          glfw.KeyF13,
+	"F14":          glfw.KeyF14,
+	"F15":          glfw.KeyF15,
+	"F16":          glfw.KeyF16,
+	"F17":          glfw.KeyF17,
+	"F18":          glfw.KeyF18,
+	"F19":          glfw.KeyF19,
+	"F20":          glfw.KeyF20,
+	"F21":          glfw.KeyF21,
+	"F22":          glfw.KeyF22,
+	"F23":          glfw.KeyF23,
+	"F24":          glfw.KeyF24,
+	"F25":          glfw.KeyF25,
+	"KP0":          glfw.KeyKP0,
+	"KP1":          glfw.KeyKP1,
+	"KP2":          glfw.KeyKP2,
+	"KP3":          glfw.KeyKP3,
+	"KP4":          glfw.KeyKP4,
+	"KP5":          glfw.KeyKP5,
+	"KP6":          glfw.KeyKP6,
+	"KP7":          glfw.KeyKP7,
+	"KP8":          glfw.KeyKP8,
+	"KP9":          glfw.KeyKP9,
+	"KPDecimal":    glfw.KeyKPDecimal,
+	"KPDivide":     glfw.KeyKPDivide,
+	"KPMultiply":   glfw.KeyKPMultiply,
+	"KPSubtract":   glfw.KeyKPSubtract,
+	"KPAdd":        glfw.KeyKPAdd,
+	"KPEnter":      glfw.KeyKPEnter,
+	"KPEqual":      glfw.KeyKPEqual,
+	"LeftShift":    glfw.KeyLeftShift,
+	"LeftControl":  glfw.KeyLeftControl,
+	"LeftAlt":      glfw.KeyLeftAlt,
+	"LeftSuper":    glfw.KeyLeftSuper,
+	"RightShift":   glfw.KeyRightShift,
+	"RightControl": glfw.KeyRightControl,
+	"RightAlt":     glfw.KeyRightAlt,
+	"RightSuper":   glfw.KeyRightSuper,
+	"Menu":         glfw.KeyMenu,
+}
+
+var mouseButtonNames = map[string]glfw.MouseButton{
+	"MouseLeft":   glfw.MouseButtonLeft,
+	"MouseRight":  glfw.MouseButtonRight,
+	"MouseMiddle": glfw.MouseButtonMiddle,
+	"Mouse4":      glfw.MouseButton4,
+	"Mouse5":      glfw.MouseButton5,
+	"Mouse6":      glfw.MouseButton6,
+	"Mouse7":      glfw.MouseButton7,
+	"Mouse8":      glfw.MouseButton8,
+}
+
+var gamepadButtonNames = map[string]glfw.GamepadButton{
+	"A":           glfw.ButtonA,
+	"B":           glfw.ButtonB,
+	"X":           glfw.ButtonX,
+	"Y":           glfw.ButtonY,
+	"LeftBumper":  glfw.ButtonLeftBumper,
+	"RightBumper": glfw.ButtonRightBumper,
+	"Back":        glfw.ButtonBack,
+	"Start":       glfw.ButtonStart,
+	"Guide":       glfw.ButtonGuide,
+	"LeftThumb":   glfw.ButtonLeftThumb,
+	"RightThumb":  glfw.ButtonRightThumb,
+	"DpadUp":      glfw.ButtonDpadUp,
+	"DpadRight":   glfw.ButtonDpadRight,
+	"DpadDown":    glfw.ButtonDpadDown,
+	"DpadLeft":    glfw.ButtonDpadLeft,
+}
+
+var gamepadAxisNames = map[string]glfw.GamepadAxis{
+	"LeftX":        glfw.AxisLeftX,
+	"LeftY":        glfw.AxisLeftY,
+	"RightX":       glfw.AxisRightX,
+	"RightY":       glfw.AxisRightY,
+	"LeftTrigger":  glfw.AxisLeftTrigger,
+	"RightTrigger": glfw.AxisRightTrigger,
+}
+
+var deviceNames = map[Device]string{
+	Keyboard:       "Keyboard",
+	Mouse:          "Mouse",
+	GamepadButtons: "GamepadButton",
+	GamepadAxes:    "GamepadAxis",
+}
+
+const gamepadPrefix = "Gamepad"
+
+func (binding Binding) String() string {
+	switch binding.Device {
+	case Keyboard:
+		if name, ok := nameOf(keyNames, glfw.Key(binding.Code)); ok {
+			return name
+		}
+	case Mouse:
+		if name, ok := nameOf(mouseButtonNames, glfw.MouseButton(binding.Code)); ok {
+			return name
+		}
+	case GamepadButtons:
+		if name, ok := nameOf(gamepadButtonNames, glfw.GamepadButton(binding.Code)); ok {
+			return gamepadPrefix + name
+		}
+	case GamepadAxes:
+		if name, ok := nameOf(gamepadAxisNames, glfw.GamepadAxis(binding.Code)); ok {
+			return gamepadPrefix + name + directionSuffix(binding.Direction)
+		}
+		return fmt.Sprintf("%s:%d%s", deviceNames[binding.Device], binding.Code, directionSuffix(binding.Direction))
+	}
+
+	return fmt.Sprintf("%s:%d", deviceNames[binding.Device], binding.Code)
+}
+
+func (binding Binding) MarshalText() ([]byte, error) {
+	if _, ok := deviceNames[binding.Device]; !ok {
+		return nil, fmt.Errorf("unknown input device %d", binding.Device)
+	}
+	return []byte(binding.String()), nil
+}
+
+func (binding *Binding) UnmarshalText(text []byte) error {
+	parsed, err := ParseBinding(string(text))
+	if err != nil {
+		return err
+	}
+
+	*binding = parsed
+	return nil
+}
+
+// ParseBinding reads a binding written by Binding.String
+func ParseBinding(text string) (Binding, error) {
+	if device, code, ok := strings.Cut(text, ":"); ok {
+		return parseCodeBinding(text, device, code)
+	}
+
+	if key, ok := keyNames[text]; ok {
+		return Key(key), nil
+	}
+	if button, ok := mouseButtonNames[text]; ok {
+		return MouseButton(button), nil
+	}
+
+	if name, ok := strings.CutPrefix(text, gamepadPrefix); ok {
+		if button, ok := gamepadButtonNames[name]; ok {
+			return GamepadButton(button), nil
+		}
+
+		if axisName, direction, ok := cutDirection(name); ok {
+			if axis, ok := gamepadAxisNames[axisName]; ok {
+				return GamepadAxis(axis, direction), nil
+			}
+		}
+	}
+
+	return Binding{}, fmt.Errorf("unknown input %q", text)
+}
+
+func parseCodeBinding(text, deviceName, code string) (Binding, error) {
+	for device, name := range deviceNames {
+		if name != deviceName {
+			continue
+		}
+
+		direction := 0
+		if device == GamepadAxes {
+			var ok bool
+			if code, direction, ok = cutDirection(code); !ok {
+				return Binding{}, fmt.Errorf("gamepad axis %q needs a direction, + or -", text)
+			}
+		}
+
+		value, err := strconv.Atoi(code)
+		if err != nil {
+			return Binding{}, fmt.Errorf("invalid input code in %q", text)
+		}
+
+		return Binding{Device: device, Code: value, Direction: direction}, nil
+	}
+
+	return Binding{}, fmt.Errorf("unknown input device in %q", text)
+}
+
+func directionSuffix(direction int) string {
+	if direction < 0 {
+		return "-"
+	}
+	return "+"
+}
+
+func cutDirection(text string) (string, int, bool) {
+	if name, ok := strings.CutSuffix(text, "+"); ok {
+		return name, 1, true
+	}
+	if name, ok := strings.CutSuffix(text, "-"); ok {
+		return name, -1, true
+	}
+	return text, 0, false
+}
+
+func nameOf[T comparable](names map[string]T, value T) (string, bool) {
+	for name, named := range names {
+		if named == value {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,50 @@
+package input
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+type CursorMode int
+
+const (
+	CursorNormal   CursorMode = iota
+	CursorHidden              // Hidden while over the window, but free to leave it
+	CursorCaptured            // Hidden and locked to the window, for mouse look
+)
+
+var cursorModeNames = []string{"normal", "hidden", "captured"}
+
+func (mode CursorMode) String() string {
+	if mode < 0 || int(mode) >= len(cursorModeNames) {
+		return fmt.Sprintf("CursorMode(%d)", int(mode))
+	}
+	return cursorModeNames[mode]
+}
+
+// Cursor modes are written by name in config files and flags
+
+func (mode CursorMode) MarshalText() ([]byte, error) {
+	return []byte(mode.String()), nil
+}
+
+func (mode *CursorMode) UnmarshalText(text []byte) error {
+	for i, name := range cursorModeNames {
+		if string(text) == name {
+			*mode = CursorMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown cursor mode %q, expected normal, hidden or captured", text)
+}
+
+func (mode CursorMode) glfwMode() int {
+	switch mode {
+	case CursorHidden:
+		return glfw.CursorHidden
+	case CursorCaptured:
+		return glfw.CursorDisabled
+	}
+	return glfw.CursorNormal
+}
@@ -0,0 +1,136 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestMouseDelta(t *testing.T) {
+	im, fake := newFakeInputManager()
+
+	// The first position has nothing to be relative to
+	fake.MoveMouse(100, 100)
+	im.Update()
+	if delta := im.MouseDelta(); delta != (mgl32.Vec2{}) {
+		t.Errorf("first move gave delta %v", delta)
+	}
+
+	// Moves between updates add up
+	fake.MoveMouse(110, 95)
+	fake.MoveMouse(130, 90)
+	im.Update()
+	if delta := im.MouseDelta(); delta != (mgl32.Vec2{30, -10}) {
+		t.Errorf("delta %v, want [30 -10]", delta)
+	}
+	if x, y := im.CursorPosition(); x != 130 || y != 90 {
+		t.Errorf("cursor at %v, %v", x, y)
+	}
+
+	im.Update()
+	if delta := im.MouseDelta(); delta != (mgl32.Vec2{}) {
+		t.Errorf("delta %v without moving", delta)
+	}
+}
+
+func TestScrollDelta(t *testing.T) {
+	im, fake := newFakeInputManager()
+
+	fake.Scroll(0, 1)
+	fake.Scroll(0.5, 2)
+	im.Update()
+	if delta := im.ScrollDelta(); delta != (mgl32.Vec2{0.5, 3}) {
+		t.Errorf("scrolled %v, want [0.5 3]", delta)
+	}
+
+	im.Update()
+	if delta := im.ScrollDelta(); delta != (mgl32.Vec2{}) {
+		t.Errorf("scrolled %v without scrolling", delta)
+	}
+}
+
+func TestSetCursorMode(t *testing.T) {
+	im, fake := newFakeInputManager()
+
+	cases := []struct {
+		mode CursorMode
+		glfw int
+	}{
+		{CursorCaptured, glfw.CursorDisabled},
+		{CursorHidden, glfw.CursorHidden},
+		{CursorNormal, glfw.CursorNormal},
+	}
+	for _, c := range cases {
+		im.SetCursorMode(c.mode)
+		if im.CursorMode() != c.mode || fake.CursorMode != c.glfw {
+			t.Errorf("set %v, manager has %v and source %v", c.mode, im.CursorMode(), fake.CursorMode)
+		}
+	}
+
+	if !im.SetRawMouseMotion(true) || !fake.RawMouseMotion {
+		t.Error("raw mouse motion not enabled")
+	}
+	im.SetRawMouseMotion(false)
+	if fake.RawMouseMotion {
+		t.Error("raw mouse motion not disabled")
+	}
+}
+
+// Capturing the cursor warps it, which shouldn't count as a mouse movement
+func TestCursorModeChangeSkipsJump(t *testing.T) {
+	im, fake := newFakeInputManager()
+
+	fake.MoveMouse(10, 10)
+	im.Update()
+
+	im.SetCursorMode(CursorCaptured)
+	fake.MoveMouse(400, 300)
+	im.Update()
+	if delta := im.MouseDelta(); delta != (mgl32.Vec2{}) {
+		t.Errorf("capturing the cursor moved it %v", delta)
+	}
+
+	fake.MoveMouse(405, 298)
+	im.Update()
+	if delta := im.MouseDelta(); delta != (mgl32.Vec2{5, -2}) {
+		t.Errorf("delta %v after capturing, want [5 -2]", delta)
+	}
+}
+
+func TestCursorModeNames(t *testing.T) {
+	for _, mode := range []CursorMode{CursorNormal, CursorHidden, CursorCaptured} {
+		text, err := mode.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parsed CursorMode
+		if err := parsed.UnmarshalText(text); err != nil || parsed != mode {
+			t.Errorf("%q parsed as %v, %v", text, parsed, err)
+		}
+	}
+
+	var mode CursorMode
+	if err := mode.UnmarshalText([]byte("locked")); err == nil {
+		t.Error("parsed an unknown cursor mode")
+	}
+	if name := CursorMode(7).String(); name != "CursorMode(7)" {
+		t.Errorf("unknown mode named %q", name)
+	}
+}
+
+func TestMouseButtonActions(t *testing.T) {
+	im, fake := newFakeInputManager()
+	im.Actions.Bind("fire", MouseButton(glfw.MouseButtonLeft))
+	im.Actions.Bind("aim", MouseButton(glfw.MouseButtonRight))
+
+	fake.PressMouseButton(glfw.MouseButtonLeft)
+	im.Update()
+	checkAction(t, im.Actions, "fire", actionFrame{justPressed: true, held: true})
+	checkAction(t, im.Actions, "aim", actionFrame{})
+
+	fake.ReleaseMouseButton(glfw.MouseButtonLeft)
+	im.Update()
+	checkAction(t, im.Actions, "fire", actionFrame{justReleased: true})
+}
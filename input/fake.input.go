@@ -0,0 +1,165 @@
+package input
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// FakeInput is an InputSource driven from code, used by the headless engine so
+// tests can press keys, move the mouse and plug in gamepads without a window.
+type FakeInput struct {
+	CursorMode     int
+	RawMouseMotion bool
+
+	keyCallback         func(key glfw.Key, action glfw.Action)
+	mouseButtonCallback func(button glfw.MouseButton, action glfw.Action)
+	cursorPosCallback   func(xpos, ypos float64)
+	scrollCallback      func(xoffset, yoffset float64)
+
+	joystickCallback func(joystick glfw.Joystick, connected bool)
+	gamepads         map[glfw.Joystick]*fakeGamepad
+}
+
+type fakeGamepad struct {
+	name  string
+	state glfw.GamepadState
+}
+
+func NewFakeInput() *FakeInput {
+	return &FakeInput{
+		CursorMode: glfw.CursorNormal,
+		gamepads:   make(map[glfw.Joystick]*fakeGamepad),
+	}
+}
+
+func (fake *FakeInput) SetKeyCallback(callback func(key glfw.Key, action glfw.Action)) {
+	fake.keyCallback = callback
+}
+
+func (fake *FakeInput) SetMouseButtonCallback(callback func(button glfw.MouseButton, action glfw.Action)) {
+	fake.mouseButtonCallback = callback
+}
+
+func (fake *FakeInput) SetCursorPosCallback(callback func(xpos, ypos float64)) {
+	fake.cursorPosCallback = callback
+}
+
+func (fake *FakeInput) SetScrollCallback(callback func(xoffset, yoffset float64)) {
+	fake.scrollCallback = callback
+}
+
+func (fake *FakeInput) SetCursorMode(mode int) {
+	fake.CursorMode = mode
+}
+
+func (fake *FakeInput) RawMouseMotionSupported() bool {
+	return true
+}
+
+func (fake *FakeInput) SetRawMouseMotion(enabled bool) {
+	fake.RawMouseMotion = enabled
+}
+
+func (fake *FakeInput) PressKey(key glfw.Key) {
+	if fake.keyCallback != nil {
+		fake.keyCallback(key, glfw.Press)
+	}
+}
+
+func (fake *FakeInput) ReleaseKey(key glfw.Key) {
+	if fake.keyCallback != nil {
+		fake.keyCallback(key, glfw.Release)
+	}
+}
+
+func (fake *FakeInput) PressMouseButton(button glfw.MouseButton) {
+	if fake.mouseButtonCallback != nil {
+		fake.mouseButtonCallback(button, glfw.Press)
+	}
+}
+
+func (fake *FakeInput) ReleaseMouseButton(button glfw.MouseButton) {
+	if fake.mouseButtonCallback != nil {
+		fake.mouseButtonCallback(button, glfw.Release)
+	}
+}
+
+func (fake *FakeInput) MoveMouse(xpos, ypos float64) {
+	if fake.cursorPosCallback != nil {
+		fake.cursorPosCallback(xpos, ypos)
+	}
+}
+
+func (fake *FakeInput) Scroll(xoffset, yoffset float64) {
+	if fake.scrollCallback != nil {
+		fake.scrollCallback(xoffset, yoffset)
+	}
+}
+
+func (fake *FakeInput) SetJoystickCallback(callback func(joystick glfw.Joystick, connected bool)) {
+	fake.joystickCallback = callback
+}
+
+func (fake *FakeInput) Gamepads() []glfw.Joystick {
+	var gamepads []glfw.Joystick
+	for joystick := glfw.Joystick1; joystick <= glfw.JoystickLast; joystick++ {
+		if _, ok := fake.gamepads[joystick]; ok {
+			gamepads = append(gamepads, joystick)
+		}
+	}
+	return gamepads
+}
+
+func (fake *FakeInput) GamepadName(joystick glfw.Joystick) string {
+	if gamepad, ok := fake.gamepads[joystick]; ok {
+		return gamepad.name
+	}
+	return ""
+}
+
+func (fake *FakeInput) GamepadState(joystick glfw.Joystick) (glfw.GamepadState, bool) {
+	gamepad, ok := fake.gamepads[joystick]
+	if !ok {
+		return glfw.GamepadState{}, false
+	}
+	return gamepad.state, true
+}
+
+// ConnectGamepad plugs in a gamepad with every button released, sticks centred
+// and triggers at rest.
+func (fake *FakeInput) ConnectGamepad(joystick glfw.Joystick, name string) {
+	gamepad := &fakeGamepad{name: name}
+	for i := range gamepad.state.Buttons {
+		gamepad.state.Buttons[i] = glfw.Release
+	}
+	gamepad.state.Axes[glfw.AxisLeftTrigger] = -1
+	gamepad.state.Axes[glfw.AxisRightTrigger] = -1
+	fake.gamepads[joystick] = gamepad
+
+	if fake.joystickCallback != nil {
+		fake.joystickCallback(joystick, true)
+	}
+}
+
+func (fake *FakeInput) DisconnectGamepad(joystick glfw.Joystick) {
+	delete(fake.gamepads, joystick)
+
+	if fake.joystickCallback != nil {
+		fake.joystickCallback(joystick, false)
+	}
+}
+
+func (fake *FakeInput) SetGamepadButton(joystick glfw.Joystick, button glfw.GamepadButton, pressed bool) {
+	if gamepad, ok := fake.gamepads[joystick]; ok {
+		gamepad.state.Buttons[button] = glfw.Release
+		if pressed {
+			gamepad.state.Buttons[button] = glfw.Press
+		}
+	}
+}
+
+// SetGamepadAxis sets an axis' raw value, triggers go from -1 at rest to 1
+func (fake *FakeInput) SetGamepadAxis(joystick glfw.Joystick, axis glfw.GamepadAxis, value float32) {
+	if gamepad, ok := fake.gamepads[joystick]; ok {
+		gamepad.state.Axes[axis] = value
+	}
+}
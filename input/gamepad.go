@@ -0,0 +1,75 @@
+package input
+
+import (
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+const (
+	gamepadButtonCount = int(glfw.ButtonLast) + 1
+	gamepadAxisCount   = int(glfw.AxisLast) + 1
+
+	DefaultDeadZone float32 = 0.15
+)
+
+// Gamepad is the state of a connected controller using the standard GLFW
+// gamepad mapping. Axes have their dead zone applied and triggers run from 0
+// at rest to 1 fully pressed.
+type Gamepad struct {
+	Joystick glfw.Joystick
+	Name     string
+	Buttons  [gamepadButtonCount]bool
+	Axes     [gamepadAxisCount]float32
+
+	state glfw.GamepadState // Raw state from the last poll
+}
+
+func (gamepad *Gamepad) Button(button glfw.GamepadButton) bool {
+	if button < 0 || int(button) >= gamepadButtonCount {
+		return false
+	}
+	return gamepad.Buttons[button]
+}
+
+func (gamepad *Gamepad) Axis(axis glfw.GamepadAxis) float32 {
+	if axis < 0 || int(axis) >= gamepadAxisCount {
+		return 0
+	}
+	return gamepad.Axes[axis]
+}
+
+// update works out the buttons and axes from the raw state, deadZones is
+// indexed by axis
+func (gamepad *Gamepad) update(deadZones [gamepadAxisCount]float32) {
+	for i, action := range gamepad.state.Buttons {
+		gamepad.Buttons[i] = action == glfw.Press
+	}
+
+	for i, value := range gamepad.state.Axes {
+		axis := glfw.GamepadAxis(i)
+		if axis == glfw.AxisLeftTrigger || axis == glfw.AxisRightTrigger {
+			value = (value + 1) / 2
+		}
+		gamepad.Axes[i] = applyDeadZone(value, deadZones[i])
+	}
+}
+
+// applyDeadZone zeroes values inside the dead zone and rescales the rest so
+// output still starts from 0 at its edge.
+func applyDeadZone(value, deadZone float32) float32 {
+	magnitude := float32(math.Abs(float64(value)))
+	if magnitude <= deadZone {
+		return 0
+	}
+
+	scaled := (magnitude - deadZone) / (1 - deadZone)
+	if scaled > 1 {
+		scaled = 1
+	}
+
+	if value < 0 {
+		return -scaled
+	}
+	return scaled
+}
@@ -0,0 +1,138 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func approxEqual(a, b float32) bool {
+	return mgl32.FloatEqualThreshold(a, b, 1e-5)
+}
+
+func TestGamepadDiscoveryAndHotPlug(t *testing.T) {
+	fake := NewFakeInput()
+	fake.ConnectGamepad(glfw.Joystick2, "Already plugged in")
+
+	im := NewInputManagerFromSource(fake)
+	if gamepad, ok := im.Gamepad(glfw.Joystick2); !ok || gamepad.Name != "Already plugged in" {
+		t.Fatalf("gamepad connected at startup not found, have %v", im.Gamepads)
+	}
+
+	var connected, disconnected []glfw.Joystick
+	im.OnGamepadConnected(func(gamepad *Gamepad) { connected = append(connected, gamepad.Joystick) })
+	im.OnGamepadDisconnected(func(gamepad *Gamepad) { disconnected = append(disconnected, gamepad.Joystick) })
+
+	fake.ConnectGamepad(glfw.Joystick1, "Plugged in later")
+	if len(connected) != 1 || connected[0] != glfw.Joystick1 || len(im.Gamepads) != 2 {
+		t.Errorf("connected %v, gamepads %v", connected, im.Gamepads)
+	}
+
+	fake.DisconnectGamepad(glfw.Joystick2)
+	if len(disconnected) != 1 || disconnected[0] != glfw.Joystick2 {
+		t.Errorf("disconnected %v", disconnected)
+	}
+	if _, ok := im.Gamepad(glfw.Joystick2); ok || len(im.Gamepads) != 1 {
+		t.Errorf("gamepads %v after unplugging", im.Gamepads)
+	}
+}
+
+func TestGamepadDeadZones(t *testing.T) {
+	im, fake := newFakeInputManager()
+	fake.ConnectGamepad(glfw.Joystick1, "Pad")
+	gamepad, _ := im.Gamepad(glfw.Joystick1)
+
+	cases := []struct {
+		raw, want float32
+	}{
+		{0.1, 0},   // Inside the default dead zone
+		{-0.15, 0}, // On its edge
+		{0.575, 0.5},
+		{-0.575, -0.5},
+		{1, 1},
+	}
+	for _, c := range cases {
+		fake.SetGamepadAxis(glfw.Joystick1, glfw.AxisLeftX, c.raw)
+		im.Update()
+		if got := gamepad.Axis(glfw.AxisLeftX); !approxEqual(got, c.want) {
+			t.Errorf("raw %v read as %v, want %v", c.raw, got, c.want)
+		}
+	}
+
+	im.SetDeadZone(glfw.AxisLeftX, 0.5)
+	fake.SetGamepadAxis(glfw.Joystick1, glfw.AxisLeftX, 0.4)
+	im.Update()
+	if got := gamepad.Axis(glfw.AxisLeftX); got != 0 {
+		t.Errorf("raw 0.4 read as %v inside a 0.5 dead zone", got)
+	}
+
+	// Triggers rest at -1 raw and read from 0
+	if got := gamepad.Axis(glfw.AxisLeftTrigger); got != 0 {
+		t.Errorf("trigger at rest reads %v", got)
+	}
+	fake.SetGamepadAxis(glfw.Joystick1, glfw.AxisLeftTrigger, 1)
+	im.Update()
+	if got := gamepad.Axis(glfw.AxisLeftTrigger); got != 1 {
+		t.Errorf("pressed trigger reads %v", got)
+	}
+}
+
+// The left stick and WASD drive the same movement axis
+func TestGamepadActions(t *testing.T) {
+	im, fake := newFakeInputManager()
+	fake.ConnectGamepad(glfw.Joystick1, "Pad")
+
+	actions := im.Actions
+	actions.Bind("forward", Key(glfw.KeyW), GamepadAxis(glfw.AxisLeftY, -1))
+	actions.Bind("backward", Key(glfw.KeyS), GamepadAxis(glfw.AxisLeftY, 1))
+	actions.Bind("left", Key(glfw.KeyA), GamepadAxis(glfw.AxisLeftX, -1))
+	actions.Bind("right", Key(glfw.KeyD), GamepadAxis(glfw.AxisLeftX, 1))
+	actions.BindAxis2D("move", "forward", "backward", "left", "right")
+	actions.Bind("jump", Key(glfw.KeySpace), GamepadButton(glfw.ButtonA))
+
+	// Partly pushed up, rescaled past the dead zone
+	fake.SetGamepadAxis(glfw.Joystick1, glfw.AxisLeftY, -0.83)
+	im.Update()
+	if move := actions.Axis2D("move"); !move.ApproxEqualThreshold(mgl32.Vec2{0, 0.8}, 1e-5) {
+		t.Errorf("stick half up moves %v", move)
+	}
+	checkAction(t, actions, "forward", actionFrame{justPressed: true, held: true})
+
+	fake.SetGamepadAxis(glfw.Joystick1, glfw.AxisLeftY, 0)
+	fake.PressKey(glfw.KeyW)
+	im.Update()
+	if move := actions.Axis2D("move"); move != (mgl32.Vec2{0, 1}) {
+		t.Errorf("W moves %v", move)
+	}
+	fake.ReleaseKey(glfw.KeyW)
+
+	fake.SetGamepadButton(glfw.Joystick1, glfw.ButtonA, true)
+	im.Update()
+	checkAction(t, actions, "jump", actionFrame{justPressed: true, held: true})
+
+	// Unplugging releases everything the gamepad held
+	fake.DisconnectGamepad(glfw.Joystick1)
+	im.Update()
+	checkAction(t, actions, "jump", actionFrame{justReleased: true})
+}
+
+// Each player's action map only reads their own gamepad
+func TestGamepadPerPlayerActionMaps(t *testing.T) {
+	im, fake := newFakeInputManager()
+	fake.ConnectGamepad(glfw.Joystick1, "Player one")
+	fake.ConnectGamepad(glfw.Joystick2, "Player two")
+
+	playerOne, playerTwo := im.NewActionMap(), im.NewActionMap()
+	playerOne.Gamepad, playerTwo.Gamepad = glfw.Joystick1, glfw.Joystick2
+	playerOne.Bind("jump", GamepadButton(glfw.ButtonA))
+	playerTwo.Bind("jump", GamepadButton(glfw.ButtonA))
+	im.Actions.Bind("jump", GamepadButton(glfw.ButtonA)) // Reads any gamepad
+
+	fake.SetGamepadButton(glfw.Joystick2, glfw.ButtonA, true)
+	im.Update()
+
+	checkAction(t, playerOne, "jump", actionFrame{})
+	checkAction(t, playerTwo, "jump", actionFrame{justPressed: true, held: true})
+	checkAction(t, im.Actions, "jump", actionFrame{justPressed: true, held: true})
+}
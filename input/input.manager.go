@@ -2,82 +2,318 @@ package input
 
 import (
 	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
 )
 
 type InputManager struct {
-	GlfwWindow         *glfw.Window
+	Source             InputSource
+	Actions            *ActionMap // Default action map, see NewActionMap for more
+	actionMaps         []*ActionMap
+	Gamepads           []*Gamepad // Connected gamepads, in connection order
+	deadZones          [gamepadAxisCount]float32
+	connectHandlers    []func(gamepad *Gamepad)
+	disconnectHandlers []func(gamepad *Gamepad)
 	keyMap             map[glfw.Key]int
 	actionState        map[int]bool
 	actionHandlers     map[int]func()
+	actionOrder        []int // Actions with handlers in registration order, so handlers run in the same order every frame
 	mouseMoveHandler   func(xpos, ypos float64)
 	mouseScrollHandler func(xoffset, yoffset float64)
-	LastX, LastY       float64
-	FirstMouse         bool
+
+	cursorMode CursorMode
+
+	// Cursor position and the motion and scrolling since the last Update, moved
+	// into mouseDelta and scrollDelta by Update.
+	cursorX, cursorY   float64
+	hasCursor          bool // False until the first cursor event after a mode change
+	pendingMouseDelta  mgl32.Vec2
+	pendingScrollDelta mgl32.Vec2
+	mouseDelta         mgl32.Vec2
+	scrollDelta        mgl32.Vec2
+
+	frame     int // Updates since recording or replaying started
+	recording *Recording
+	replay    *Recording
+	replayAt  int // Index of the next replayed event
 }
 
 func NewInputManager(glfwWindow *glfw.Window) *InputManager {
-	glfwWindow.SetInputMode(glfw.CursorMode, glfw.CursorDisabled) // Disable cursor and capture inputs
-	return &InputManager{
-		GlfwWindow:     glfwWindow,
+	return NewInputManagerFromSource(&glfwInputSource{glfwWindow: glfwWindow})
+}
+
+func NewInputManagerFromSource(source InputSource) *InputManager {
+	im := &InputManager{
+		Source:         source,
+		Actions:        NewActionMap(),
 		keyMap:         make(map[glfw.Key]int),
 		actionState:    make(map[int]bool),
 		actionHandlers: make(map[int]func()),
-		FirstMouse:     true,
+	}
+
+	im.SetCursorMode(CursorNormal)
+
+	im.actionMaps = []*ActionMap{im.Actions}
+	for i := range im.deadZones {
+		im.deadZones[i] = DefaultDeadZone
+	}
+
+	source.SetKeyCallback(im.onKey)
+	source.SetMouseButtonCallback(im.onMouseButton)
+	source.SetCursorPosCallback(im.onMouseMove)
+	source.SetScrollCallback(im.onMouseScroll)
+	source.SetJoystickCallback(im.onJoystick)
+
+	for _, joystick := range source.Gamepads() {
+		im.connectGamepad(joystick, source.GamepadName(joystick))
+	}
+
+	return im
+}
+
+// NewActionMap creates another action map fed by the same input, e.g one per
+// player with its Gamepad set to that player's controller.
+func (im *InputManager) NewActionMap() *ActionMap {
+	actionMap := NewActionMap()
+	im.actionMaps = append(im.actionMaps, actionMap)
+	return actionMap
+}
+
+// SetDeadZone sets how far an axis must move from rest before it registers
+func (im *InputManager) SetDeadZone(axis glfw.GamepadAxis, deadZone float32) {
+	if axis >= 0 && int(axis) < gamepadAxisCount {
+		im.deadZones[axis] = deadZone
 	}
 }
 
+func (im *InputManager) Gamepad(joystick glfw.Joystick) (*Gamepad, bool) {
+	for _, gamepad := range im.Gamepads {
+		if gamepad.Joystick == joystick {
+			return gamepad, true
+		}
+	}
+	return nil, false
+}
+
+// OnGamepadConnected is called for gamepads plugged in from now on, ones
+// connected at startup are already in Gamepads.
+func (im *InputManager) OnGamepadConnected(handler func(gamepad *Gamepad)) {
+	im.connectHandlers = append(im.connectHandlers, handler)
+}
+
+func (im *InputManager) OnGamepadDisconnected(handler func(gamepad *Gamepad)) {
+	im.disconnectHandlers = append(im.disconnectHandlers, handler)
+}
+
+// RegisterKeyAction calls handler every frame the key is held. Use Actions for
+// presses, releases, several bindings per action and axes.
 func (im *InputManager) RegisterKeyAction(key glfw.Key, action int, handler func()) {
 	im.keyMap[key] = action
+	if _, ok := im.actionHandlers[action]; !ok {
+		im.actionOrder = append(im.actionOrder, action)
+	}
 	im.actionHandlers[action] = handler
 }
 
+// SetCursorMode shows, hides or captures the cursor, e.g releasing it when the
+// game pauses.
+func (im *InputManager) SetCursorMode(mode CursorMode) {
+	im.cursorMode = mode
+	im.Source.SetCursorMode(mode.glfwMode())
+	im.hasCursor = false
+}
+
+func (im *InputManager) CursorMode() CursorMode {
+	return im.cursorMode
+}
+
+// SetRawMouseMotion asks for unaccelerated mouse motion while the cursor is
+// captured, returning false where the platform doesn't support it.
+func (im *InputManager) SetRawMouseMotion(enabled bool) bool {
+	if enabled && !im.Source.RawMouseMotionSupported() {
+		return false
+	}
+
+	im.Source.SetRawMouseMotion(enabled)
+	return true
+}
+
+// CursorPosition is where the cursor last was, in window coordinates
+func (im *InputManager) CursorPosition() (float64, float64) {
+	return im.cursorX, im.cursorY
+}
+
+// MouseDelta is how far the mouse moved over the last frame, y grows downwards
+func (im *InputManager) MouseDelta() mgl32.Vec2 {
+	return im.mouseDelta
+}
+
+// ScrollDelta is how far the wheel scrolled over the last frame
+func (im *InputManager) ScrollDelta() mgl32.Vec2 {
+	return im.scrollDelta
+}
+
+// RegisterMouseMoveHandler is called with the cursor position on every move,
+// MouseDelta is simpler for mouse look.
 func (im *InputManager) RegisterMouseMoveHandler(handler func(xpos, ypos float64)) {
 	im.mouseMoveHandler = handler
-	im.GlfwWindow.SetCursorPosCallback(im.onMouseMove)
 }
 
 func (im *InputManager) RegisterMouseScrollHandler(handler func(xoffset, yoffset float64)) {
 	im.mouseScrollHandler = handler
-	im.GlfwWindow.SetScrollCallback(im.onMouseScroll) // Set the scroll callback
 }
 
 func (im *InputManager) Update() {
-	im.GlfwWindow.SetKeyCallback(im.onKey)
-	for action, active := range im.actionState {
-		if active && im.actionHandlers[action] != nil {
+	if im.replay != nil {
+		im.replayFrame()
+	} else {
+		for _, gamepad := range im.Gamepads {
+			if state, ok := im.Source.GamepadState(gamepad.Joystick); ok && state != gamepad.state {
+				im.receive(Event{Type: GamepadStateEvent, Code: int(gamepad.Joystick), State: &state})
+			}
+		}
+	}
+
+	for _, gamepad := range im.Gamepads {
+		gamepad.update(im.deadZones)
+	}
+
+	im.mouseDelta, im.pendingMouseDelta = im.pendingMouseDelta, mgl32.Vec2{}
+	im.scrollDelta, im.pendingScrollDelta = im.pendingScrollDelta, mgl32.Vec2{}
+
+	for _, actionMap := range im.actionMaps {
+		actionMap.update(im.Gamepads)
+	}
+
+	for _, action := range im.actionOrder {
+		if im.actionState[action] && im.actionHandlers[action] != nil {
 			im.actionHandlers[action]()
 		}
 	}
+
+	im.frame++
+	if im.replay != nil && im.frame >= im.replay.Frames {
+		im.endReplay()
+	}
+}
+
+func (im *InputManager) onKey(key glfw.Key, action glfw.Action) {
+	im.receive(Event{Type: KeyEvent, Code: int(key), Action: action})
+}
+
+func (im *InputManager) onMouseButton(button glfw.MouseButton, action glfw.Action) {
+	im.receive(Event{Type: MouseButtonEvent, Code: int(button), Action: action})
+}
+
+func (im *InputManager) onMouseMove(xpos, ypos float64) {
+	im.receive(Event{Type: CursorEvent, X: xpos, Y: ypos})
+}
+
+func (im *InputManager) onMouseScroll(xoffset, yoffset float64) {
+	im.receive(Event{Type: ScrollEvent, X: xoffset, Y: yoffset})
+}
+
+func (im *InputManager) onJoystick(joystick glfw.Joystick, connected bool) {
+	if connected {
+		im.receive(Event{Type: GamepadConnectedEvent, Code: int(joystick), Name: im.Source.GamepadName(joystick)})
+	} else {
+		im.receive(Event{Type: GamepadDisconnectedEvent, Code: int(joystick)})
+	}
+}
+
+// receive takes a live event, live input is ignored while replaying
+func (im *InputManager) receive(event Event) {
+	if im.replay != nil {
+		return
+	}
+
+	if im.recording != nil {
+		event.Frame = im.frame
+		im.recording.Events = append(im.recording.Events, event)
+	}
+
+	im.dispatch(event)
 }
 
-func (im *InputManager) onKey(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-	if act, ok := im.keyMap[key]; ok {
-		if action == glfw.Press {
-			im.actionState[act] = true
-		} else if action == glfw.Release {
-			im.actionState[act] = false
+func (im *InputManager) dispatch(event Event) {
+	switch event.Type {
+	case KeyEvent:
+		for _, actionMap := range im.actionMaps {
+			actionMap.handle(Key(glfw.Key(event.Code)), event.Action)
+		}
+
+		if act, ok := im.keyMap[glfw.Key(event.Code)]; ok {
+			if event.Action == glfw.Press {
+				im.actionState[act] = true
+			} else if event.Action == glfw.Release {
+				im.actionState[act] = false
+			}
+		}
+
+	case MouseButtonEvent:
+		for _, actionMap := range im.actionMaps {
+			actionMap.handle(MouseButton(glfw.MouseButton(event.Code)), event.Action)
+		}
+
+	case CursorEvent:
+		// The first position after a mode change can jump, so it isn't motion
+		if im.hasCursor {
+			im.pendingMouseDelta = im.pendingMouseDelta.Add(mgl32.Vec2{float32(event.X - im.cursorX), float32(event.Y - im.cursorY)})
+		}
+		im.cursorX, im.cursorY, im.hasCursor = event.X, event.Y, true
+
+		if im.mouseMoveHandler != nil {
+			im.mouseMoveHandler(event.X, event.Y)
 		}
 
+	case ScrollEvent:
+		im.pendingScrollDelta = im.pendingScrollDelta.Add(mgl32.Vec2{float32(event.X), float32(event.Y)})
+
+		if im.mouseScrollHandler != nil {
+			im.mouseScrollHandler(event.X, event.Y)
+		}
+
+	case GamepadConnectedEvent:
+		if gamepad := im.connectGamepad(glfw.Joystick(event.Code), event.Name); gamepad != nil {
+			for _, handler := range im.connectHandlers {
+				handler(gamepad)
+			}
+		}
+
+	case GamepadDisconnectedEvent:
+		im.disconnectGamepad(glfw.Joystick(event.Code))
+
+	case GamepadStateEvent:
+		if gamepad, ok := im.Gamepad(glfw.Joystick(event.Code)); ok && event.State != nil {
+			gamepad.state = *event.State
+		}
 	}
 }
 
-func (im *InputManager) onMouseMove(w *glfw.Window, xpos, ypos float64) {
-	if im.FirstMouse {
-		im.LastX = xpos
-		im.LastY = ypos
-		im.FirstMouse = false
+// connectGamepad starts tracking a gamepad, returning nil if it already is
+func (im *InputManager) connectGamepad(joystick glfw.Joystick, name string) *Gamepad {
+	if _, ok := im.Gamepad(joystick); ok {
+		return nil
 	}
 
-	if im.mouseMoveHandler != nil {
-		im.mouseMoveHandler(xpos, ypos)
+	gamepad := &Gamepad{
+		Joystick: joystick,
+		Name:     name,
 	}
+	im.Gamepads = append(im.Gamepads, gamepad)
 
-	im.LastX = xpos
-	im.LastY = ypos
+	return gamepad
 }
 
-func (im *InputManager) onMouseScroll(w *glfw.Window, xoffset, yoffset float64) {
-	if im.mouseScrollHandler != nil {
-		im.mouseScrollHandler(xoffset, yoffset)
+func (im *InputManager) disconnectGamepad(joystick glfw.Joystick) {
+	for i, gamepad := range im.Gamepads {
+		if gamepad.Joystick != joystick {
+			continue
+		}
+
+		im.Gamepads = append(im.Gamepads[:i], im.Gamepads[i+1:]...)
+		for _, handler := range im.disconnectHandlers {
+			handler(gamepad)
+		}
+		return
 	}
 }
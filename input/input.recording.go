@@ -0,0 +1,163 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type EventType string
+
+const (
+	KeyEvent                 EventType = "key"
+	MouseButtonEvent         EventType = "mouseButton"
+	CursorEvent              EventType = "cursor"
+	ScrollEvent              EventType = "scroll"
+	GamepadConnectedEvent    EventType = "gamepadConnected"
+	GamepadDisconnectedEvent EventType = "gamepadDisconnected"
+	GamepadStateEvent        EventType = "gamepadState"
+)
+
+// Event is a single input event as the InputManager received it. Code is the
+// key, mouse button or joystick, X and Y the cursor position or scroll offset.
+type Event struct {
+	Frame  int
+	Type   EventType
+	Code   int                `json:",omitempty"`
+	Action glfw.Action        `json:",omitempty"`
+	X, Y   float64            `json:",omitempty"`
+	Name   string             `json:",omitempty"`
+	State  *glfw.GamepadState `json:",omitempty"`
+}
+
+// Recording is every input event over a number of frames, along with each
+// frame's delta time so a replay steps the simulation exactly as the original.
+type Recording struct {
+	Frames     int
+	FrameTimes []float64 `json:",omitempty"`
+	Events     []Event
+}
+
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	recording := &Recording{}
+	if err := json.Unmarshal(data, recording); err != nil {
+		return nil, fmt.Errorf("error parsing recording %s: %w", path, err)
+	}
+
+	return recording, nil
+}
+
+func (recording *Recording) Save(path string) error {
+	data, err := json.Marshal(recording)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// StartRecording records every input event from the next Update on. Replays
+// only match when they start from the same state, so record from startup.
+func (im *InputManager) StartRecording() {
+	im.recording = &Recording{}
+	im.frame = 0
+	im.hasCursor = false
+	im.pendingMouseDelta, im.pendingScrollDelta = mgl32.Vec2{}, mgl32.Vec2{}
+
+	// Gamepads connected before recording started
+	for _, gamepad := range im.Gamepads {
+		state := gamepad.state
+		im.recording.Events = append(im.recording.Events,
+			Event{Type: GamepadConnectedEvent, Code: int(gamepad.Joystick), Name: gamepad.Name},
+			Event{Type: GamepadStateEvent, Code: int(gamepad.Joystick), State: &state},
+		)
+	}
+}
+
+// StopRecording returns everything recorded since StartRecording, or nil if
+// nothing was being recorded.
+func (im *InputManager) StopRecording() *Recording {
+	recording := im.recording
+	if recording != nil {
+		recording.Frames = im.frame
+	}
+
+	im.recording = nil
+	return recording
+}
+
+func (im *InputManager) Recording() bool {
+	return im.recording != nil
+}
+
+// Replay feeds a recording's events in on the frames they were recorded on,
+// ignoring live input until it runs out.
+func (im *InputManager) Replay(recording *Recording) {
+	im.replay = nil
+	if recording == nil || recording.Frames == 0 {
+		return
+	}
+
+	im.replay = recording
+	im.replayAt = 0
+	im.frame = 0
+	im.hasCursor = false
+	im.pendingMouseDelta, im.pendingScrollDelta = mgl32.Vec2{}, mgl32.Vec2{}
+
+	im.Gamepads = nil
+	clear(im.actionState)
+	for _, actionMap := range im.actionMaps {
+		actionMap.Reset()
+	}
+}
+
+func (im *InputManager) Replaying() bool {
+	return im.replay != nil
+}
+
+// RecordFrameTime stores the delta time of the frame about to update, call it
+// once a frame before Update.
+func (im *InputManager) RecordFrameTime(deltaTime float64) {
+	if im.recording != nil {
+		im.recording.FrameTimes = append(im.recording.FrameTimes, deltaTime)
+	}
+}
+
+// ReplayFrameTime is the recorded delta time of the frame about to update
+func (im *InputManager) ReplayFrameTime() (float64, bool) {
+	if im.replay == nil || im.frame >= len(im.replay.FrameTimes) {
+		return 0, false
+	}
+	return im.replay.FrameTimes[im.frame], true
+}
+
+// endReplay goes back to live input, taking the real gamepads and releasing
+// whatever the recording left held.
+func (im *InputManager) endReplay() {
+	im.replay = nil
+
+	im.Gamepads = nil
+	for _, joystick := range im.Source.Gamepads() {
+		im.connectGamepad(joystick, im.Source.GamepadName(joystick))
+	}
+
+	clear(im.actionState)
+	for _, actionMap := range im.actionMaps {
+		actionMap.Reset()
+	}
+}
+
+func (im *InputManager) replayFrame() {
+	for im.replayAt < len(im.replay.Events) && im.replay.Events[im.replayAt].Frame <= im.frame {
+		im.dispatch(im.replay.Events[im.replayAt])
+		im.replayAt++
+	}
+}
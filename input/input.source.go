@@ -0,0 +1,111 @@
+package input
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// InputSource delivers raw key and mouse events to the InputManager, either
+// from a GLFW window or from a FakeInput when running headless.
+type InputSource interface {
+	SetKeyCallback(callback func(key glfw.Key, action glfw.Action))
+	SetMouseButtonCallback(callback func(button glfw.MouseButton, action glfw.Action))
+	SetCursorPosCallback(callback func(xpos, ypos float64))
+	SetScrollCallback(callback func(xoffset, yoffset float64))
+	SetCursorMode(mode int)
+	RawMouseMotionSupported() bool
+	SetRawMouseMotion(enabled bool)
+
+	// Gamepads are polled rather than evented, apart from hot plugging
+	SetJoystickCallback(callback func(joystick glfw.Joystick, connected bool))
+	Gamepads() []glfw.Joystick
+	GamepadName(joystick glfw.Joystick) string
+	GamepadState(joystick glfw.Joystick) (glfw.GamepadState, bool)
+}
+
+type glfwInputSource struct {
+	glfwWindow *glfw.Window
+}
+
+func (source *glfwInputSource) SetKeyCallback(callback func(key glfw.Key, action glfw.Action)) {
+	source.glfwWindow.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		callback(key, action)
+	})
+}
+
+func (source *glfwInputSource) SetMouseButtonCallback(callback func(button glfw.MouseButton, action glfw.Action)) {
+	source.glfwWindow.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		callback(button, action)
+	})
+}
+
+func (source *glfwInputSource) SetCursorPosCallback(callback func(xpos, ypos float64)) {
+	source.glfwWindow.SetCursorPosCallback(func(w *glfw.Window, xpos, ypos float64) {
+		callback(xpos, ypos)
+	})
+}
+
+func (source *glfwInputSource) SetScrollCallback(callback func(xoffset, yoffset float64)) {
+	source.glfwWindow.SetScrollCallback(func(w *glfw.Window, xoffset, yoffset float64) {
+		callback(xoffset, yoffset)
+	})
+}
+
+func (source *glfwInputSource) SetCursorMode(mode int) {
+	source.glfwWindow.SetInputMode(glfw.CursorMode, mode)
+}
+
+func (source *glfwInputSource) RawMouseMotionSupported() bool {
+	return glfw.RawMouseMotionSupported()
+}
+
+func (source *glfwInputSource) SetRawMouseMotion(enabled bool) {
+	value := glfw.False
+	if enabled {
+		value = glfw.True
+	}
+	source.glfwWindow.SetInputMode(glfw.RawMouseMotion, value)
+}
+
+// SetJoystickCallback reports gamepads being connected, joysticks without a
+// gamepad mapping are ignored.
+func (source *glfwInputSource) SetJoystickCallback(callback func(joystick glfw.Joystick, connected bool)) {
+	glfw.SetJoystickCallback(func(joystick glfw.Joystick, event glfw.PeripheralEvent) {
+		if event == glfw.Connected && !joystick.IsGamepad() {
+			return
+		}
+		callback(joystick, event == glfw.Connected)
+	})
+}
+
+func (source *glfwInputSource) Gamepads() []glfw.Joystick {
+	var gamepads []glfw.Joystick
+	for joystick := glfw.Joystick1; joystick <= glfw.JoystickLast; joystick++ {
+		if joystick.Present() && joystick.IsGamepad() {
+			gamepads = append(gamepads, joystick)
+		}
+	}
+	return gamepads
+}
+
+func (source *glfwInputSource) GamepadName(joystick glfw.Joystick) string {
+	return joystick.GetGamepadName()
+}
+
+func (source *glfwInputSource) GamepadState(joystick glfw.Joystick) (glfw.GamepadState, bool) {
+	state := joystick.GetGamepadState()
+	if state == nil {
+		return glfw.GamepadState{}, false
+	}
+	return *state, true
+}
+
+// UpdateGamepadMappings adds SDL_GameControllerDB style mappings on top of the
+// ones built into GLFW, for controllers it doesn't know.
+func UpdateGamepadMappings(mappings string) error {
+	if !glfw.UpdateGamepadMappings(mappings) {
+		return fmt.Errorf("invalid gamepad mappings")
+	}
+	return nil
+}
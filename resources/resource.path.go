@@ -0,0 +1,61 @@
+package resources
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Asset paths (shaders, textures, models, scenes) are written relative to the
+// project root, e.g "assets/textures/wall.jpg". Path resolves them against the
+// configured root so games don't depend on the working directory.
+
+var root = struct {
+	sync.RWMutex
+	dir string
+}{}
+
+// SetRoot sets the directory relative asset paths are resolved against. An
+// empty dir searches upwards from the working directory with FindRoot.
+func SetRoot(dir string) {
+	if dir == "" {
+		dir = FindRoot()
+	}
+
+	root.Lock()
+	defer root.Unlock()
+	root.dir = dir
+}
+
+func Root() string {
+	root.RLock()
+	defer root.RUnlock()
+	return root.dir
+}
+
+// Path resolves an asset path, absolute paths are returned unchanged
+func Path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(Root(), path)
+}
+
+// FindRoot returns the closest directory at or above the working directory
+// that contains an "assets" directory, or the working directory if none do.
+func FindRoot() string {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for dir := workingDir; ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(filepath.Join(dir, "assets")); err == nil && info.IsDir() {
+			return dir
+		}
+
+		if filepath.Dir(dir) == dir {
+			return workingDir
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package scene
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Engine components, game components are registered by the game with Register
+func init() {
+	Register("Transform", func() *components.TransformComponent {
+		return components.NewTransformComponent(mgl32.Vec3{})
+	})
+	Register[components.PhysicsComponent]("Physics", nil)
+	Register[components.BoxColliderComponent]("BoxCollider", nil)
+	RegisterCodec("Model", encodeModel, decodeModel)
+	RegisterCodec("Renderable", encodeRenderable, decodeRenderable)
+	Register[components.CameraComponent]("Camera", nil)
+
+	Register[components.AmbientLightComponent]("AmbientLight", nil)
+	Register("DirectionalLight", func() *components.DirectionalLightComponent {
+		return &components.DirectionalLightComponent{Shadow: components.DefaultShadowSettings()}
+	})
+	Register("PointLight", func() *components.PointLightComponent {
+		return &components.PointLightComponent{Shadow: components.DefaultPointShadowSettings()}
+	})
+	Register("SpotLight", func() *components.SpotLightComponent {
+		return &components.SpotLightComponent{Shadow: components.DefaultShadowSettings()}
+	})
+}
+
+// modelData is how a model is stored, meshes are rebuilt from the source and
+// materials override the ones the source comes with.
+type modelData struct {
+	Source    components.ModelSource
+	Materials []*components.MaterialComponent `json:",omitempty"`
+}
+
+func encodeModel(modelComponent *components.ModelComponent) (any, error) {
+	if modelComponent.Source == (components.ModelSource{}) {
+		return nil, fmt.Errorf("model has no source to save")
+	}
+
+	return modelData{
+		Source:    modelComponent.Source,
+		Materials: modelComponent.MaterialComponents,
+	}, nil
+}
+
+func decodeModel(data json.RawMessage) (*components.ModelComponent, error) {
+	var model modelData
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, err
+	}
+
+	modelComponent, err := entities.NewModelFromSource(model.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(model.Materials) > 0 {
+		if len(model.Materials) != len(modelComponent.MeshComponents) {
+			return nil, fmt.Errorf("model has %d meshes but %d materials", len(modelComponent.MeshComponents), len(model.Materials))
+		}
+		modelComponent.MaterialComponents = model.Materials
+	}
+
+	return modelComponent, nil
+}
+
+// renderableData is how a renderable is stored, its transform and model are
+// the entity's own and are filled in once they are loaded.
+type renderableData struct {
+	CastShadows    bool
+	ReceiveShadows bool
+}
+
+func encodeRenderable(renderableComponent *components.RenderableComponent) (any, error) {
+	return renderableData{
+		CastShadows:    renderableComponent.CastShadows,
+		ReceiveShadows: renderableComponent.ReceiveShadows,
+	}, nil
+}
+
+func decodeRenderable(data json.RawMessage) (*components.RenderableComponent, error) {
+	renderableComponent := components.NewRenderableComponent(nil, nil)
+
+	// Flags missing from the file keep NewRenderableComponent's defaults
+	flags := renderableData{CastShadows: renderableComponent.CastShadows, ReceiveShadows: renderableComponent.ReceiveShadows}
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, err
+	}
+
+	renderableComponent.CastShadows, renderableComponent.ReceiveShadows = flags.CastShadows, flags.ReceiveShadows
+	return renderableComponent, nil
+}
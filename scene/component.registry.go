@@ -0,0 +1,103 @@
+package scene
+
+import (
+	"0xKowalski/game/entities"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// componentCodec reads and writes one component type under its scene name
+type componentCodec struct {
+	name   string
+	id     entities.ComponentID
+	encode func(store *entities.EntityStore, entity entities.Entity) (json.RawMessage, bool, error)
+	decode func(store *entities.EntityStore, entity entities.Entity, data json.RawMessage) error
+}
+
+var componentRegistry = struct {
+	sync.RWMutex
+	byName map[string]*componentCodec
+	byID   map[entities.ComponentID]*componentCodec
+
+	// Registration order, components are added to loaded entities in this order
+	codecs []*componentCodec
+}{
+	byName: make(map[string]*componentCodec),
+	byID:   make(map[entities.ComponentID]*componentCodec),
+}
+
+// Register makes components of type T serializable under name, using
+// encoding/json on the component itself. newDefault, when not nil, provides the
+// starting value so fields missing from a scene file keep sensible defaults.
+func Register[T any](name string, newDefault func() *T) {
+	RegisterCodec(name,
+		func(component *T) (any, error) {
+			return component, nil
+		},
+		func(data json.RawMessage) (*T, error) {
+			component := new(T)
+			if newDefault != nil {
+				component = newDefault()
+			}
+
+			if err := json.Unmarshal(data, component); err != nil {
+				return nil, err
+			}
+			return component, nil
+		})
+}
+
+// RegisterCodec makes components of type T serializable under name, for
+// components that hold data which can't be written as is (GPU handles, meshes).
+// encode returns the value written to the file, decode builds the component back.
+func RegisterCodec[T any](name string, encode func(*T) (any, error), decode func(json.RawMessage) (*T, error)) {
+	codec := &componentCodec{
+		name: name,
+		id:   entities.ComponentIDOf[T](),
+		encode: func(store *entities.EntityStore, entity entities.Entity) (json.RawMessage, bool, error) {
+			component, ok := entities.Get[T](store, entity)
+			if !ok {
+				return nil, false, nil
+			}
+
+			value, err := encode(component)
+			if err != nil {
+				return nil, true, err
+			}
+
+			data, err := json.Marshal(value)
+			return data, true, err
+		},
+		decode: func(store *entities.EntityStore, entity entities.Entity, data json.RawMessage) error {
+			component, err := decode(data)
+			if err != nil {
+				return err
+			}
+
+			entities.Add(store, entity, component)
+			return nil
+		},
+	}
+
+	componentRegistry.Lock()
+	defer componentRegistry.Unlock()
+
+	if _, ok := componentRegistry.byName[name]; ok {
+		panic(fmt.Sprintf("scene component %q is already registered", name))
+	}
+	if existing, ok := componentRegistry.byID[codec.id]; ok {
+		panic(fmt.Sprintf("scene component %q is already registered as %q", name, existing.name))
+	}
+
+	componentRegistry.byName[name] = codec
+	componentRegistry.byID[codec.id] = codec
+	componentRegistry.codecs = append(componentRegistry.codecs, codec)
+}
+
+func registeredCodecs() []*componentCodec {
+	componentRegistry.RLock()
+	defer componentRegistry.RUnlock()
+
+	return append([]*componentCodec(nil), componentRegistry.codecs...)
+}
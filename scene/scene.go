@@ -0,0 +1,211 @@
+package scene
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"0xKowalski/game/resources"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Scene is the on disk format, a list of entities with their components keyed
+// by registered name. Files are JSON, or YAML when the path ends in .yaml or
+// .yml. YAML is converted to and from JSON, so component codecs only see JSON.
+type Scene struct {
+	Entities []EntityData `json:"entities"`
+}
+
+type EntityData struct {
+	Parent     *int                       `json:"parent,omitempty"` // Index into Scene.Entities
+	Components map[string]json.RawMessage `json:"components"`
+}
+
+// Load reads a scene file and spawns its entities into the store, returning
+// them in file order. Relative paths are resolved against the asset root.
+func Load(store *entities.EntityStore, path string) ([]entities.Entity, error) {
+	data, err := os.ReadFile(resources.Path(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if isYAML(path) {
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing scene %s: %w", path, err)
+		}
+	}
+
+	var scene Scene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		return nil, fmt.Errorf("error parsing scene %s: %w", path, err)
+	}
+
+	spawned, err := scene.Spawn(store)
+	if err != nil {
+		return nil, fmt.Errorf("error loading scene %s: %w", path, err)
+	}
+
+	return spawned, nil
+}
+
+// Save writes every entity in the store that has a registered component to path
+func Save(store *entities.EntityStore, path string) error {
+	scene, err := Encode(store)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(scene, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if isYAML(path) {
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(resources.Path(path), data, 0644)
+}
+
+func isYAML(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".yaml" || extension == ".yml"
+}
+
+// Encode captures the store's registered components. Unregistered components
+// are skipped, as are entities left with nothing to save and no hierarchy.
+func Encode(store *entities.EntityStore) (*Scene, error) {
+	codecs := registeredCodecs()
+	scene := &Scene{}
+
+	indices := make(map[entities.Entity]int)
+	parents := make(map[int]entities.Entity)
+
+	for _, entity := range store.ActiveEntities() {
+		entityData := EntityData{Components: make(map[string]json.RawMessage)}
+
+		for _, codec := range codecs {
+			data, ok, err := codec.encode(store, entity)
+			if err != nil {
+				return nil, fmt.Errorf("error saving %s of %v: %w", codec.name, entity, err)
+			}
+			if ok {
+				entityData.Components[codec.name] = data
+			}
+		}
+
+		parent, hasParent := store.GetParent(entity)
+		hasChildren := len(store.GetChildren(entity)) > 0
+		if len(entityData.Components) == 0 && !hasParent && !hasChildren {
+			continue
+		}
+
+		indices[entity] = len(scene.Entities)
+		if hasParent {
+			parents[len(scene.Entities)] = parent
+		}
+		scene.Entities = append(scene.Entities, entityData)
+	}
+
+	for child, parent := range parents {
+		index := indices[parent]
+		scene.Entities[child].Parent = &index
+	}
+
+	return scene, nil
+}
+
+// Spawn creates the scene's entities in the store. Nothing is left behind in
+// the store if any entity fails to load.
+func (scene *Scene) Spawn(store *entities.EntityStore) ([]entities.Entity, error) {
+	if err := scene.validate(); err != nil {
+		return nil, err
+	}
+
+	spawned := make([]entities.Entity, len(scene.Entities))
+	for i := range spawned {
+		spawned[i] = store.NewEntity()
+	}
+
+	if err := scene.spawnComponents(store, spawned); err != nil {
+		for _, entity := range spawned {
+			store.FreeEntity(entity)
+		}
+		return nil, err
+	}
+
+	return spawned, nil
+}
+
+func (scene *Scene) spawnComponents(store *entities.EntityStore, spawned []entities.Entity) error {
+	codecs := registeredCodecs()
+
+	for i, entityData := range scene.Entities {
+		entity := spawned[i]
+
+		for _, codec := range codecs {
+			data, ok := entityData.Components[codec.name]
+			if !ok {
+				continue
+			}
+
+			if err := codec.decode(store, entity, data); err != nil {
+				return fmt.Errorf("error loading %s of entity %d: %w", codec.name, i, err)
+			}
+		}
+
+		// Only the renderable's flags are saved, it draws the entity's own transform and model
+		transformComponent, hasTransform := entities.Get[components.TransformComponent](store, entity)
+		modelComponent, hasModel := entities.Get[components.ModelComponent](store, entity)
+		renderableComponent, hasRenderable := entities.Get[components.RenderableComponent](store, entity)
+		switch {
+		case hasRenderable && (!hasTransform || !hasModel):
+			return fmt.Errorf("entity %d is renderable without a transform and model", i)
+		case hasRenderable:
+			renderableComponent.TransformComponent = transformComponent
+			renderableComponent.ModelComponent = modelComponent
+		case hasTransform && hasModel:
+			entities.Add(store, entity, components.NewRenderableComponent(transformComponent, modelComponent))
+		}
+	}
+
+	for i, entityData := range scene.Entities {
+		if entityData.Parent == nil {
+			continue
+		}
+
+		if err := store.SetParent(spawned[i], spawned[*entityData.Parent], false); err != nil {
+			return fmt.Errorf("error parenting entity %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// validate catches unknown components and bad parent indices before anything is spawned
+func (scene *Scene) validate() error {
+	componentRegistry.RLock()
+	defer componentRegistry.RUnlock()
+
+	for i, entityData := range scene.Entities {
+		for name := range entityData.Components {
+			if _, ok := componentRegistry.byName[name]; !ok {
+				return fmt.Errorf("entity %d has unknown component %q", i, name)
+			}
+		}
+
+		if entityData.Parent != nil && (*entityData.Parent < 0 || *entityData.Parent >= len(scene.Entities)) {
+			return fmt.Errorf("entity %d has parent %d, out of range", i, *entityData.Parent)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,384 @@
+package scene
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"0xKowalski/game/graphics"
+	"0xKowalski/game/resources"
+	"0xKowalski/game/systems"
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// testHealth is a game component, registered the way a game would
+type testHealth struct {
+	Current, Max int
+	Regenerates  bool
+}
+
+func init() {
+	Register("TestHealth", func() *testHealth {
+		return &testHealth{Max: 100}
+	})
+}
+
+// writeTestModel writes a one triangle obj/mtl pair, returning their paths
+func writeTestModel(t *testing.T) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	objPath, mtlPath := filepath.Join(dir, "triangle.obj"), filepath.Join(dir, "triangle.mtl")
+
+	obj := "mtllib triangle.mtl\nv 0 0 0\nv 1 0 0\nv 0 1 0\nvt 0 0\nvt 1 0\nvt 0 1\nvn 0 0 1\nusemtl red\nf 1/1/1 2/2/1 3/3/1\n"
+	mtl := "newmtl red\nKd 1 0 0\nNs 16\nmap_Kd red.png\nmap_Ks red_specular.png\n"
+	if err := os.WriteFile(objPath, []byte(obj), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mtlPath, []byte(mtl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return objPath, mtlPath
+}
+
+// roundTrip saves the store to a file and loads it into a new store
+func roundTrip(t *testing.T, store *entities.EntityStore) (*entities.EntityStore, []entities.Entity) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "scene.json")
+	if err := Save(store, path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := entities.NewEntityStore()
+	spawned, err := Load(loaded, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded, spawned
+}
+
+// checkSame fails unless the entity has an equal T in both stores
+func checkSame[T any](t *testing.T, name string, store *entities.EntityStore, entity entities.Entity, loaded *entities.EntityStore, loadedEntity entities.Entity) {
+	t.Helper()
+
+	want, _ := entities.Get[T](store, entity)
+	got, ok := entities.Get[T](loaded, loadedEntity)
+	if !ok {
+		t.Errorf("%s not loaded", name)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s loaded as %+v, want %+v", name, got, want)
+	}
+}
+
+func TestSceneRoundTrip(t *testing.T) {
+	objPath, mtlPath := writeTestModel(t)
+	store := entities.NewEntityStore()
+
+	body := store.NewEntity()
+	transform := components.NewTransformComponent(mgl32.Vec3{1, 2, 3})
+	transform.Rotation = mgl32.QuatRotate(0.5, mgl32.Vec3{0, 1, 0})
+	transform.Scale = mgl32.Vec3{2, 1, 0.5}
+	entities.Add(store, body, transform)
+	entities.Add(store, body, components.NewPhysicsComponent(mgl32.Vec3{0, 1, 0}, mgl32.Vec3{0, -9.8, 0}, mgl32.Vec3{0.1, 0, 0}, mgl32.Vec3{1, 1, 1}, 2, false))
+	entities.Add(store, body, components.NewBoxColliderComponent(mgl32.Vec3{0, 0.5, 0}, mgl32.Vec3{1, 2, 1}, 0.4, 0.2))
+	entities.Add(store, body, &testHealth{Current: 40, Max: 50, Regenerates: true})
+
+	// A loaded model whose material was changed after loading
+	model, err := components.LoadModelComponent(objPath, mtlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.MaterialComponents[0] = components.NewMaterialComponent("assets/textures/wall.jpg", "", 64)
+	child := store.NewEntity()
+	entities.Add(store, child, components.NewTransformComponent(mgl32.Vec3{0, 1, 0}))
+	entities.Add(store, child, model)
+	if err := store.SetParent(child, body, false); err != nil {
+		t.Fatal(err)
+	}
+
+	lights := store.NewEntity()
+	entities.Add(store, lights, components.NewAmbientLightComponent(mgl32.Vec3{0.2, 0.3, 0.4}, 0.1))
+	directional := components.NewDirectionalLightComponent(mgl32.Vec3{-0.2, -1, -0.3}, mgl32.Vec3{1, 1, 0.9}, 0.8)
+	directional.CastShadows = true
+	directional.Shadow.Resolution = 1024
+	entities.Add(store, lights, directional)
+
+	point := store.NewEntity()
+	pointLight := components.NewPointLightComponent(mgl32.Vec3{0, 3, 0}, mgl32.Vec3{1, 0.8, 0.7}, 2, 1, 0.09, 0.032)
+	pointLight.CastShadows = true
+	entities.Add(store, point, pointLight)
+
+	spot := store.NewEntity()
+	entities.Add(store, spot, components.NewSpotLightComponent(mgl32.Vec3{0, 0, 5}, mgl32.Vec3{1, 1, 1}, mgl32.Vec3{0, 0, -1}, 0.97, 0.95, 1, 1, 0.09, 0.032))
+
+	// Nothing registered, so not saved
+	store.NewEntity()
+
+	loaded, spawned := roundTrip(t, store)
+	if len(spawned) != 5 {
+		t.Fatalf("loaded %d entities, want 5", len(spawned))
+	}
+	loadedBody, loadedChild, loadedLights, loadedPoint, loadedSpot := spawned[0], spawned[1], spawned[2], spawned[3], spawned[4]
+
+	loadedTransform, _ := entities.Get[components.TransformComponent](loaded, loadedBody)
+	if loadedTransform.Position != transform.Position || loadedTransform.Rotation != transform.Rotation || loadedTransform.Scale != transform.Scale {
+		t.Errorf("transform loaded as %v %v %v", loadedTransform.Position, loadedTransform.Rotation, loadedTransform.Scale)
+	}
+	checkSame[components.PhysicsComponent](t, "physics", store, body, loaded, loadedBody)
+	checkSame[components.BoxColliderComponent](t, "box collider", store, body, loaded, loadedBody)
+	checkSame[testHealth](t, "custom component", store, body, loaded, loadedBody)
+
+	if parent, ok := loaded.GetParent(loadedChild); !ok || parent != loadedBody {
+		t.Errorf("child's parent %v, %v", parent, ok)
+	}
+	childTransform, _ := entities.Get[components.TransformComponent](loaded, loadedChild)
+	if position := childTransform.GetWorldPosition(); !position.ApproxEqual(transform.GetWorldMatrix().Mul4x1(mgl32.Vec4{0, 1, 0, 1}).Vec3()) {
+		t.Errorf("child's world position %v", position)
+	}
+
+	loadedModel, ok := entities.Get[components.ModelComponent](loaded, loadedChild)
+	if !ok {
+		t.Fatal("model not loaded")
+	}
+	if loadedModel.Source != model.Source || len(loadedModel.MeshComponents) != 1 {
+		t.Errorf("model loaded from %+v with %d meshes", loadedModel.Source, len(loadedModel.MeshComponents))
+	}
+	if !reflect.DeepEqual(loadedModel.MeshComponents[0].Vertices, model.MeshComponents[0].Vertices) {
+		t.Error("model's vertices differ")
+	}
+	if !reflect.DeepEqual(loadedModel.MaterialComponents, model.MaterialComponents) {
+		t.Errorf("materials loaded as %+v, want %+v", loadedModel.MaterialComponents[0], model.MaterialComponents[0])
+	}
+	if renderable, ok := entities.Get[components.RenderableComponent](loaded, loadedChild); !ok || renderable.ModelComponent != loadedModel || renderable.TransformComponent != childTransform {
+		t.Error("model loaded without a renderable drawing it")
+	}
+
+	checkSame[components.AmbientLightComponent](t, "ambient light", store, lights, loaded, loadedLights)
+	checkSame[components.DirectionalLightComponent](t, "directional light", store, lights, loaded, loadedLights)
+	checkSame[components.PointLightComponent](t, "point light", store, point, loaded, loadedPoint)
+	checkSame[components.SpotLightComponent](t, "spot light", store, spot, loaded, loadedSpot)
+}
+
+// Fields missing from a file keep the registered defaults
+func TestSceneDefaults(t *testing.T) {
+	scene := &Scene{Entities: []EntityData{{Components: map[string]json.RawMessage{
+		"Transform":  json.RawMessage(`{"Position": [1, 2, 3]}`),
+		"TestHealth": json.RawMessage(`{"Current": 5}`),
+	}}}}
+
+	store := entities.NewEntityStore()
+	spawned, err := scene.Spawn(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transform, _ := entities.Get[components.TransformComponent](store, spawned[0])
+	if *transform != *components.NewTransformComponent(mgl32.Vec3{1, 2, 3}) {
+		t.Errorf("transform loaded as %+v", transform)
+	}
+	if health, _ := entities.Get[testHealth](store, spawned[0]); *health != (testHealth{Current: 5, Max: 100}) {
+		t.Errorf("custom component loaded as %+v", health)
+	}
+}
+
+// Renderables keep their shadow flags, and draw the loaded transform and model
+func TestSceneRenderableFlags(t *testing.T) {
+	store := entities.NewEntityStore()
+	caster := store.NewCubeEntity(mgl32.Vec3{}, 1)
+	ghost := store.NewCubeEntity(mgl32.Vec3{2, 0, 0}, 1)
+	ghostRenderable, _ := entities.Get[components.RenderableComponent](store, *ghost)
+	ghostRenderable.CastShadows = false
+	ghostRenderable.ReceiveShadows = false
+
+	loaded, spawned := roundTrip(t, store)
+	for i, entity := range []entities.Entity{*caster, *ghost} {
+		checkSame[components.RenderableComponent](t, "renderable", store, entity, loaded, spawned[i])
+
+		renderable, _ := entities.Get[components.RenderableComponent](loaded, spawned[i])
+		transform, _ := entities.Get[components.TransformComponent](loaded, spawned[i])
+		model, _ := entities.Get[components.ModelComponent](loaded, spawned[i])
+		if renderable.TransformComponent != transform || renderable.ModelComponent != model {
+			t.Error("renderable doesn't draw the loaded transform and model")
+		}
+	}
+
+	// Files without the flags cast and receive shadows
+	scene := &Scene{Entities: []EntityData{{Components: map[string]json.RawMessage{
+		"Transform":  json.RawMessage(`{}`),
+		"Model":      json.RawMessage(`{"Source": {"Primitive": "cube", "Size": 1}}`),
+		"Renderable": json.RawMessage(`{"ReceiveShadows": false}`),
+	}}}}
+	spawned, err := scene.Spawn(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renderable, _ := entities.Get[components.RenderableComponent](loaded, spawned[0]); !renderable.CastShadows || renderable.ReceiveShadows {
+		t.Errorf("renderable loaded with cast %v and receive %v", renderable.CastShadows, renderable.ReceiveShadows)
+	}
+
+	// Without a model there is nothing to draw
+	delete(scene.Entities[0].Components, "Model")
+	if _, err := scene.Spawn(loaded); err == nil {
+		t.Error("loaded a renderable without a model")
+	}
+}
+
+func TestSceneYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scene.yaml")
+	yamlScene := `
+entities:
+  - components:
+      Transform: { Position: [0, 2, 0] }
+      Model: { Source: { Primitive: sphere, Size: 1, Segments: 8, Rings: 8 } }
+      Renderable: { CastShadows: false }
+  - parent: 0
+    components:
+      PointLight: { Color: [1, 0.5, 0], Intensity: 2, Constant: 1, Linear: 0.09, Quadratic: 0.032 }
+`
+	if err := os.WriteFile(path, []byte(yamlScene), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := entities.NewEntityStore()
+	spawned, err := Load(store, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spawned) != 2 {
+		t.Fatalf("loaded %d entities, want 2", len(spawned))
+	}
+	if renderable, ok := entities.Get[components.RenderableComponent](store, spawned[0]); !ok || renderable.CastShadows || renderable.TransformComponent.Position != (mgl32.Vec3{0, 2, 0}) {
+		t.Errorf("renderable loaded as %+v", renderable)
+	}
+	if light, ok := entities.Get[components.PointLightComponent](store, spawned[1]); !ok || light.Color != (mgl32.Vec3{1, 0.5, 0}) || light.Shadow != components.DefaultPointShadowSettings() {
+		t.Errorf("point light loaded as %+v", light)
+	}
+	if parent, _ := store.GetParent(spawned[1]); parent != spawned[0] {
+		t.Error("parent not loaded")
+	}
+
+	// Saved as YAML, loading the same entities back
+	if err := Save(store, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json.Valid(data) || !bytes.Contains(data, []byte("entities:")) {
+		t.Errorf("saved as\n%s\nwant YAML", data)
+	}
+
+	loaded := entities.NewEntityStore()
+	reloaded, err := Load(loaded, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSame[components.RenderableComponent](t, "renderable", store, spawned[0], loaded, reloaded[0])
+	checkSame[components.PointLightComponent](t, "point light", store, spawned[1], loaded, reloaded[1])
+}
+
+// newLightingCamera adds the lighting example's camera
+func newLightingCamera(store *entities.EntityStore) *entities.Freecam {
+	freeCam := store.NewFreecamEntity(mgl32.Vec3{0, 0, 5})
+	freeCam.CameraComponent.AspectRatio = 4.0 / 3.0
+	return freeCam
+}
+
+// buildLightingScene builds the lighting example's scene the way it was built
+// before it was moved to examples/lighting/scene.json
+func buildLightingScene(store *entities.EntityStore) {
+	freeCam := newLightingCamera(store)
+
+	store.NewCubeEntity(mgl32.Vec3{-1.0, -1.0, -2.0}, 1)
+
+	ambientLightEntity := store.NewEntity()
+	store.AddComponent(ambientLightEntity, components.NewAmbientLightComponent(mgl32.Vec3{1.0, 1.0, 1.0}, 0.1))
+
+	directionalLightEntity := store.NewEntity()
+	store.AddComponent(directionalLightEntity, components.NewDirectionalLightComponent(mgl32.Vec3{-0.2, -1.0, -0.3}, mgl32.Vec3{1.0, 1.0, 1.0}, 1))
+
+	pointLightEntity := store.NewEntity()
+	store.AddComponent(pointLightEntity, components.NewPointLightComponent(mgl32.Vec3{0.0, 0.0, 0.0}, mgl32.Vec3{1.0, 0.8, 0.7}, 1.0, 1.0, 0.09, 0.032))
+
+	spotLightEntity := store.NewEntity()
+	spotLightComponent := components.NewSpotLightComponent(freeCam.TransformComponent.Position, mgl32.Vec3{1.0, 1.0, 1.0}, freeCam.CameraComponent.Front, float32(math.Cos(float64(mgl32.DegToRad(12.5)))), float32(math.Cos(float64(mgl32.DegToRad(17.5)))), 1.0, 1.0, 0.09, 0.032)
+	store.AddComponent(spotLightEntity, spotLightComponent)
+}
+
+func loadLightingScene(t *testing.T, store *entities.EntityStore) {
+	t.Helper()
+
+	newLightingCamera(store)
+	if _, err := Load(store, "examples/lighting/scene.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// drawRecorded renders a frame of the store with the render system over a
+// recording device
+func drawRecorded(t *testing.T, build func(store *entities.EntityStore)) *graphics.RecordingDevice {
+	t.Helper()
+
+	store := entities.NewEntityStore()
+	device := graphics.NewRecordingDevice()
+	device.SetViewport(0, 0, 320, 240)
+	rs, err := systems.NewRenderSystem(device, store, "assets/shaders/vertex.glsl", "assets/shaders/fragment.glsl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build(store)
+	rs.Update(0)
+
+	if len(device.Errors) > 0 {
+		t.Fatalf("device errors %v", device.Errors)
+	}
+	return device
+}
+
+// drawSoftware renders a frame of the store with the software renderer
+func drawSoftware(build func(store *entities.EntityStore)) []byte {
+	store := entities.NewEntityStore()
+	build(store)
+
+	sr := systems.NewSoftwareRenderer(store, 320, 240)
+	sr.Update(0)
+	return sr.Image.Pix
+}
+
+// The lighting example's scene file draws the same frame as the scene it replaced
+func TestLightingSceneMatchesBuiltScene(t *testing.T) {
+	resources.SetRoot("")
+	load := func(store *entities.EntityStore) { loadLightingScene(t, store) }
+
+	built, loaded := drawRecorded(t, buildLightingScene), drawRecorded(t, load)
+	if len(built.Draws) == 0 {
+		t.Fatal("nothing drawn")
+	}
+	if !reflect.DeepEqual(loaded.Calls, built.Calls) {
+		t.Errorf("loaded scene made %d calls, the built scene %d", len(loaded.Calls), len(built.Calls))
+		for i := range min(len(loaded.Calls), len(built.Calls)) {
+			if !reflect.DeepEqual(loaded.Calls[i], built.Calls[i]) {
+				t.Fatalf("call %d is %v, want %v", i, loaded.Calls[i], built.Calls[i])
+			}
+		}
+	}
+	if !reflect.DeepEqual(loaded.Draws, built.Draws) {
+		t.Errorf("loaded scene drew %+v, want %+v", loaded.Draws, built.Draws)
+	}
+
+	// Light values only reach the GPU in buffers, compare the pixels too
+	if !bytes.Equal(drawSoftware(load), drawSoftware(buildLightingScene)) {
+		t.Error("loaded scene renders differently")
+	}
+}
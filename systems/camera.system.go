@@ -0,0 +1,59 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+)
+
+// CameraSystem keeps every camera's aspect ratio matching the window. Resize
+// events are recorded as they arrive and applied on the next Update.
+type CameraSystem struct {
+	EntityStore *entities.EntityStore
+
+	cameras       entities.Query1[components.CameraComponent]
+	width, height int
+	resized       bool
+}
+
+func NewCameraSystem(entityStore *entities.EntityStore, width, height int) *CameraSystem {
+	cs := &CameraSystem{
+		EntityStore: entityStore,
+		cameras:     entities.NewQuery1[components.CameraComponent](entityStore),
+		width:       width,
+		height:      height,
+	}
+
+	// New cameras start out matching the window
+	entities.OnAdd(entityStore, func(entity entities.Entity, cameraComponent *components.CameraComponent) {
+		cameraComponent.AspectRatio = cs.AspectRatio()
+	})
+
+	return cs
+}
+
+// Resize records the window's new framebuffer size. A zero size, e.g a
+// minimised window, is ignored so cameras keep a usable aspect ratio.
+func (cs *CameraSystem) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	cs.width, cs.height = width, height
+	cs.resized = true
+}
+
+func (cs *CameraSystem) AspectRatio() float32 {
+	return float32(cs.width) / float32(cs.height)
+}
+
+func (cs *CameraSystem) Update(deltaTime float32) {
+	if !cs.resized {
+		return
+	}
+	cs.resized = false
+
+	aspectRatio := cs.AspectRatio()
+	cs.cameras.Each(func(entity entities.Entity, cameraComponent *components.CameraComponent) {
+		cameraComponent.AspectRatio = aspectRatio
+	})
+}
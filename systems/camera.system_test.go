@@ -0,0 +1,57 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestCameraSystemSetsAspectRatioOfNewCameras(t *testing.T) {
+	store := entities.NewEntityStore()
+	NewCameraSystem(store, 1280, 720)
+
+	freecam := store.NewFreecamEntity(mgl32.Vec3{})
+	if freecam.CameraComponent.AspectRatio != 1280.0/720.0 {
+		t.Errorf("freecam aspect ratio %v, want %v", freecam.CameraComponent.AspectRatio, 1280.0/720.0)
+	}
+
+	camera := components.NewCameraComponent(mgl32.Vec3{0, 1, 0}, -90, 0, 45, 0, 0.1, 100)
+	store.AddComponent(store.NewEntity(), camera)
+	if camera.AspectRatio != 1280.0/720.0 {
+		t.Errorf("camera aspect ratio %v, want %v", camera.AspectRatio, 1280.0/720.0)
+	}
+}
+
+func TestCameraSystemResize(t *testing.T) {
+	store := entities.NewEntityStore()
+	cameraSystem := NewCameraSystem(store, 800, 600)
+
+	first := store.NewFreecamEntity(mgl32.Vec3{})
+	second := store.NewFreecamEntity(mgl32.Vec3{})
+
+	// Applied on the next update, not from inside the window's callback
+	cameraSystem.Resize(1920, 1080)
+	if first.CameraComponent.AspectRatio != 800.0/600.0 {
+		t.Errorf("resize applied before update, aspect ratio %v", first.CameraComponent.AspectRatio)
+	}
+
+	cameraSystem.Update(0)
+	for _, freecam := range []*entities.Freecam{first, second} {
+		if freecam.CameraComponent.AspectRatio != 1920.0/1080.0 {
+			t.Errorf("aspect ratio %v after resize, want %v", freecam.CameraComponent.AspectRatio, 1920.0/1080.0)
+		}
+	}
+
+	// Minimising reports a zero size, which would make the projection degenerate
+	cameraSystem.Resize(0, 0)
+	cameraSystem.Update(0)
+	if first.CameraComponent.AspectRatio != 1920.0/1080.0 {
+		t.Errorf("zero size changed the aspect ratio to %v", first.CameraComponent.AspectRatio)
+	}
+
+	if third := store.NewFreecamEntity(mgl32.Vec3{}); third.CameraComponent.AspectRatio != 1920.0/1080.0 {
+		t.Errorf("camera added after a resize has aspect ratio %v", third.CameraComponent.AspectRatio)
+	}
+}
@@ -0,0 +1,67 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// frameLights are the lights a frame is lit by, in world space
+type frameLights struct {
+	ambient     *components.AmbientLightComponent
+	directional *components.DirectionalLightComponent
+	points      []framePointLight
+	spots       []frameSpotLight
+}
+
+type framePointLight struct {
+	position   mgl32.Vec3
+	light      *components.PointLightComponent
+	shadowSlot int // Point shadow map, -1 without shadows
+}
+
+type frameSpotLight struct {
+	position    mgl32.Vec3
+	direction   mgl32.Vec3
+	light       *components.SpotLightComponent
+	shadowLayer int // Layer of the spot shadow maps, -1 without shadows
+}
+
+// gatherLights collects the frame's lights, only the first ambient and
+// directional light are used.
+func gatherLights(entityStore *entities.EntityStore, pointLights entities.Query1[components.PointLightComponent], spotLights entities.Query1[components.SpotLightComponent]) frameLights {
+	var lights frameLights
+
+	if ambientLightComponents := entities.GetAll[components.AmbientLightComponent](entityStore); len(ambientLightComponents) > 0 {
+		lights.ambient = ambientLightComponents[0]
+	}
+	if directionalLightComponents := entities.GetAll[components.DirectionalLightComponent](entityStore); len(directionalLightComponents) > 0 {
+		lights.directional = directionalLightComponents[0]
+	}
+
+	pointLights.Each(func(entity entities.Entity, pointLightComponent *components.PointLightComponent) {
+		position, _ := lightWorldTransform(entityStore, entity, pointLightComponent.Position, mgl32.Vec3{})
+		lights.points = append(lights.points, framePointLight{position: position, light: pointLightComponent, shadowSlot: -1})
+	})
+
+	spotLights.Each(func(entity entities.Entity, spotLightComponent *components.SpotLightComponent) {
+		position, direction := lightWorldTransform(entityStore, entity, spotLightComponent.Position, spotLightComponent.Direction)
+		lights.spots = append(lights.spots, frameSpotLight{position: position, direction: direction, light: spotLightComponent, shadowLayer: -1})
+	})
+
+	return lights
+}
+
+// lightWorldTransform places a light relative to its entity's transform, so lights
+// can be attached to moving entities. Lights without a transform are already in world space.
+func lightWorldTransform(entityStore *entities.EntityStore, entity entities.Entity, position, direction mgl32.Vec3) (mgl32.Vec3, mgl32.Vec3) {
+	transformComponent, ok := entities.Get[components.TransformComponent](entityStore, entity)
+	if !ok {
+		return position, direction
+	}
+
+	worldPosition := transformComponent.GetWorldMatrix().Mul4x1(position.Vec4(1)).Vec3()
+	worldDirection := transformComponent.GetWorldRotation().Rotate(direction)
+	return worldPosition, worldDirection
+}
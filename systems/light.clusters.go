@@ -0,0 +1,192 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"log"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// The camera's view is split into clusters, tiles across the screen and slices
+// in depth, each listing the lights that reach it. Fragments only loop over
+// their cluster's lights, so scenes can have hundreds of lights.
+const (
+	clusterTilesX = 16
+	clusterTilesY = 9
+	clusterSlices = 24
+	clusterCount  = clusterTilesX * clusterTilesY * clusterSlices
+)
+
+// lightClusters are the lights of each cluster, laid out as the shader reads
+// them: grid holds an offset into indices, a point light count and a spot light
+// count per cluster, and indices the point then spot light indices.
+type lightClusters struct {
+	grid    []uint32
+	indices []uint32
+
+	// Light indices the shader can fetch, the device's buffer texture size. GL
+	// only promises 65536, drivers allow far more and a few hundred lights can
+	// reach that many clusters.
+	maxIndices int
+
+	near, far float32
+
+	points [clusterCount][]uint32
+	spots  [clusterCount][]uint32
+
+	projection mgl32.Mat4
+	bounds     [clusterCount]clusterBounds // View space, rebuilt when the projection changes
+
+	truncated bool // Logged once, rather than every frame
+}
+
+type clusterBounds struct {
+	min, max mgl32.Vec3
+}
+
+// assign works out which clusters each light reaches
+func (clusters *lightClusters) assign(lights frameLights, camera *components.CameraComponent, view mgl32.Mat4) {
+	projection := camera.GetProjectionMatrix()
+	if projection != clusters.projection {
+		clusters.projection = projection
+		clusters.near, clusters.far = camera.NearClip, camera.FarClip
+		clusters.buildBounds()
+	}
+
+	for cluster := range clusters.points {
+		clusters.points[cluster] = clusters.points[cluster][:0]
+		clusters.spots[cluster] = clusters.spots[cluster][:0]
+	}
+
+	for index, pointLight := range lights.points {
+		light := pointLight.light
+		center := view.Mul4x1(pointLight.position.Vec4(1)).Vec3()
+		clusters.addSphere(center, lightRange(light.Constant, light.Linear, light.Quadratic), uint32(index), &clusters.points)
+	}
+
+	for index, spotLight := range lights.spots {
+		center, radius := spotBoundingSphere(spotLight)
+		center = view.Mul4x1(center.Vec4(1)).Vec3()
+		clusters.addSphere(center, radius, uint32(index), &clusters.spots)
+	}
+
+	clusters.grid = clusters.grid[:0]
+	clusters.indices = clusters.indices[:0]
+	for cluster := range clusters.points {
+		points, spots := clusters.points[cluster], clusters.spots[cluster]
+		if len(clusters.indices)+len(points)+len(spots) > clusters.maxIndices {
+			if !clusters.truncated {
+				log.Printf("Too many lights in view, clusters are limited to %d light indices", clusters.maxIndices)
+				clusters.truncated = true
+			}
+			points, spots = nil, nil
+		}
+
+		clusters.grid = append(clusters.grid, uint32(len(clusters.indices)), uint32(len(points)), uint32(len(spots)), 0)
+		clusters.indices = append(clusters.indices, points...)
+		clusters.indices = append(clusters.indices, spots...)
+	}
+}
+
+// addSphere adds a light to every cluster a view space sphere touches
+func (clusters *lightClusters) addSphere(center mgl32.Vec3, radius float32, index uint32, lists *[clusterCount][]uint32) {
+	depth := -center.Z()
+	if depth+radius < clusters.near || depth-radius > clusters.far {
+		return
+	}
+
+	firstSlice := clusters.slice(depth - radius)
+	lastSlice := clusters.slice(depth + radius)
+	for slice := firstSlice; slice <= lastSlice; slice++ {
+		for tile := 0; tile < clusterTilesX*clusterTilesY; tile++ {
+			cluster := slice*clusterTilesX*clusterTilesY + tile
+			if clusters.bounds[cluster].touchesSphere(center, radius) {
+				lists[cluster] = append(lists[cluster], index)
+			}
+		}
+	}
+}
+
+// slice is the depth slice of a view space depth, slices grow exponentially so
+// clusters are about as deep as they are wide.
+func (clusters *lightClusters) slice(depth float32) int {
+	if depth <= clusters.near {
+		return 0
+	}
+	slice := int(math.Log(float64(depth/clusters.near)) / math.Log(float64(clusters.far/clusters.near)) * clusterSlices)
+	return min(slice, clusterSlices-1)
+}
+
+// sliceScale is how the shader finds a slice: log(depth) * scale - offset
+func (clusters *lightClusters) sliceScale() (scale, offset float32) {
+	scale = clusterSlices / float32(math.Log(float64(clusters.far/clusters.near)))
+	return scale, float32(math.Log(float64(clusters.near))) * scale
+}
+
+func (clusters *lightClusters) buildBounds() {
+	// A point at ndc x, y and view depth d is at d * ndc / scale in view space
+	scaleX, scaleY := clusters.projection[0], clusters.projection[5]
+
+	for slice := 0; slice < clusterSlices; slice++ {
+		ratio := float64(clusters.far / clusters.near)
+		sliceNear := clusters.near * float32(math.Pow(ratio, float64(slice)/clusterSlices))
+		sliceFar := clusters.near * float32(math.Pow(ratio, float64(slice+1)/clusterSlices))
+
+		for y := 0; y < clusterTilesY; y++ {
+			for x := 0; x < clusterTilesX; x++ {
+				ndcMinX, ndcMaxX := float32(x)/clusterTilesX*2-1, float32(x+1)/clusterTilesX*2-1
+				ndcMinY, ndcMaxY := float32(y)/clusterTilesY*2-1, float32(y+1)/clusterTilesY*2-1
+
+				bounds := clusterBounds{
+					min: mgl32.Vec3{float32(math.Inf(1)), float32(math.Inf(1)), -sliceFar},
+					max: mgl32.Vec3{float32(math.Inf(-1)), float32(math.Inf(-1)), -sliceNear},
+				}
+				for _, depth := range []float32{sliceNear, sliceFar} {
+					for _, ndcX := range []float32{ndcMinX, ndcMaxX} {
+						bounds.min[0] = min(bounds.min[0], depth*ndcX/scaleX)
+						bounds.max[0] = max(bounds.max[0], depth*ndcX/scaleX)
+					}
+					for _, ndcY := range []float32{ndcMinY, ndcMaxY} {
+						bounds.min[1] = min(bounds.min[1], depth*ndcY/scaleY)
+						bounds.max[1] = max(bounds.max[1], depth*ndcY/scaleY)
+					}
+				}
+
+				clusters.bounds[(slice*clusterTilesY+y)*clusterTilesX+x] = bounds
+			}
+		}
+	}
+}
+
+func (bounds clusterBounds) touchesSphere(center mgl32.Vec3, radius float32) bool {
+	var distance float32
+	for axis := 0; axis < 3; axis++ {
+		closest := mgl32.Clamp(center[axis], bounds.min[axis], bounds.max[axis])
+		distance += (center[axis] - closest) * (center[axis] - closest)
+	}
+	return distance <= radius*radius
+}
+
+// spotBoundingSphere is the smallest sphere around a spot light's cone
+func spotBoundingSphere(spotLight frameSpotLight) (mgl32.Vec3, float32) {
+	light := spotLight.light
+	reach := lightRange(light.Constant, light.Linear, light.Quadratic)
+	if spotLight.direction.Len() == 0 {
+		return spotLight.position, reach
+	}
+
+	direction := spotLight.direction.Normalize()
+	cosAngle := min(light.CutOff, light.OuterCutOff, 1)
+	if cosAngle <= 0 {
+		return spotLight.position, reach
+	}
+	if cosAngle < math.Sqrt2/2 {
+		// Wider than 90 degrees across, the sphere is around the cone's cap
+		sinAngle := float32(math.Sqrt(float64(1 - cosAngle*cosAngle)))
+		return spotLight.position.Add(direction.Mul(reach * cosAngle)), reach * sinAngle
+	}
+
+	radius := reach / (2 * cosAngle)
+	return spotLight.position.Add(direction.Mul(radius)), radius
+}
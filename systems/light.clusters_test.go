@@ -0,0 +1,259 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func newClusterCamera() (*components.CameraComponent, mgl32.Mat4) {
+	camera := components.NewCameraComponent(mgl32.Vec3{0, 1, 0}, 0, 0, 45, 16.0/9.0, 0.1, 100)
+	return camera, mgl32.LookAtV(mgl32.Vec3{3, 2, 10}, mgl32.Vec3{1, 0, 0}, mgl32.Vec3{0, 1, 0})
+}
+
+// randomViewPoint is a point in the camera's view, spread evenly across the
+// screen and over the depth slices
+func randomViewPoint(rng *rand.Rand, clusters *lightClusters) mgl32.Vec3 {
+	ndcX, ndcY := rng.Float32()*2-1, rng.Float32()*2-1
+	depth := clusters.near * float32(math.Pow(float64(clusters.far/clusters.near), rng.Float64()))
+	return mgl32.Vec3{depth * ndcX / clusters.projection[0], depth * ndcY / clusters.projection[5], -depth}
+}
+
+// shaderCluster finds a view space point's cluster the way fragment.glsl does
+func shaderCluster(clusters *lightClusters, point mgl32.Vec3) int {
+	clip := clusters.projection.Mul4x1(point.Vec4(1))
+	ndcX, ndcY := clip.X()/clip.W(), clip.Y()/clip.W()
+
+	tileX := min(max(int((ndcX+1)/2*clusterTilesX), 0), clusterTilesX-1)
+	tileY := min(max(int((ndcY+1)/2*clusterTilesY), 0), clusterTilesY-1)
+
+	scale, offset := clusters.sliceScale()
+	slice := min(max(int(float32(math.Log(float64(-point.Z())))*scale-offset), 0), clusterSlices-1)
+
+	return (slice*clusterTilesY+tileY)*clusterTilesX + tileX
+}
+
+// clusterLights returns a cluster's point and spot light indices from the grid
+func clusterLights(clusters *lightClusters, cluster int) (points, spots []uint32) {
+	offset, pointCount, spotCount := clusters.grid[cluster*4], clusters.grid[cluster*4+1], clusters.grid[cluster*4+2]
+	return clusters.indices[offset : offset+pointCount], clusters.indices[offset+pointCount : offset+pointCount+spotCount]
+}
+
+// boundsDistance is how far a point is from a cluster's bounds, 0 inside them
+func boundsDistance(bounds clusterBounds, point mgl32.Vec3) float32 {
+	var distance float32
+	for axis := 0; axis < 3; axis++ {
+		outside := max(bounds.min[axis]-point[axis], point[axis]-bounds.max[axis], 0)
+		distance += outside * outside
+	}
+	return float32(math.Sqrt(float64(distance)))
+}
+
+func TestLightClusterBounds(t *testing.T) {
+	camera, view := newClusterCamera()
+	var clusters lightClusters
+	clusters.assign(frameLights{}, camera, view)
+
+	if len(clusters.grid) != clusterCount*4 || len(clusters.indices) != 0 {
+		t.Fatalf("grid of %d and %d indices without lights", len(clusters.grid), len(clusters.indices))
+	}
+
+	// Slices split the view from near to far exponentially, without gaps
+	ratio := float64(camera.FarClip / camera.NearClip)
+	for slice := 0; slice < clusterSlices; slice++ {
+		sliceNear := camera.NearClip * float32(math.Pow(ratio, float64(slice)/clusterSlices))
+		sliceFar := camera.NearClip * float32(math.Pow(ratio, float64(slice+1)/clusterSlices))
+
+		for tile := 0; tile < clusterTilesX*clusterTilesY; tile++ {
+			bounds := clusters.bounds[slice*clusterTilesX*clusterTilesY+tile]
+			if !mgl32.FloatEqualThreshold(bounds.max.Z(), -sliceNear, 1e-4) || !mgl32.FloatEqualThreshold(bounds.min.Z(), -sliceFar, 1e-4) {
+				t.Fatalf("slice %d spans z %v to %v, want %v to %v", slice, bounds.min.Z(), bounds.max.Z(), -sliceFar, -sliceNear)
+			}
+			if bounds.min.X() >= bounds.max.X() || bounds.min.Y() >= bounds.max.Y() {
+				t.Fatalf("cluster %d is empty, %v to %v", slice*clusterTilesX*clusterTilesY+tile, bounds.min, bounds.max)
+			}
+		}
+	}
+	if first, last := clusters.bounds[0], clusters.bounds[clusterCount-1]; first.max.Z() != -camera.NearClip || !mgl32.FloatEqual(last.min.Z(), -camera.FarClip) {
+		t.Errorf("clusters span z %v to %v, want %v to %v", last.min.Z(), first.max.Z(), -camera.FarClip, -camera.NearClip)
+	}
+
+	// Every point in view is inside the cluster the shader looks it up in
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		point := randomViewPoint(rng, &clusters)
+		cluster := shaderCluster(&clusters, point)
+		if distance := boundsDistance(clusters.bounds[cluster], point); distance > 1e-3*-point.Z() {
+			t.Fatalf("%v is %v outside its cluster %d, %v to %v", point, distance, cluster, clusters.bounds[cluster].min, clusters.bounds[cluster].max)
+		}
+	}
+
+	// Rebuilt when the projection changes
+	camera.AspectRatio = 1
+	clusters.assign(frameLights{}, camera, view)
+	if clusters.projection != camera.GetProjectionMatrix() {
+		t.Error("bounds not rebuilt for the new projection")
+	}
+}
+
+// newRandomLights scatters point lights and spot lights, narrow and wide, around the origin
+func newRandomLights(rng *rand.Rand) frameLights {
+	randomVec3 := func(scale float32) mgl32.Vec3 {
+		return mgl32.Vec3{rng.Float32()*2 - 1, rng.Float32()*2 - 1, rng.Float32()*2 - 1}.Mul(scale)
+	}
+	randomAttenuation := func() (float32, float32) {
+		return 0.05 + rng.Float32()*0.5, 0.02 + rng.Float32()*1.5
+	}
+
+	var lights frameLights
+	for i := 0; i < 200; i++ {
+		linear, quadratic := randomAttenuation()
+		light := components.NewPointLightComponent(mgl32.Vec3{}, mgl32.Vec3{1, 1, 1}, 1, 1, linear, quadratic)
+		lights.points = append(lights.points, framePointLight{position: randomVec3(40), light: light, shadowSlot: -1})
+	}
+	for i := 0; i < 60; i++ {
+		// Narrow cones and ones wider than 90 degrees across
+		angle := 5 + rng.Float64()*70
+		cutOff := float32(math.Cos(angle * math.Pi / 180))
+		outerCutOff := float32(math.Cos((angle + 5) * math.Pi / 180))
+		linear, quadratic := randomAttenuation()
+
+		direction := randomVec3(1)
+		light := components.NewSpotLightComponent(mgl32.Vec3{}, mgl32.Vec3{1, 1, 1}, direction, cutOff, outerCutOff, 1, 1, linear, quadratic)
+		lights.spots = append(lights.spots, frameSpotLight{position: randomVec3(40), direction: direction, light: light, shadowLayer: -1})
+	}
+	return lights
+}
+
+func TestLightClustersMatchBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	lights := newRandomLights(rng)
+
+	camera, view := newClusterCamera()
+	clusters := lightClusters{maxIndices: 1 << 20}
+	clusters.assign(lights, camera, view)
+
+	if clusters.truncated {
+		t.Fatal("clusters truncated")
+	}
+
+	// The grid's offsets run through indices in order
+	offset := uint32(0)
+	for cluster := 0; cluster < clusterCount; cluster++ {
+		if clusters.grid[cluster*4] != offset {
+			t.Fatalf("cluster %d starts at %d, want %d", cluster, clusters.grid[cluster*4], offset)
+		}
+		offset += clusters.grid[cluster*4+1] + clusters.grid[cluster*4+2]
+	}
+	if int(offset) != len(clusters.indices) {
+		t.Fatalf("clusters list %d indices, have %d", offset, len(clusters.indices))
+	}
+
+	type sphere struct {
+		center mgl32.Vec3
+		radius float32
+	}
+	pointSpheres := make([]sphere, len(lights.points))
+	for i, pointLight := range lights.points {
+		light := pointLight.light
+		pointSpheres[i] = sphere{view.Mul4x1(pointLight.position.Vec4(1)).Vec3(), lightRange(light.Constant, light.Linear, light.Quadratic)}
+	}
+	spotSpheres := make([]sphere, len(lights.spots))
+	for i, spotLight := range lights.spots {
+		center, radius := spotBoundingSphere(spotLight)
+		spotSpheres[i] = sphere{view.Mul4x1(center.Vec4(1)).Vec3(), radius}
+	}
+
+	// Each cluster lists exactly the lights whose bounding spheres reach it,
+	// give or take rounding on the edges
+	checkList := func(cluster int, kind string, listed []uint32, spheres []sphere) {
+		for i, sphere := range spheres {
+			distance := boundsDistance(clusters.bounds[cluster], sphere.center)
+			isListed := slices.Contains(listed, uint32(i))
+			if !isListed && distance < sphere.radius*0.999 {
+				t.Errorf("cluster %d is missing %s light %d, %v inside its range", cluster, kind, i, sphere.radius-distance)
+			}
+			if isListed && distance > sphere.radius*1.001 {
+				t.Errorf("cluster %d lists %s light %d, %v out of its range", cluster, kind, i, distance-sphere.radius)
+			}
+		}
+
+		for i, index := range listed {
+			if slices.Contains(listed[:i], index) {
+				t.Errorf("cluster %d lists %s light %d twice", cluster, kind, index)
+			}
+		}
+	}
+	for cluster := 0; cluster < clusterCount; cluster++ {
+		points, spots := clusterLights(&clusters, cluster)
+		checkList(cluster, "point", points, pointSpheres)
+		checkList(cluster, "spot", spots, spotSpheres)
+	}
+
+	// Every light that reaches a point is in its cluster, spot lights only within their cone
+	inverseView := view.Inv()
+	lit := 0
+	for i := 0; i < 5000; i++ {
+		viewPoint := randomViewPoint(rng, &clusters)
+		worldPoint := inverseView.Mul4x1(viewPoint.Vec4(1)).Vec3()
+		points, spots := clusterLights(&clusters, shaderCluster(&clusters, viewPoint))
+
+		for index, pointLight := range lights.points {
+			if worldPoint.Sub(pointLight.position).Len() < pointSpheres[index].radius*0.999 {
+				lit++
+				if !slices.Contains(points, uint32(index)) {
+					t.Errorf("point light %d reaches %v but isn't in its cluster", index, worldPoint)
+				}
+			}
+		}
+		for index, spotLight := range lights.spots {
+			light := spotLight.light
+			toPoint := worldPoint.Sub(spotLight.position)
+			inCone := toPoint.Normalize().Dot(spotLight.direction.Normalize()) > min(light.CutOff, light.OuterCutOff)+1e-3
+			if inCone && toPoint.Len() < lightRange(light.Constant, light.Linear, light.Quadratic)*0.999 {
+				lit++
+				if !slices.Contains(spots, uint32(index)) {
+					t.Errorf("spot light %d reaches %v but isn't in its cluster", index, worldPoint)
+				}
+			}
+		}
+	}
+	if lit < 1000 {
+		t.Errorf("only %d sampled points were lit, the scene should be denser", lit)
+	}
+}
+
+// Clusters past the device's limit are left without lights rather than
+// listing indices the shader can't fetch
+func TestLightClustersTruncateAtMaxIndices(t *testing.T) {
+	lights := newRandomLights(rand.New(rand.NewSource(1)))
+	camera, view := newClusterCamera()
+
+	all := lightClusters{maxIndices: 1 << 20}
+	all.assign(lights, camera, view)
+
+	clusters := lightClusters{maxIndices: len(all.indices) / 2}
+	clusters.assign(lights, camera, view)
+	if !clusters.truncated || len(clusters.indices) > clusters.maxIndices {
+		t.Fatalf("%d indices of at most %d, truncated %v", len(clusters.indices), clusters.maxIndices, clusters.truncated)
+	}
+
+	// Each cluster lists all of its lights or none of them
+	dropped := 0
+	for cluster := 0; cluster < clusterCount; cluster++ {
+		points, spots := clusterLights(&clusters, cluster)
+		allPoints, allSpots := clusterLights(&all, cluster)
+		if len(points)+len(spots) == 0 && len(allPoints)+len(allSpots) > 0 {
+			dropped++
+		} else if !slices.Equal(points, allPoints) || !slices.Equal(spots, allSpots) {
+			t.Fatalf("cluster %d lists %d and %d of its %d and %d lights", cluster, len(points), len(spots), len(allPoints), len(allSpots))
+		}
+	}
+	if dropped == 0 {
+		t.Error("no cluster was left without lights")
+	}
+}
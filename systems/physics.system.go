@@ -12,41 +12,36 @@ type PhysicsSystem struct {
 	EntityStore *entities.EntityStore
 	Gravity     mgl32.Vec3
 	dt          float32
+
+	bodies    entities.Query2[components.PhysicsComponent, components.TransformComponent]
+	colliders entities.Query
 }
 
 func NewPhysicsSystem(entityStore *entities.EntityStore, gravity mgl32.Vec3) *PhysicsSystem {
 	return &PhysicsSystem{
 		EntityStore: entityStore,
 		Gravity:     gravity,
+
+		bodies: entities.NewQuery2[components.PhysicsComponent, components.TransformComponent](entityStore),
+		colliders: entities.NewQuery(entityStore,
+			entities.With[components.PhysicsComponent](),
+			entities.With[components.TransformComponent](),
+			entities.With[components.BoxColliderComponent]()),
 	}
 }
 
 func (ps *PhysicsSystem) Update(dt float32) {
 	ps.dt = dt
 
-	entities := ps.EntityStore.GetEntitiesWithComponentType(&components.PhysicsComponent{})
-
-	for _, entity := range entities {
-		physicsComponent, physicsComponentOk := ps.EntityStore.GetComponent(entity, &components.PhysicsComponent{}).(*components.PhysicsComponent)
-		if !physicsComponentOk {
-			log.Println("Error converting physics component interface into component")
-			continue
-		}
-
+	ps.bodies.Each(func(entity entities.Entity, physicsComponent *components.PhysicsComponent, transformComponent *components.TransformComponent) {
 		if physicsComponent.Static {
-			continue
-		}
-
-		transformComponent, transformComponentOk := ps.EntityStore.GetComponent(entity, &components.TransformComponent{}).(*components.TransformComponent)
-		if !transformComponentOk {
-			log.Println("Error converting transform component interface into component")
-			continue
+			return
 		}
 
 		ps.updateForces(physicsComponent)
 		ps.applyForces(physicsComponent, transformComponent)
 		ps.handleCollisions(entity)
-	}
+	})
 }
 
 func (ps *PhysicsSystem) updateForces(physicsComponent *components.PhysicsComponent) {
@@ -62,61 +57,48 @@ func (ps *PhysicsSystem) applyForces(physicsComponent *components.PhysicsCompone
 
 	// Update position based on velocity
 	displacement := physicsComponent.Velocity.Mul(ps.dt)
-	transformComponent.Position = transformComponent.Position.Add(displacement)
+	transformComponent.TranslateWorld(displacement)
 }
 
 func (ps *PhysicsSystem) handleCollisions(entity entities.Entity) {
-	entities := ps.EntityStore.GetEntitiesWithComponentType(&components.PhysicsComponent{})
-
-	for _, entityToCheck := range entities {
+	ps.colliders.Each(func(entityToCheck entities.Entity) {
 		if entityToCheck.ID == entity.ID {
-			continue
+			return
 		}
 
 		broadPhaseCollision := ps.broadPhaseCollisionCheck(entity, entityToCheck)
-		log.Println(broadPhaseCollision)
 		if !broadPhaseCollision {
-			continue
+			return
 		}
 
 		narrowPhaseCollision := ps.narrowPhaseCollisionCheck(entity, entityToCheck)
 
 		if !narrowPhaseCollision {
-			continue
+			return
 		}
 
 		ps.resolveCollision(entity, entityToCheck)
-	}
+	})
 }
 
 func (ps *PhysicsSystem) broadPhaseCollisionCheck(entity, entityToCheck entities.Entity) bool {
-	boxCollider1, ok1 := ps.EntityStore.GetComponent(entity, &components.BoxColliderComponent{}).(*components.BoxColliderComponent)
-	if !ok1 {
-		log.Println("Error converting box collider component interface into component for the first entity")
-		return false
-	}
-	transform1, ok1 := ps.EntityStore.GetComponent(entity, &components.TransformComponent{}).(*components.TransformComponent)
-	if !ok1 {
-		log.Println("Error converting transform component interface into component for the first entity")
+	boxCollider1, ok1 := entities.Get[components.BoxColliderComponent](ps.EntityStore, entity)
+	transform1, okT1 := entities.Get[components.TransformComponent](ps.EntityStore, entity)
+	if !ok1 || !okT1 {
 		return false
 	}
 
-	boxCollider2, ok2 := ps.EntityStore.GetComponent(entityToCheck, &components.BoxColliderComponent{}).(*components.BoxColliderComponent)
-	if !ok2 {
-		log.Println("Error converting box collider component interface into component for the second entity")
-		return false
-	}
-	transform2, ok2 := ps.EntityStore.GetComponent(entityToCheck, &components.TransformComponent{}).(*components.TransformComponent)
-	if !ok2 {
-		log.Println("Error converting transform component interface into component for the second entity")
+	boxCollider2, ok2 := entities.Get[components.BoxColliderComponent](ps.EntityStore, entityToCheck)
+	transform2, okT2 := entities.Get[components.TransformComponent](ps.EntityStore, entityToCheck)
+	if !ok2 || !okT2 {
 		return false
 	}
 
-	min1 := transform1.Position.Add(boxCollider1.Center.Sub(boxCollider1.Size.Mul(0.5)))
-	max1 := transform1.Position.Add(boxCollider1.Center.Add(boxCollider1.Size.Mul(0.5)))
+	min1 := transform1.GetWorldPosition().Add(boxCollider1.Center.Sub(boxCollider1.Size.Mul(0.5)))
+	max1 := transform1.GetWorldPosition().Add(boxCollider1.Center.Add(boxCollider1.Size.Mul(0.5)))
 
-	min2 := transform2.Position.Add(boxCollider2.Center.Sub(boxCollider2.Size.Mul(0.5)))
-	max2 := transform2.Position.Add(boxCollider2.Center.Add(boxCollider2.Size.Mul(0.5)))
+	min2 := transform2.GetWorldPosition().Add(boxCollider2.Center.Sub(boxCollider2.Size.Mul(0.5)))
+	max2 := transform2.GetWorldPosition().Add(boxCollider2.Center.Add(boxCollider2.Size.Mul(0.5)))
 
 	return min1[0] <= max2[0] && max1[0] >= min2[0] &&
 		min1[1] <= max2[1] && max1[1] >= min2[1] &&
@@ -128,31 +110,31 @@ func (ps *PhysicsSystem) narrowPhaseCollisionCheck(entity, entityToCheck entitie
 }
 
 func (ps *PhysicsSystem) resolveCollision(entity, entityToCheck entities.Entity) {
-	physics1, ok1 := ps.EntityStore.GetComponent(entity, &components.PhysicsComponent{}).(*components.PhysicsComponent)
-	transform1, okT1 := ps.EntityStore.GetComponent(entity, &components.TransformComponent{}).(*components.TransformComponent)
-	boxCollider1, okB1 := ps.EntityStore.GetComponent(entity, &components.BoxColliderComponent{}).(*components.BoxColliderComponent)
+	physics1, ok1 := entities.Get[components.PhysicsComponent](ps.EntityStore, entity)
+	transform1, okT1 := entities.Get[components.TransformComponent](ps.EntityStore, entity)
+	boxCollider1, okB1 := entities.Get[components.BoxColliderComponent](ps.EntityStore, entity)
 	if !ok1 || !okT1 || !okB1 {
 		log.Println("Error retrieving components for the first entity")
 		return
 	}
 
-	physics2, ok2 := ps.EntityStore.GetComponent(entityToCheck, &components.PhysicsComponent{}).(*components.PhysicsComponent)
-	transform2, okT2 := ps.EntityStore.GetComponent(entityToCheck, &components.TransformComponent{}).(*components.TransformComponent)
-	boxCollider2, okB2 := ps.EntityStore.GetComponent(entityToCheck, &components.BoxColliderComponent{}).(*components.BoxColliderComponent)
+	physics2, ok2 := entities.Get[components.PhysicsComponent](ps.EntityStore, entityToCheck)
+	transform2, okT2 := entities.Get[components.TransformComponent](ps.EntityStore, entityToCheck)
+	boxCollider2, okB2 := entities.Get[components.BoxColliderComponent](ps.EntityStore, entityToCheck)
 	if !ok2 || !okT2 || !okB2 {
 		log.Println("Error retrieving components for the second entity")
 		return
 	}
 
-	center1 := transform1.Position.Add(boxCollider1.Center)
-	center2 := transform2.Position.Add(boxCollider2.Center)
+	center1 := transform1.GetWorldPosition().Add(boxCollider1.Center)
+	center2 := transform2.GetWorldPosition().Add(boxCollider2.Center)
 	distance := center1.Sub(center2)
 	displacement := distance.Normalize().Mul(0.5)
 
 	// Update positions to resolve penetration
-	transform1.Position = transform1.Position.Add(displacement)
+	transform1.TranslateWorld(displacement)
 	if !physics2.Static {
-		transform2.Position = transform2.Position.Sub(displacement)
+		transform2.TranslateWorld(displacement.Mul(-1))
 	}
 	// Update velocities based on a very basic elastic collision response
 	v1 := physics1.Velocity
@@ -0,0 +1,216 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/graphics"
+	"fmt"
+	"log"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Binding points of fragment.glsl's uniform blocks
+const (
+	lightsBlockBinding  = 0
+	shadowsBlockBinding = 1
+)
+
+// Texture units of the light and cluster buffers, after the shadow maps
+const (
+	pointLightsUnit   = pointShadowUnit + maxPointShadows
+	spotLightsUnit    = pointLightsUnit + 1
+	clusterGridUnit   = pointLightsUnit + 2
+	clusterLightsUnit = pointLightsUnit + 3
+)
+
+// lightBuffers hold the frame's lights on the GPU. The fixed size parts are
+// uniform blocks, the lights and their clusters are texture buffers so there is
+// no limit on how many there are.
+type lightBuffers struct {
+	lightsBlock  graphics.Buffer
+	shadowsBlock graphics.Buffer
+
+	pointLights   graphics.Buffer
+	spotLights    graphics.Buffer
+	clusterGrid   graphics.Buffer
+	clusterLights graphics.Buffer
+	textures      [4]graphics.Texture // Of the texture buffers, in the order above
+
+	clusters lightClusters
+
+	// Reused between frames
+	lightsData  []float32
+	shadowsData []float32
+	pointsData  []float32
+	spotsData   []float32
+}
+
+func newLightBuffers(device graphics.Device) *lightBuffers {
+	buffers := &lightBuffers{
+		lightsBlock:   device.CreateBuffer(graphics.UniformBuffer, make([]float32, lightsBlockFloats)),
+		shadowsBlock:  device.CreateBuffer(graphics.UniformBuffer, make([]float32, shadowsBlockFloats)),
+		pointLights:   device.CreateBuffer(graphics.TextureBuffer, []float32(nil)),
+		spotLights:    device.CreateBuffer(graphics.TextureBuffer, []float32(nil)),
+		clusterGrid:   device.CreateBuffer(graphics.TextureBuffer, []uint32(nil)),
+		clusterLights: device.CreateBuffer(graphics.TextureBuffer, []uint32(nil)),
+	}
+	buffers.clusters.maxIndices = device.MaxBufferTextureSize()
+	buffers.textures = [4]graphics.Texture{
+		device.CreateBufferTexture(buffers.pointLights, graphics.RGBA32F),
+		device.CreateBufferTexture(buffers.spotLights, graphics.RGBA32F),
+		device.CreateBufferTexture(buffers.clusterGrid, graphics.RGBA32UI),
+		device.CreateBufferTexture(buffers.clusterLights, graphics.R32UI),
+	}
+	return buffers
+}
+
+// initLightUniforms points the shader's uniform blocks and samplers at their
+// bindings and units, which stay the same from frame to frame.
+func (rs *RenderSystem) initLightUniforms() {
+	rs.ShaderProgram.Use()
+
+	if err := rs.Device.SetUniformBlock("Lights", lightsBlockBinding); err != nil {
+		log.Println(err)
+	}
+	if err := rs.Device.SetUniformBlock("Shadows", shadowsBlockBinding); err != nil {
+		log.Println(err)
+	}
+
+	rs.SetShaderUniformInt("pointLightData", pointLightsUnit)
+	rs.SetShaderUniformInt("spotLightData", spotLightsUnit)
+	rs.SetShaderUniformInt("clusterGrid", clusterGridUnit)
+	rs.SetShaderUniformInt("clusterLights", clusterLightsUnit)
+
+	// Samplers of different types can't share a unit, so these are set even without shadows
+	rs.SetShaderUniformInt("directionalShadowMap", directionalShadowUnit)
+	rs.SetShaderUniformInt("spotShadowMaps", spotShadowUnit)
+	for slot := 0; slot < maxPointShadows; slot++ {
+		rs.SetShaderUniformInt(fmt.Sprintf("pointShadowMaps[%d]", slot), int32(pointShadowUnit+slot))
+	}
+}
+
+// uploadLights fills the light buffers for the frame and binds them
+func (rs *RenderSystem) uploadLights(lights frameLights, shadows frameShadows, camera *components.CameraComponent, view mgl32.Mat4) {
+	buffers := rs.lightBuffers
+	buffers.clusters.assign(lights, camera, view)
+
+	_, _, width, height := rs.Device.Viewport()
+	buffers.lightsData = lightsBlock(buffers.lightsData[:0], lights, &buffers.clusters, width, height)
+	buffers.shadowsData = shadowsBlock(buffers.shadowsData[:0], shadows)
+	buffers.pointsData = packPointLights(buffers.pointsData[:0], lights.points)
+	buffers.spotsData = packSpotLights(buffers.spotsData[:0], lights.spots)
+
+	rs.Device.UpdateBuffer(buffers.lightsBlock, buffers.lightsData)
+	rs.Device.UpdateBuffer(buffers.shadowsBlock, buffers.shadowsData)
+	rs.Device.UpdateBuffer(buffers.pointLights, buffers.pointsData)
+	rs.Device.UpdateBuffer(buffers.spotLights, buffers.spotsData)
+	rs.Device.UpdateBuffer(buffers.clusterGrid, buffers.clusters.grid)
+	rs.Device.UpdateBuffer(buffers.clusterLights, buffers.clusters.indices)
+
+	rs.Device.BindUniformBuffer(lightsBlockBinding, buffers.lightsBlock)
+	rs.Device.BindUniformBuffer(shadowsBlockBinding, buffers.shadowsBlock)
+	for i, unit := range []int{pointLightsUnit, spotLightsUnit, clusterGridUnit, clusterLightsUnit} {
+		rs.Device.BindTexture(unit, buffers.textures[i])
+	}
+}
+
+// Sizes of the blocks in fragment.glsl, std140 packs them as written
+const (
+	lightsBlockFloats  = 4 * 4
+	shadowsBlockFloats = (maxShadowCascades*16 + 2*4) + maxSpotShadows*(16+4) + maxPointShadows*2*4
+)
+
+// lightsBlock is the Lights uniform block
+func lightsBlock(data []float32, lights frameLights, clusters *lightClusters, width, height int) []float32 {
+	var ambient, direction, directional mgl32.Vec4
+	if lights.ambient != nil {
+		ambient = lights.ambient.Color.Vec4(lights.ambient.Intensity)
+	}
+	if lights.directional != nil {
+		direction = lights.directional.Direction.Vec4(0)
+		directional = lights.directional.Color.Vec4(lights.directional.Intensity)
+	}
+
+	sliceScale, sliceOffset := clusters.sliceScale()
+	clusterScale := mgl32.Vec4{clusterTilesX / float32(max(width, 1)), clusterTilesY / float32(max(height, 1)), sliceScale, sliceOffset}
+
+	data = append(data, ambient[:]...)
+	data = append(data, direction[:]...)
+	data = append(data, directional[:]...)
+	return append(data, clusterScale[:]...)
+}
+
+// shadowsBlock is the Shadows uniform block, spot shadows by layer and point
+// shadows by slot
+func shadowsBlock(data []float32, shadows frameShadows) []float32 {
+	var cascadeMatrices [maxShadowCascades]mgl32.Mat4
+	var splits, directional mgl32.Vec4
+	if shadows.directional != nil {
+		copy(cascadeMatrices[:], shadows.directional.matrices)
+		copy(splits[:], shadows.directional.splits)
+		settings := shadows.directional.settings
+		directional = mgl32.Vec4{1, float32(len(shadows.directional.matrices)), settings.Bias, float32(settings.PCFRadius)}
+	}
+	for _, matrix := range cascadeMatrices {
+		data = append(data, matrix[:]...)
+	}
+	data = append(data, splits[:]...)
+	data = append(data, directional[:]...)
+
+	var spotMatrices [maxSpotShadows]mgl32.Mat4
+	var spotSettings [maxSpotShadows]mgl32.Vec4
+	for layer, spot := range shadows.spots {
+		spotMatrices[layer] = spot.matrix
+		spotSettings[layer] = mgl32.Vec4{spot.settings.Bias, float32(spot.settings.PCFRadius)}
+	}
+	for _, matrix := range spotMatrices {
+		data = append(data, matrix[:]...)
+	}
+	for _, settings := range spotSettings {
+		data = append(data, settings[:]...)
+	}
+
+	var pointPositions, pointSettings [maxPointShadows]mgl32.Vec4
+	for slot, point := range shadows.points {
+		if point == nil {
+			continue
+		}
+		pointPositions[slot] = point.position.Vec4(point.far)
+		pointSettings[slot] = mgl32.Vec4{point.settings.Bias, float32(point.settings.Resolution), float32(point.settings.PCFRadius)}
+	}
+	for _, position := range pointPositions {
+		data = append(data, position[:]...)
+	}
+	for _, settings := range pointSettings {
+		data = append(data, settings[:]...)
+	}
+
+	return data
+}
+
+// packPointLights lays point lights out as fetchPointLight reads them
+func packPointLights(data []float32, points []framePointLight) []float32 {
+	for _, pointLight := range points {
+		light := pointLight.light
+		data = append(data,
+			pointLight.position.X(), pointLight.position.Y(), pointLight.position.Z(), lightRange(light.Constant, light.Linear, light.Quadratic),
+			light.Color.X(), light.Color.Y(), light.Color.Z(), light.Intensity,
+			light.Constant, light.Linear, light.Quadratic, float32(pointLight.shadowSlot),
+		)
+	}
+	return data
+}
+
+// packSpotLights lays spot lights out as fetchSpotLight reads them
+func packSpotLights(data []float32, spots []frameSpotLight) []float32 {
+	for _, spotLight := range spots {
+		light := spotLight.light
+		data = append(data,
+			spotLight.position.X(), spotLight.position.Y(), spotLight.position.Z(), lightRange(light.Constant, light.Linear, light.Quadratic),
+			spotLight.direction.X(), spotLight.direction.Y(), spotLight.direction.Z(), light.CutOff,
+			light.Color.X(), light.Color.Y(), light.Color.Z(), light.OuterCutOff,
+			light.Constant, light.Linear, light.Quadratic, float32(spotLight.shadowLayer),
+		)
+	}
+	return data
+}
@@ -0,0 +1,174 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/graphics"
+	"0xKowalski/game/resources"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Texture units of the shadow maps, after the material's
+const (
+	directionalShadowUnit = 2
+	spotShadowUnit        = 3
+	pointShadowUnit       = 4 // One unit per point shadow map
+)
+
+// shadowMap is a depth texture array or cube map with a framebuffer per layer
+type shadowMap struct {
+	texture      graphics.Texture
+	framebuffers []graphics.Framebuffer
+	resolution   int
+}
+
+// LoadShadowShader loads the depth only shader shadow maps are drawn with, until
+// it is loaded lights don't cast shadows.
+func (rs *RenderSystem) LoadShadowShader(vertexShaderPath, fragmentShaderPath string) error {
+	shaderProgram, err := graphics.InitShaderProgram(rs.Device, resources.Path(vertexShaderPath), resources.Path(fragmentShaderPath))
+	if err != nil {
+		return err
+	}
+
+	if rs.ShadowShaderProgram != nil {
+		rs.ShadowShaderProgram.Delete()
+	}
+	rs.ShadowShaderProgram = shaderProgram
+	return nil
+}
+
+// LoadPointShadowShader loads the shader point light shadow maps are drawn with,
+// which writes the distance from the light. Until it is loaded point lights
+// don't cast shadows.
+func (rs *RenderSystem) LoadPointShadowShader(vertexShaderPath, fragmentShaderPath string) error {
+	shaderProgram, err := graphics.InitShaderProgram(rs.Device, resources.Path(vertexShaderPath), resources.Path(fragmentShaderPath))
+	if err != nil {
+		return err
+	}
+
+	if rs.PointShadowShaderProgram != nil {
+		rs.PointShadowShaderProgram.Delete()
+	}
+	rs.PointShadowShaderProgram = shaderProgram
+	return nil
+}
+
+// resizeShadowMap makes sure a shadow map has the resolution and at least the
+// layers asked for, recreating it if not.
+func (rs *RenderSystem) resizeShadowMap(shadowMap *shadowMap, resolution, layers int) {
+	if shadowMap.resolution == resolution && len(shadowMap.framebuffers) >= layers {
+		return
+	}
+
+	rs.deleteShadowMap(shadowMap)
+
+	shadowMap.texture = rs.Device.CreateDepthTextureArray(resolution, resolution, layers)
+	for layer := 0; layer < layers; layer++ {
+		shadowMap.framebuffers = append(shadowMap.framebuffers, rs.Device.CreateFramebuffer(shadowMap.texture, layer))
+	}
+	shadowMap.resolution = resolution
+}
+
+func (rs *RenderSystem) resizePointShadowMap(shadowMap *shadowMap, resolution int) {
+	if shadowMap.resolution == resolution {
+		return
+	}
+
+	rs.deleteShadowMap(shadowMap)
+
+	shadowMap.texture = rs.Device.CreateDepthCubeMap(resolution)
+	for face := 0; face < 6; face++ {
+		shadowMap.framebuffers = append(shadowMap.framebuffers, rs.Device.CreateFramebuffer(shadowMap.texture, face))
+	}
+	shadowMap.resolution = resolution
+}
+
+func (rs *RenderSystem) deleteShadowMap(shadowMap *shadowMap) {
+	for _, framebuffer := range shadowMap.framebuffers {
+		rs.Device.DeleteFramebuffer(framebuffer)
+	}
+	if shadowMap.texture != 0 {
+		rs.Device.DeleteTexture(shadowMap.texture)
+	}
+	shadowMap.texture = 0
+	shadowMap.framebuffers = nil
+	shadowMap.resolution = 0
+}
+
+func (rs *RenderSystem) renderShadowMaps(shadows frameShadows, renderableComponents []*components.RenderableComponent) {
+	pointUpdates := false
+	for _, point := range shadows.points {
+		pointUpdates = pointUpdates || (point != nil && point.update)
+	}
+	if shadows.directional == nil && len(shadows.spots) == 0 && !pointUpdates {
+		return
+	}
+
+	x, y, width, height := rs.Device.Viewport()
+
+	if shadows.directional != nil || len(shadows.spots) > 0 {
+		rs.ShadowShaderProgram.Use()
+	}
+
+	if shadows.directional != nil {
+		rs.resizeShadowMap(&rs.directionalShadowMap, shadows.directional.settings.Resolution, len(shadows.directional.matrices))
+		for cascade, matrix := range shadows.directional.matrices {
+			rs.renderShadowMap(&rs.directionalShadowMap, cascade, matrix, renderableComponents)
+		}
+	}
+
+	if len(shadows.spots) > 0 {
+		rs.resizeShadowMap(&rs.spotShadowMaps, shadows.spotResolution, len(shadows.spots))
+		for layer, spot := range shadows.spots {
+			rs.renderShadowMap(&rs.spotShadowMaps, layer, spot.matrix, renderableComponents)
+		}
+	}
+
+	if pointUpdates {
+		rs.PointShadowShaderProgram.Use()
+	}
+	for slot, point := range shadows.points {
+		if point == nil || !point.update {
+			continue
+		}
+
+		rs.resizePointShadowMap(&rs.pointShadowMaps[slot], point.settings.Resolution)
+		rs.SetShaderUniformVec3("lightPosition", point.position)
+		rs.SetShaderUniformFloat("farPlane", point.far)
+		for face, matrix := range point.faces {
+			rs.renderShadowMap(&rs.pointShadowMaps[slot], face, matrix, renderableComponents)
+		}
+	}
+
+	rs.Device.BindFramebuffer(0)
+	rs.Device.SetViewport(x, y, width, height)
+}
+
+func (rs *RenderSystem) renderShadowMap(shadowMap *shadowMap, layer int, lightSpace mgl32.Mat4, renderableComponents []*components.RenderableComponent) {
+	rs.Device.BindFramebuffer(shadowMap.framebuffers[layer])
+	rs.Device.SetViewport(0, 0, shadowMap.resolution, shadowMap.resolution)
+	rs.Device.Clear()
+
+	rs.SetShaderUniformMat4("lightSpaceMatrix", lightSpace)
+
+	for _, renderableComponent := range renderableComponents {
+		if !renderableComponent.CastShadows || renderableComponent.TransformComponent == nil || renderableComponent.ModelComponent == nil {
+			continue
+		}
+
+		rs.SetShaderUniformMat4("model", renderableComponent.TransformComponent.GetRenderMatrix())
+		for _, bufferComponent := range renderableComponent.ModelComponent.BufferComponents {
+			bufferComponent.Upload(rs.Device)
+			rs.Device.DrawIndexed(bufferComponent.VertexArray, bufferComponent.IndexCount)
+		}
+	}
+}
+
+// bindShadowMaps binds the shadow maps to the units fragment.glsl samples them from
+func (rs *RenderSystem) bindShadowMaps() {
+	rs.Device.BindTexture(directionalShadowUnit, rs.directionalShadowMap.texture)
+	rs.Device.BindTexture(spotShadowUnit, rs.spotShadowMaps.texture)
+	for slot := range rs.pointShadowMaps {
+		rs.Device.BindTexture(pointShadowUnit+slot, rs.pointShadowMaps[slot].texture)
+	}
+}
@@ -4,99 +4,161 @@ import (
 	"0xKowalski/game/components"
 	"0xKowalski/game/entities"
 	"0xKowalski/game/graphics"
-	"0xKowalski/game/window"
-	"fmt"
+	"0xKowalski/game/resources"
 	"log"
 
-	"github.com/go-gl/gl/v4.3-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
+// Renderer is the System run in the Render stage, implemented by RenderSystem
+// and by SoftwareRenderer for headless screenshots.
+type Renderer interface {
+	System
+}
+
 type RenderSystem struct {
+	Device        graphics.Device
 	TextureStore  *TextureStore
 	ShaderProgram *graphics.ShaderProgram
 	EntityStore   *entities.EntityStore
-}
 
-func NewRenderSystem(win *window.Window, entityStore *entities.EntityStore) (*RenderSystem, error) {
-	err := graphics.InitOpenGL(win)
-	if err != nil {
-		log.Printf("Error initializing renderer: %v", err)
-		return nil, err
-	}
+	ShadowShaderProgram  *graphics.ShaderProgram // Shadows are only drawn once loaded with LoadShadowShader
+	directionalShadowMap shadowMap
+	spotShadowMaps       shadowMap
+
+	PointShadowShaderProgram *graphics.ShaderProgram // Point lights only cast shadows once loaded with LoadPointShadowShader
+	PointShadowBudget        int                     // Point lights whose shadow maps are redrawn each frame, 0 for all of them
+	pointShadowSlots         pointShadowSlots
+	pointShadowMaps          [maxPointShadows]shadowMap
+
+	lightBuffers *lightBuffers
+
+	pointLights entities.Query1[components.PointLightComponent]
+	spotLights  entities.Query1[components.SpotLightComponent]
+
+	// Renderables and models that can't be drawn, logged once rather than every frame
+	skippedRenderables map[*components.RenderableComponent]bool
+	skippedModels      map[*components.ModelComponent]bool
+}
 
+func NewRenderSystem(device graphics.Device, entityStore *entities.EntityStore, vertexShaderPath, fragmentShaderPath string) (*RenderSystem, error) {
 	rs := new(RenderSystem)
 
-	shaderProgram, err := graphics.InitShaderProgram("assets/shaders/vertex.glsl", "assets/shaders/fragment.glsl")
+	shaderProgram, err := graphics.InitShaderProgram(device, resources.Path(vertexShaderPath), resources.Path(fragmentShaderPath))
 	if err != nil {
 		return nil, err
 	}
 
+	rs.Device = device
 	rs.ShaderProgram = shaderProgram
 	rs.EntityStore = entityStore
-	rs.TextureStore = NewTextureStore()
+	rs.TextureStore = NewTextureStore(device)
+	rs.PointShadowBudget = defaultPointShadowBudget
+	rs.lightBuffers = newLightBuffers(device)
+	rs.initLightUniforms()
+	rs.pointLights = entities.NewQuery1[components.PointLightComponent](entityStore)
+	rs.spotLights = entities.NewQuery1[components.SpotLightComponent](entityStore)
+	rs.skippedRenderables = make(map[*components.RenderableComponent]bool)
+	rs.skippedModels = make(map[*components.ModelComponent]bool)
+
+	// GPU buffers follow the lifetime of the model component, they are uploaded when first drawn
+	entities.OnAdd(entityStore, rs.uploadModel)
+	entities.OnRemove(entityStore, rs.releaseModel)
+	entities.OnRemove(entityStore, func(entity entities.Entity, renderableComponent *components.RenderableComponent) {
+		delete(rs.skippedRenderables, renderableComponent)
+	})
 
 	return rs, nil
 }
 
-func (rs *RenderSystem) getShaderLoc(name string) (int32, error) {
-	loc := gl.GetUniformLocation(rs.ShaderProgram.ID, gl.Str(name+"\x00"))
-	if loc == -1 {
-		return -1, fmt.Errorf("Could not find the '%s' uniform location", name)
+func (rs *RenderSystem) uploadModel(entity entities.Entity, modelComponent *components.ModelComponent) {
+	rs.buildModelBuffers(modelComponent)
+}
+
+// buildModelBuffers replaces the model's buffers with one per mesh
+func (rs *RenderSystem) buildModelBuffers(modelComponent *components.ModelComponent) {
+	rs.deleteModelBuffers(modelComponent)
+
+	for _, meshComponent := range modelComponent.MeshComponents {
+		bufferComponent := components.NewBufferComponent(meshComponent.Vertices, meshComponent.Indices)
+		modelComponent.BufferComponents = append(modelComponent.BufferComponents, bufferComponent)
+	}
+}
+
+// uploadMissingModels builds buffers for models that have none for some of
+// their meshes, e.g models added before the render system was created
+func (rs *RenderSystem) uploadMissingModels(renderableComponents []*components.RenderableComponent) {
+	for _, renderableComponent := range renderableComponents {
+		modelComponent := renderableComponent.ModelComponent
+		if modelComponent != nil && len(modelComponent.BufferComponents) != len(modelComponent.MeshComponents) {
+			rs.buildModelBuffers(modelComponent)
+		}
+	}
+}
+
+func (rs *RenderSystem) releaseModel(entity entities.Entity, modelComponent *components.ModelComponent) {
+	rs.deleteModelBuffers(modelComponent)
+	delete(rs.skippedModels, modelComponent)
+}
+
+func (rs *RenderSystem) deleteModelBuffers(modelComponent *components.ModelComponent) {
+	for _, bufferComponent := range modelComponent.BufferComponents {
+		bufferComponent.Delete()
 	}
-	return loc, nil
+	modelComponent.BufferComponents = nil
 }
 
 func (rs *RenderSystem) SetShaderUniformMat4(name string, value mgl32.Mat4) {
-	loc, err := rs.getShaderLoc(name)
-	if err != nil {
+	if err := rs.Device.SetUniformMat4(name, value); err != nil {
 		log.Println(err)
-		return
 	}
-
-	gl.UniformMatrix4fv(loc, 1, false, &value[0])
 }
 
 func (rs *RenderSystem) SetShaderUniformVec3(name string, value mgl32.Vec3) {
-	loc, err := rs.getShaderLoc(name)
-	if err != nil {
+	if err := rs.Device.SetUniformVec3(name, value); err != nil {
 		log.Println(err)
-		return
 	}
-
-	gl.Uniform3f(loc, value.X(), value.Y(), value.Z())
 }
 
 func (rs *RenderSystem) SetShaderUniformFloat(name string, value float32) {
-	loc, err := rs.getShaderLoc(name)
-	if err != nil {
+	if err := rs.Device.SetUniformFloat(name, value); err != nil {
 		log.Println(err)
-		return
 	}
-
-	gl.Uniform1f(loc, value)
 }
 
 func (rs *RenderSystem) SetShaderUniformInt(name string, value int32) {
-	loc, err := rs.getShaderLoc(name)
-	if err != nil {
+	if err := rs.Device.SetUniformInt(name, value); err != nil {
 		log.Println(err)
-		return
 	}
-
-	gl.Uniform1i(loc, value)
 }
 
 func (rs *RenderSystem) renderEntity(comp *components.RenderableComponent) {
-	modelMatrix := comp.TransformComponent.GetModelMatrix()
-	rs.SetShaderUniformMat4("model", modelMatrix)
-
 	if comp.TransformComponent == nil || comp.ModelComponent == nil {
-		log.Println("Mesh, buffer, transform or material component is nil, cannot render entity")
+		if !rs.skippedRenderables[comp] {
+			log.Println("Transform or model component is nil, cannot render entity")
+			rs.skippedRenderables[comp] = true
+		}
+		return
+	}
+
+	if len(comp.ModelComponent.MaterialComponents) != len(comp.ModelComponent.MeshComponents) {
+		if !rs.skippedModels[comp.ModelComponent] {
+			log.Printf("Model has %d meshes but %d materials, cannot render entity", len(comp.ModelComponent.MeshComponents), len(comp.ModelComponent.MaterialComponents))
+			rs.skippedModels[comp.ModelComponent] = true
+		}
 		return
 	}
 
-	for i, meshComponent := range comp.ModelComponent.MeshComponents {
+	modelMatrix := comp.TransformComponent.GetRenderMatrix()
+	rs.SetShaderUniformMat4("model", modelMatrix)
+
+	receiveShadows := int32(0)
+	if comp.ReceiveShadows {
+		receiveShadows = 1
+	}
+	rs.SetShaderUniformInt("receiveShadows", receiveShadows)
+
+	for i := range comp.ModelComponent.MeshComponents {
 		materialComponent := comp.ModelComponent.MaterialComponents[i]
 		bufferComponent := comp.ModelComponent.BufferComponents[i]
 
@@ -109,133 +171,88 @@ func (rs *RenderSystem) renderEntity(comp *components.RenderableComponent) {
 			log.Printf("Error getting specularmap texture: %v", err)
 		}
 
-		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, diffuseMap)
+		rs.Device.BindTexture(0, diffuseMap)
 		rs.SetShaderUniformInt("material.diffuseMap", 0)
 
-		gl.ActiveTexture(gl.TEXTURE1)
-		gl.BindTexture(gl.TEXTURE_2D, specularMap)
+		rs.Device.BindTexture(1, specularMap)
 		rs.SetShaderUniformInt("material.specularMap", 1)
 
 		rs.SetShaderUniformFloat("material.shininess", materialComponent.Shininess)
 
-		gl.BindVertexArray(bufferComponent.VAO)
-		gl.DrawElements(gl.TRIANGLES, int32(len(meshComponent.Indices)), gl.UNSIGNED_INT, gl.Ptr(nil))
-		gl.BindVertexArray(0)
+		bufferComponent.Upload(rs.Device)
+		rs.Device.DrawIndexed(bufferComponent.VertexArray, bufferComponent.IndexCount)
 	}
 
 }
 
-func (rs *RenderSystem) Update() {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT) // Clear the color and depth buffers
-	gl.ClearColor(0.0, 0.0, 0.1, 0.0)                   // Set background color to black
-
-	rs.ShaderProgram.Use()
-
-	cameraEntity := rs.EntityStore.GetEntityWithComponentType(&components.CameraComponent{})
+// clear clears the color and depth buffers to the background color
+func (rs *RenderSystem) clear() {
+	rs.Device.SetClearColor(0.0, 0.0, 0.1, 0.0)
+	rs.Device.Clear()
+}
 
-	// get camera component
-	cameraComponentInterface := rs.EntityStore.GetComponent(cameraEntity, &components.CameraComponent{})
-	cameraComponent, cameraOk := cameraComponentInterface.(*components.CameraComponent)
+func (rs *RenderSystem) Update(deltaTime float32) {
+	// Re-upload models whose meshes were marked as changed this frame
+	for _, entity := range entities.Changed[components.ModelComponent](rs.EntityStore) {
+		modelComponent, _ := entities.Get[components.ModelComponent](rs.EntityStore, entity)
+		rs.buildModelBuffers(modelComponent)
+	}
 
-	transformComponentInterface := rs.EntityStore.GetComponent(cameraEntity, &components.TransformComponent{})
-	transformComponent, transformComponentOk := transformComponentInterface.(*components.TransformComponent)
+	// Without a camera there is nothing to draw but the background
+	cameraEntities := rs.EntityStore.GetEntitiesWithComponentType(&components.CameraComponent{})
+	if len(cameraEntities) == 0 {
+		rs.clear()
+		return
+	}
 
+	cameraComponent, cameraOk := entities.Get[components.CameraComponent](rs.EntityStore, cameraEntities[0])
+	transformComponent, transformComponentOk := entities.Get[components.TransformComponent](rs.EntityStore, cameraEntities[0])
 	if !cameraOk || !transformComponentOk {
-		log.Println(cameraEntity, cameraComponentInterface, transformComponentInterface)
 		log.Fatalf("Failed to get camera component")
 	}
 
-	rs.SetShaderUniformVec3("viewPos", transformComponent.Position)
-
-	viewMatrix := cameraComponent.GetViewMatrix(transformComponent.Position)
-	rs.SetShaderUniformMat4("view", viewMatrix)
-
+	cameraPosition := transformComponent.GetWorldPosition()
+	viewMatrix := cameraComponent.GetViewMatrix(cameraPosition)
 	projectionMatrix := cameraComponent.GetProjectionMatrix()
-	rs.SetShaderUniformMat4("projection", projectionMatrix)
-
-	// Get renderable components and render them
-	renderableComponents := rs.EntityStore.GetAllComponents(&components.RenderableComponent{})
-	for _, renderableComponent := range renderableComponents {
-		comp, ok := renderableComponent.(*components.RenderableComponent)
-
-		if ok {
-			rs.renderEntity(comp)
-		} else {
-			log.Println("Failed to parse render component")
-		}
-
-	}
 
-	// Lighting
 	// Ambient light (1 max)
-	ambientLightComponents := rs.EntityStore.GetAllComponents(&components.AmbientLightComponent{})
-	if len(ambientLightComponents) > 1 {
-		log.Fatalf("Exceeded max amount of ambient lights - Max: 1, Used: %d", len(ambientLightComponents))
-
-	}
-	if len(ambientLightComponents) == 1 {
-		ambientLightComponentInterface := ambientLightComponents[0]
-		ambientLightComponent, ambientOk := ambientLightComponentInterface.(*components.AmbientLightComponent)
-		if ambientOk {
-			rs.SetShaderUniformVec3("ambientLight.color", ambientLightComponent.Color)
-			rs.SetShaderUniformFloat("ambientLight.intensity", ambientLightComponent.Intensity)
-		}
+	ambientLightCount := len(entities.GetAll[components.AmbientLightComponent](rs.EntityStore))
+	if ambientLightCount > 1 {
+		log.Fatalf("Exceeded max amount of ambient lights - Max: 1, Used: %d", ambientLightCount)
 	}
-
 	// Directional Light (1 max) - Might want to increase this, e.g, sun & moon? multiple suns?
-	directionalLightComponents := rs.EntityStore.GetAllComponents(&components.DirectionalLightComponent{})
-	if len(directionalLightComponents) > 1 {
-		log.Fatalf("Exceeded max amount of Directional lights - Max: 1, Used: %d", len(directionalLightComponents))
-	}
-	if len(directionalLightComponents) == 1 {
-		directionalLightComponentInterface := directionalLightComponents[0]
-		directionalLightComponent, directionalOk := directionalLightComponentInterface.(*components.DirectionalLightComponent)
-		if directionalOk {
-			rs.SetShaderUniformVec3("directionalLight.direction", directionalLightComponent.Direction)
-			rs.SetShaderUniformVec3("directionalLight.color", directionalLightComponent.Color)
-			rs.SetShaderUniformFloat("directionalLight.intensity", directionalLightComponent.Intensity)
-		}
+	directionalLightCount := len(entities.GetAll[components.DirectionalLightComponent](rs.EntityStore))
+	if directionalLightCount > 1 {
+		log.Fatalf("Exceeded max amount of Directional lights - Max: 1, Used: %d", directionalLightCount)
 	}
 
-	// Point Lights (idk max yet)
-	pointLightComponents := rs.EntityStore.GetAllComponents(&components.PointLightComponent{})
-	rs.SetShaderUniformInt("pointLightsCount", int32(len(pointLightComponents)))
-	if len(pointLightComponents) > 0 {
-		for index, pointLightComponentInterface := range pointLightComponents {
-			pointLightComponent, pointLightOk := pointLightComponentInterface.(*components.PointLightComponent)
-
-			if pointLightOk {
-				rs.SetShaderUniformVec3(fmt.Sprintf("pointLights[%d].position", index), pointLightComponent.Position)
-				rs.SetShaderUniformVec3(fmt.Sprintf("pointLights[%d].color", index), pointLightComponent.Color)
-				rs.SetShaderUniformFloat(fmt.Sprintf("pointLights[%d].intensity", index), pointLightComponent.Intensity)
-				rs.SetShaderUniformFloat(fmt.Sprintf("pointLights[%d].constant", index), pointLightComponent.Constant)
-				rs.SetShaderUniformFloat(fmt.Sprintf("pointLights[%d].linear", index), pointLightComponent.Linear)
-				rs.SetShaderUniformFloat(fmt.Sprintf("pointLights[%d].quadratic", index), pointLightComponent.Quadratic)
-			}
-		}
+	renderableComponents := entities.GetAll[components.RenderableComponent](rs.EntityStore)
+	rs.uploadMissingModels(renderableComponents)
+
+	// Shadow maps are drawn first, into their own framebuffers
+	lights := gatherLights(rs.EntityStore, rs.pointLights, rs.spotLights)
+	var shadows frameShadows
+	if rs.ShadowShaderProgram != nil {
+		shadows = newFrameShadows(&lights, cameraComponent, viewMatrix)
+	}
+	if rs.PointShadowShaderProgram != nil {
+		shadows.points = rs.pointShadowSlots.assign(&lights, rs.PointShadowBudget)
 	}
+	rs.renderShadowMaps(shadows, renderableComponents)
 
-	// Spot Lights (idk max yet)
-	spotLightComponents := rs.EntityStore.GetAllComponents(&components.SpotLightComponent{})
-	rs.SetShaderUniformInt("spotLightsCount", int32(len(spotLightComponents)))
-	if len(spotLightComponents) > 0 {
-		for index, spotLightComponentInterface := range spotLightComponents {
-			spotLightComponent, spotLightOk := spotLightComponentInterface.(*components.SpotLightComponent)
+	rs.clear()
+
+	rs.ShaderProgram.Use()
 
-			if spotLightOk {
-				rs.SetShaderUniformVec3(fmt.Sprintf("spotLights[%d].position", index), spotLightComponent.Position)
-				rs.SetShaderUniformVec3(fmt.Sprintf("spotLights[%d].color", index), spotLightComponent.Color)
-				rs.SetShaderUniformVec3(fmt.Sprintf("spotLights[%d].direction", index), spotLightComponent.Direction)
-				rs.SetShaderUniformFloat(fmt.Sprintf("spotLights[%d].cutOff", index), spotLightComponent.CutOff)
-				rs.SetShaderUniformFloat(fmt.Sprintf("spotLights[%d].outerCutOff", index), spotLightComponent.OuterCutOff)
+	rs.SetShaderUniformVec3("viewPos", cameraPosition)
+	rs.SetShaderUniformMat4("view", viewMatrix)
+	rs.SetShaderUniformMat4("projection", projectionMatrix)
 
-				rs.SetShaderUniformFloat(fmt.Sprintf("spotLights[%d].intensity", index), spotLightComponent.Intensity)
-				rs.SetShaderUniformFloat(fmt.Sprintf("spotLights[%d].constant", index), spotLightComponent.Constant)
-				rs.SetShaderUniformFloat(fmt.Sprintf("spotLights[%d].linear", index), spotLightComponent.Linear)
-				rs.SetShaderUniformFloat(fmt.Sprintf("spotLights[%d].quadratic", index), spotLightComponent.Quadratic)
+	rs.uploadLights(lights, shadows, cameraComponent, viewMatrix)
+	rs.bindShadowMaps()
 
-			}
-		}
+	// Get renderable components and render them
+	for _, renderableComponent := range renderableComponents {
+		rs.renderEntity(renderableComponent)
 	}
 }
@@ -0,0 +1,278 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"0xKowalski/game/graphics"
+	"0xKowalski/game/resources"
+	"bytes"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// renderScene is a camera looking at two cubes, lit by an ambient and a point light
+type renderScene struct {
+	store  *entities.EntityStore
+	camera *entities.Freecam
+	cubes  map[mgl32.Mat4]entities.Entity // By model matrix
+}
+
+// newRenderScene fills a store, after the render system's hooks were added
+func newRenderScene(store *entities.EntityStore) *renderScene {
+	scene := &renderScene{store: store, cubes: make(map[mgl32.Mat4]entities.Entity)}
+
+	scene.camera = store.NewFreecamEntity(mgl32.Vec3{0, 1, 6})
+	scene.camera.CameraComponent.AspectRatio = 16.0 / 9.0
+
+	for _, position := range []mgl32.Vec3{{-1, 0, 0}, {1.5, 0.5, -1}} {
+		cube := store.NewCubeEntity(position, 1)
+		transform, _ := entities.Get[components.TransformComponent](store, *cube)
+		scene.cubes[transform.GetRenderMatrix()] = *cube
+	}
+
+	store.AddComponent(store.NewEntity(), components.NewAmbientLightComponent(mgl32.Vec3{1, 1, 1}, 0.2))
+	light := store.NewEntity()
+	store.AddComponent(light, components.NewTransformComponent(mgl32.Vec3{0, 3, 0}))
+	store.AddComponent(light, components.NewPointLightComponent(mgl32.Vec3{}, mgl32.Vec3{1, 1, 1}, 1, 1, 0.09, 0.032))
+
+	return scene
+}
+
+func newRecordingRenderSystem(t *testing.T, store *entities.EntityStore) (*RenderSystem, *graphics.RecordingDevice) {
+	t.Helper()
+	resources.SetRoot("")
+
+	device := graphics.NewRecordingDevice()
+	device.SetViewport(0, 0, 1280, 720)
+
+	rs, err := NewRenderSystem(device, store, "assets/shaders/vertex.glsl", "assets/shaders/fragment.glsl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs, device
+}
+
+// callNames lists the calls with the given names, in order
+func callNames(device *graphics.RecordingDevice, names ...string) []string {
+	var calls []string
+	for _, call := range device.Calls {
+		for _, name := range names {
+			if call.Name == name {
+				calls = append(calls, name)
+			}
+		}
+	}
+	return calls
+}
+
+func TestRenderSystemDrawsScene(t *testing.T) {
+	store := entities.NewEntityStore()
+	rs, device := newRecordingRenderSystem(t, store)
+	scene := newRenderScene(store)
+	shader := rs.ShaderProgram.Shader
+
+	for _, block := range []struct {
+		name    string
+		binding int
+	}{{"Lights", lightsBlockBinding}, {"Shadows", shadowsBlockBinding}} {
+		if binding, ok := device.UniformBlock(shader, block.name); !ok || binding != block.binding {
+			t.Errorf("%s block bound to %d, %v, want %d", block.name, binding, ok, block.binding)
+		}
+	}
+
+	device.Reset()
+	rs.Update(1.0 / 60)
+
+	if len(device.Errors) != 0 {
+		t.Fatalf("device errors %v", device.Errors)
+	}
+
+	// Cleared once, then both cubes drawn after the lights are bound
+	want := []string{"Clear", "UseShader", "BindUniformBuffer", "BindUniformBuffer", "DrawIndexed", "DrawIndexed"}
+	if calls := append(callNames(device, want[:3]...), callNames(device, "DrawIndexed")...); !slices.Equal(calls, want) {
+		t.Errorf("calls %q, want %q", calls, want)
+	}
+
+	if len(device.Draws) != len(scene.cubes) {
+		t.Fatalf("%d draws, want %d", len(device.Draws), len(scene.cubes))
+	}
+
+	camera := scene.camera.CameraComponent
+	cameraPosition := scene.camera.TransformComponent.GetWorldPosition()
+	drawn := make(map[entities.Entity]bool)
+	for _, draw := range device.Draws {
+		if draw.Shader != shader || draw.Framebuffer != 0 || draw.IndexCount != 36 {
+			t.Errorf("drew %d indices with shader %d into framebuffer %d", draw.IndexCount, draw.Shader, draw.Framebuffer)
+		}
+
+		model, _ := draw.Uniforms["model"].(mgl32.Mat4)
+		cube, ok := scene.cubes[model]
+		if !ok || drawn[cube] {
+			t.Errorf("drew model matrix %v", model)
+		}
+		drawn[cube] = true
+
+		if view := draw.Uniforms["view"]; view != camera.GetViewMatrix(cameraPosition) {
+			t.Errorf("view %v", view)
+		}
+		if projection := draw.Uniforms["projection"]; projection != camera.GetProjectionMatrix() {
+			t.Errorf("projection %v", projection)
+		}
+		if viewPos := draw.Uniforms["viewPos"]; viewPos != cameraPosition {
+			t.Errorf("viewPos %v, want %v", viewPos, cameraPosition)
+		}
+
+		if draw.Uniforms["material.diffuseMap"] != int32(0) || draw.Uniforms["material.specularMap"] != int32(1) {
+			t.Errorf("material samplers %v and %v", draw.Uniforms["material.diffuseMap"], draw.Uniforms["material.specularMap"])
+		}
+		if draw.Textures[0] == 0 || draw.Textures[1] == 0 || draw.Textures[0] == draw.Textures[1] {
+			t.Errorf("material textures %v", draw.Textures)
+		}
+
+		if draw.UniformBuffers[lightsBlockBinding] != rs.lightBuffers.lightsBlock ||
+			draw.UniformBuffers[shadowsBlockBinding] != rs.lightBuffers.shadowsBlock {
+			t.Errorf("uniform buffers %v", draw.UniformBuffers)
+		}
+		if draw.Textures[pointLightsUnit] != rs.lightBuffers.textures[0] {
+			t.Errorf("point lights not bound at unit %d, textures %v", pointLightsUnit, draw.Textures)
+		}
+	}
+
+	// Three texels of four floats for the one point light
+	if size := device.Buffers[rs.lightBuffers.pointLights].Size; size != 3*4*4 {
+		t.Errorf("point light buffer is %d bytes, want %d", size, 3*4*4)
+	}
+
+	// Meshes and textures are uploaded once, not every frame
+	device.Reset()
+	rs.Update(1.0 / 60)
+	if created := callNames(device, "CreateBuffer", "CreateVertexArray", "CreateTexture"); len(created) != 0 {
+		t.Errorf("second frame created %q", created)
+	}
+	if len(device.Draws) != len(scene.cubes) || len(device.Errors) != 0 {
+		t.Errorf("second frame drew %d times with errors %v", len(device.Draws), device.Errors)
+	}
+}
+
+func TestRenderSystemDrawsShadowsFirst(t *testing.T) {
+	store := entities.NewEntityStore()
+	rs, device := newRecordingRenderSystem(t, store)
+	scene := newRenderScene(store)
+	sun := components.NewDirectionalLightComponent(mgl32.Vec3{-0.3, -1, -0.2}, mgl32.Vec3{1, 1, 1}, 0.8)
+	sun.CastShadows = true
+	store.AddComponent(store.NewEntity(), sun)
+
+	if err := rs.LoadShadowShader("assets/shaders/shadow.vertex.glsl", "assets/shaders/shadow.fragment.glsl"); err != nil {
+		t.Fatal(err)
+	}
+
+	device.Reset()
+	rs.Update(1.0 / 60)
+	if len(device.Errors) != 0 {
+		t.Fatalf("device errors %v", device.Errors)
+	}
+
+	shadowDraws, sceneDraws := 0, 0
+	for _, draw := range device.Draws {
+		if draw.Framebuffer == 0 {
+			sceneDraws++
+			continue
+		}
+
+		if sceneDraws > 0 {
+			t.Fatal("shadow map drawn after the scene")
+		}
+		if draw.Shader != rs.ShadowShaderProgram.Shader {
+			t.Errorf("shadow map drawn with shader %d", draw.Shader)
+		}
+		if _, ok := draw.Uniforms["lightSpaceMatrix"]; !ok {
+			t.Errorf("shadow map drawn without a light space matrix, uniforms %v", draw.Uniforms)
+		}
+		shadowDraws++
+	}
+
+	// Every cube into every cascade
+	if shadowDraws != len(scene.cubes)*components.DefaultShadowSettings().Cascades || sceneDraws != len(scene.cubes) {
+		t.Errorf("%d shadow and %d scene draws", shadowDraws, sceneDraws)
+	}
+}
+
+// Models added before the render system have no buffers until the first frame,
+// and a model with fewer materials than meshes is skipped rather than drawn,
+// logging that only once
+func TestRenderSystemDrawsExistingModels(t *testing.T) {
+	store := entities.NewEntityStore()
+	scene := newRenderScene(store)
+	rs, device := newRecordingRenderSystem(t, store)
+
+	device.Reset()
+	rs.Update(1.0 / 60)
+	if len(device.Draws) != len(scene.cubes) || len(device.Errors) != 0 {
+		t.Fatalf("drew %d times with errors %v, want %d draws", len(device.Draws), device.Errors, len(scene.cubes))
+	}
+
+	var skipped entities.Entity
+	for _, cube := range scene.cubes {
+		skipped = cube
+		break
+	}
+	model, _ := entities.Get[components.ModelComponent](store, skipped)
+	model.MaterialComponents = nil
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	for frame := 0; frame < 3; frame++ {
+		device.Reset()
+		rs.Update(1.0 / 60)
+		if len(device.Draws) != len(scene.cubes)-1 || len(device.Errors) != 0 {
+			t.Fatalf("drew %d times with errors %v, want %d draws", len(device.Draws), device.Errors, len(scene.cubes)-1)
+		}
+	}
+	if count := strings.Count(logged.String(), "cannot render entity"); count != 1 {
+		t.Errorf("skipped model logged %d times, want once", count)
+	}
+	transform, _ := entities.Get[components.TransformComponent](store, skipped)
+	if device.Draws[0].Uniforms["model"] == transform.GetRenderMatrix() {
+		t.Error("drew the model without materials")
+	}
+}
+
+// Clusters are capped at the buffer texture size the device reports
+func TestRenderSystemCapsClustersAtDeviceLimit(t *testing.T) {
+	resources.SetRoot("")
+	device := graphics.NewRecordingDevice()
+	device.SetViewport(0, 0, 1280, 720)
+	device.BufferTextureSize = 4000 // Above the grid's 3456 texels
+
+	store := entities.NewEntityStore()
+	rs, err := NewRenderSystem(device, store, "assets/shaders/vertex.glsl", "assets/shaders/fragment.glsl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRenderScene(store)
+	for i := 0; i < 50; i++ {
+		light := store.NewEntity()
+		store.AddComponent(light, components.NewPointLightComponent(mgl32.Vec3{float32(i%10) - 5, 1, float32(-i / 10)}, mgl32.Vec3{1, 1, 1}, 1, 1, 0.09, 0.032))
+	}
+
+	device.Reset()
+	rs.Update(1.0 / 60)
+	if len(device.Errors) != 0 {
+		t.Fatalf("device errors %v", device.Errors)
+	}
+
+	clusters := &rs.lightBuffers.clusters
+	if clusters.maxIndices != device.BufferTextureSize || !clusters.truncated {
+		t.Errorf("clusters limited to %d indices, truncated %v, want %d", clusters.maxIndices, clusters.truncated, device.BufferTextureSize)
+	}
+	if size := device.Buffers[rs.lightBuffers.clusterLights].Size; size == 0 || size > device.BufferTextureSize*4 {
+		t.Errorf("cluster light buffer is %d bytes, want at most %d", size, device.BufferTextureSize*4)
+	}
+}
@@ -0,0 +1,319 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Limits of the shadow arrays in fragment.glsl
+const (
+	maxShadowCascades = 4
+	maxSpotShadows    = 4
+	maxPointShadows   = 4
+)
+
+// Point lights whose shadow maps are redrawn each frame by default
+const defaultPointShadowBudget = 2
+
+// How far cascade splits lean towards a logarithmic split, which gives close
+// cascades more detail, over an even one.
+const cascadeSplitLambda = 0.75
+
+// frameShadows are the shadow maps a frame draws, shared by the render system
+// and the software renderer so both shadow the same way.
+type frameShadows struct {
+	directional *directionalShadow // nil when the directional light casts no shadows
+	spots       []spotShadow       // One layer of the spot shadow maps each
+
+	spotResolution int // Spot shadow maps share a texture array, at the largest resolution asked for
+
+	points [maxPointShadows]*pointShadow // By slot, nil for slots without a drawn map
+}
+
+type directionalShadow struct {
+	settings components.ShadowSettings
+	matrices []mgl32.Mat4 // Light space matrix of each cascade
+	splits   []float32    // View space distance each cascade reaches
+}
+
+type spotShadow struct {
+	settings components.ShadowSettings
+	matrix   mgl32.Mat4
+}
+
+// newFrameShadows works out the shadow maps for the frame's shadow casting
+// lights and gives the spot lights their layers.
+func newFrameShadows(lights *frameLights, camera *components.CameraComponent, view mgl32.Mat4) frameShadows {
+	var shadows frameShadows
+
+	if lights.directional != nil {
+		shadows.directional = newDirectionalShadow(lights.directional, camera, view)
+	}
+
+	for i := range lights.spots {
+		spot := &lights.spots[i]
+		if !spot.light.CastShadows || spot.direction.Len() == 0 || len(shadows.spots) == maxSpotShadows {
+			continue
+		}
+
+		spot.shadowLayer = len(shadows.spots)
+		shadow := newSpotShadow(spot.light, spot.position, spot.direction)
+		shadows.spots = append(shadows.spots, shadow)
+		shadows.spotResolution = max(shadows.spotResolution, shadow.settings.Resolution)
+	}
+
+	return shadows
+}
+
+// shadowSettings fills in settings left at zero, e.g by lights created without a constructor
+func shadowSettings(settings, defaults components.ShadowSettings) components.ShadowSettings {
+	if settings.Resolution <= 0 {
+		settings.Resolution = defaults.Resolution
+	}
+	if settings.Cascades <= 0 {
+		settings.Cascades = defaults.Cascades
+	}
+	settings.Cascades = min(settings.Cascades, maxShadowCascades)
+	if settings.Distance <= 0 {
+		settings.Distance = defaults.Distance
+	}
+	settings.PCFRadius = max(settings.PCFRadius, 0)
+	return settings
+}
+
+func newDirectionalShadow(light *components.DirectionalLightComponent, camera *components.CameraComponent, view mgl32.Mat4) *directionalShadow {
+	if !light.CastShadows || light.Direction.Len() == 0 {
+		return nil
+	}
+
+	settings := shadowSettings(light.Shadow, components.DefaultShadowSettings())
+	shadow := &directionalShadow{settings: settings}
+
+	near := camera.NearClip
+	far := min(camera.FarClip, settings.Distance)
+	lightDir := light.Direction.Normalize()
+
+	cascadeNear := near
+	for i := 1; i <= settings.Cascades; i++ {
+		fraction := float32(i) / float32(settings.Cascades)
+		logSplit := near * float32(math.Pow(float64(far/near), float64(fraction)))
+		evenSplit := near + (far-near)*fraction
+		cascadeFar := cascadeSplitLambda*logSplit + (1-cascadeSplitLambda)*evenSplit
+
+		shadow.matrices = append(shadow.matrices, cascadeMatrix(lightDir, camera, view, cascadeNear, cascadeFar, settings))
+		shadow.splits = append(shadow.splits, cascadeFar)
+		cascadeNear = cascadeFar
+	}
+
+	return shadow
+}
+
+// cascadeMatrix fits an orthographic light view around the part of the camera's
+// view between near and far. The fit is a sphere, snapped to whole texels, so
+// shadow edges don't shimmer as the camera turns and moves.
+func cascadeMatrix(lightDir mgl32.Vec3, camera *components.CameraComponent, view mgl32.Mat4, near, far float32, settings components.ShadowSettings) mgl32.Mat4 {
+	projection := mgl32.Perspective(mgl32.DegToRad(camera.FieldOfView), camera.AspectRatio, near, far)
+	inverse := projection.Mul4(view).Inv()
+
+	var corners [8]mgl32.Vec3
+	var center mgl32.Vec3
+	for i := range corners {
+		ndc := mgl32.Vec4{float32(i&1)*2 - 1, float32(i>>1&1)*2 - 1, float32(i>>2&1)*2 - 1, 1}
+		corner := inverse.Mul4x1(ndc)
+		corners[i] = corner.Vec3().Mul(1 / corner.W())
+		center = center.Add(corners[i])
+	}
+	center = center.Mul(1.0 / 8)
+
+	var radius float32
+	for _, corner := range corners {
+		radius = max(radius, corner.Sub(center).Len())
+	}
+	radius = float32(math.Ceil(float64(radius)*16) / 16)
+
+	// Pulled back so casters between the light and the cascade are drawn
+	casterMargin := settings.Distance
+	eye := center.Sub(lightDir.Mul(radius + casterMargin))
+	lightView := mgl32.LookAtV(eye, center, shadowUp(lightDir))
+	lightProjection := mgl32.Ortho(-radius, radius, -radius, radius, 0, 2*radius+casterMargin)
+
+	// Snap the world origin to a texel
+	matrix := lightProjection.Mul4(lightView)
+	halfResolution := float32(settings.Resolution) / 2
+	origin := matrix.Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Mul(halfResolution)
+	lightProjection[12] += (float32(math.Round(float64(origin.X()))) - origin.X()) / halfResolution
+	lightProjection[13] += (float32(math.Round(float64(origin.Y()))) - origin.Y()) / halfResolution
+
+	return lightProjection.Mul4(lightView)
+}
+
+func newSpotShadow(light *components.SpotLightComponent, position, direction mgl32.Vec3) spotShadow {
+	settings := shadowSettings(light.Shadow, components.DefaultShadowSettings())
+	direction = direction.Normalize()
+
+	// Cover the outer cone out to where the light no longer reaches
+	fov := 2 * float32(math.Acos(float64(mgl32.Clamp(light.OuterCutOff, 0.05, 1))))
+	fov = mgl32.Clamp(fov, mgl32.DegToRad(1), mgl32.DegToRad(170))
+	projection := mgl32.Perspective(fov, 1, 0.1, lightRange(light.Constant, light.Linear, light.Quadratic))
+	view := mgl32.LookAtV(position, position.Add(direction), shadowUp(direction))
+
+	return spotShadow{
+		settings: settings,
+		matrix:   projection.Mul4(view),
+	}
+}
+
+// lightRange is the distance at which attenuation drops below 1/256
+func lightRange(constant, linear, quadratic float32) float32 {
+	const threshold = 256
+	var distance float32 = 100
+	if quadratic > 0 {
+		discriminant := linear*linear - 4*quadratic*(constant-threshold)
+		distance = (-linear + float32(math.Sqrt(float64(max(discriminant, 0))))) / (2 * quadratic)
+	} else if linear > 0 {
+		distance = (threshold - constant) / linear
+	}
+	return mgl32.Clamp(distance, 1, 1000)
+}
+
+// shadowUp is an up vector for a light's view that isn't parallel to it
+func shadowUp(direction mgl32.Vec3) mgl32.Vec3 {
+	if math.Abs(float64(direction.Y())) > 0.99 {
+		return mgl32.Vec3{0, 0, 1}
+	}
+	return mgl32.Vec3{0, 1, 0}
+}
+
+// pointShadow is a point light's cube shadow map, drawn at position out to far
+type pointShadow struct {
+	settings components.ShadowSettings
+	position mgl32.Vec3
+	far      float32
+	faces    [6]mgl32.Mat4 // Light space matrix of each cube face, +X, -X, +Y, -Y, +Z, -Z
+	update   bool          // Redrawn this frame, otherwise the map drawn before is used
+}
+
+// Look direction and up vector of each cube face, as cube maps are sampled
+var cubeFaces = [6][2]mgl32.Vec3{
+	{{1, 0, 0}, {0, -1, 0}},
+	{{-1, 0, 0}, {0, -1, 0}},
+	{{0, 1, 0}, {0, 0, 1}},
+	{{0, -1, 0}, {0, 0, -1}},
+	{{0, 0, 1}, {0, -1, 0}},
+	{{0, 0, -1}, {0, -1, 0}},
+}
+
+func newPointShadow(settings components.ShadowSettings, position mgl32.Vec3, far float32) *pointShadow {
+	shadow := &pointShadow{settings: settings, position: position, far: far}
+	projection := mgl32.Perspective(mgl32.DegToRad(90), 1, 0.1, far)
+	for face, axes := range cubeFaces {
+		shadow.faces[face] = projection.Mul4(mgl32.LookAtV(position, position.Add(axes[0]), axes[1]))
+	}
+	return shadow
+}
+
+// pointShadowSlots keep which point light each cube shadow map belongs to
+// between frames. Drawing six faces is costly, so only a budget of lights are
+// redrawn each frame and the rest reuse the map they last drew.
+type pointShadowSlots [maxPointShadows]pointShadowSlot
+
+type pointShadowSlot struct {
+	light  *components.PointLightComponent
+	shadow *pointShadow // Last drawn, nil until the first
+	age    int          // Frames since the map was drawn
+}
+
+// assign gives shadow casting point lights their slots and picks which maps to
+// redraw: ones never drawn first, then ones whose light moved or changed, then
+// the longest since drawn. A budget of 0 redraws every map.
+func (slots *pointShadowSlots) assign(lights *frameLights, budget int) [maxPointShadows]*pointShadow {
+	casters := make(map[*components.PointLightComponent]int)
+	for i, pointLight := range lights.points {
+		if pointLight.light.CastShadows {
+			casters[pointLight.light] = i
+		}
+	}
+
+	// Lights that were removed or stopped casting free their slot
+	for slot := range slots {
+		if _, ok := casters[slots[slot].light]; !ok {
+			slots[slot] = pointShadowSlot{}
+		}
+	}
+	for _, pointLight := range lights.points {
+		if !pointLight.light.CastShadows || slots.find(pointLight.light) >= 0 {
+			continue
+		}
+		if slot := slots.find(nil); slot >= 0 {
+			slots[slot].light = pointLight.light
+		}
+	}
+
+	type candidate struct {
+		slot     int
+		priority int
+		shadow   *pointShadow
+	}
+	var candidates []candidate
+	for slot := range slots {
+		light := slots[slot].light
+		if light == nil {
+			continue
+		}
+
+		settings := shadowSettings(light.Shadow, components.DefaultPointShadowSettings())
+		position := lights.points[casters[light]].position
+		far := lightRange(light.Constant, light.Linear, light.Quadratic)
+
+		drawn := slots[slot].shadow
+		priority := 0
+		if drawn == nil {
+			priority = 2
+		} else if drawn.position != position || drawn.far != far || drawn.settings != settings {
+			priority = 1
+		}
+		candidates = append(candidates, candidate{slot, priority, newPointShadow(settings, position, far)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].priority != candidates[j].priority {
+			return candidates[i].priority > candidates[j].priority
+		}
+		return slots[candidates[i].slot].age > slots[candidates[j].slot].age
+	})
+
+	var shadows [maxPointShadows]*pointShadow
+	for i, candidate := range candidates {
+		slot := &slots[candidate.slot]
+		if budget <= 0 || i < budget {
+			candidate.shadow.update = true
+			slot.shadow = candidate.shadow
+			slot.age = 0
+		} else {
+			slot.age++
+		}
+
+		// Lights are unshadowed until their map is first drawn
+		if slot.shadow == nil {
+			continue
+		}
+		shadow := *slot.shadow
+		shadow.update = candidate.shadow.update
+		shadows[candidate.slot] = &shadow
+		lights.points[casters[slot.light]].shadowSlot = candidate.slot
+	}
+
+	return shadows
+}
+
+func (slots *pointShadowSlots) find(light *components.PointLightComponent) int {
+	for slot := range slots {
+		if slots[slot].light == light {
+			return slot
+		}
+	}
+	return -1
+}
@@ -0,0 +1,207 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"slices"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func newShadowPointLights(count int) []*components.PointLightComponent {
+	var lights []*components.PointLightComponent
+	for i := 0; i < count; i++ {
+		light := components.NewPointLightComponent(mgl32.Vec3{float32(i), 2, 0}, mgl32.Vec3{1, 1, 1}, 1, 1, 0.09, 0.032)
+		light.CastShadows = true
+		lights = append(lights, light)
+	}
+	return lights
+}
+
+// framePointLights are the lights as gathered for a frame, without shadow slots
+func framePointLights(pointLights []*components.PointLightComponent) frameLights {
+	var lights frameLights
+	for _, light := range pointLights {
+		lights.points = append(lights.points, framePointLight{position: light.Position, light: light, shadowSlot: -1})
+	}
+	return lights
+}
+
+// drawnSlots lists the slots whose maps were redrawn
+func drawnSlots(shadows [maxPointShadows]*pointShadow) []int {
+	var drawn []int
+	for slot, shadow := range shadows {
+		if shadow != nil && shadow.update {
+			drawn = append(drawn, slot)
+		}
+	}
+	return drawn
+}
+
+func lightSlots(lights frameLights) []int {
+	var slots []int
+	for _, pointLight := range lights.points {
+		slots = append(slots, pointLight.shadowSlot)
+	}
+	return slots
+}
+
+func TestPointShadowSlotsMoreLightsThanMaps(t *testing.T) {
+	pointLights := newShadowPointLights(maxPointShadows + 2)
+	pointLights[1].CastShadows = false
+
+	var slots pointShadowSlots
+	lights := framePointLights(pointLights)
+	shadows := slots.assign(&lights, 0)
+
+	// The first casters get the maps, the rest and the light without shadows go unshadowed
+	want := []int{0, -1, 1, 2, 3, -1}
+	if got := lightSlots(lights); !slices.Equal(got, want) {
+		t.Errorf("light slots %v, want %v", got, want)
+	}
+	if drawn := drawnSlots(shadows); !slices.Equal(drawn, []int{0, 1, 2, 3}) {
+		t.Errorf("drew slots %v", drawn)
+	}
+	for slot, shadow := range shadows {
+		if light := lights.points[slices.Index(lightSlots(lights), slot)]; shadow.position != light.position {
+			t.Errorf("slot %d drawn at %v, want %v", slot, shadow.position, light.position)
+		}
+	}
+
+	// A freed slot goes to a light that had none
+	lights = framePointLights(slices.Delete(slices.Clone(pointLights), 2, 3))
+	slots.assign(&lights, 0)
+	if got, want := lightSlots(lights), []int{0, -1, 2, 3, 1}; !slices.Equal(got, want) {
+		t.Errorf("light slots %v after removing a light, want %v", got, want)
+	}
+}
+
+func TestPointShadowSlotsBudgetRotates(t *testing.T) {
+	pointLights := newShadowPointLights(maxPointShadows)
+
+	var slots pointShadowSlots
+	frame := func() (frameLights, []int) {
+		lights := framePointLights(pointLights)
+		shadows := slots.assign(&lights, 2)
+		return lights, drawnSlots(shadows)
+	}
+
+	// Lights are unshadowed until their map is first drawn
+	lights, drawn := frame()
+	if !slices.Equal(drawn, []int{0, 1}) {
+		t.Errorf("first frame drew slots %v", drawn)
+	}
+	if got, want := lightSlots(lights), []int{0, 1, -1, -1}; !slices.Equal(got, want) {
+		t.Errorf("first frame light slots %v, want %v", got, want)
+	}
+
+	lights, drawn = frame()
+	if !slices.Equal(drawn, []int{2, 3}) {
+		t.Errorf("second frame drew slots %v", drawn)
+	}
+	if got, want := lightSlots(lights), []int{0, 1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("second frame light slots %v, want %v", got, want)
+	}
+
+	// Still lights take turns, longest since drawn first
+	for i, want := range [][]int{{0, 1}, {2, 3}, {0, 1}} {
+		if _, drawn := frame(); !slices.Equal(drawn, want) {
+			t.Errorf("frame %d drew slots %v, want %v", i+3, drawn, want)
+		}
+	}
+
+	// A moved light skips the queue
+	pointLights[3].Position = mgl32.Vec3{5, 5, 5}
+	if _, drawn := frame(); !slices.Contains(drawn, 3) || len(drawn) != 2 {
+		t.Errorf("drew slots %v after moving the light in slot 3", drawn)
+	}
+}
+
+func TestPointShadowSlotsKeptWhenLightRemoved(t *testing.T) {
+	pointLights := newShadowPointLights(3)
+
+	var slots pointShadowSlots
+	lights := framePointLights(pointLights)
+	slots.assign(&lights, 0)
+
+	// Removing the first light leaves the others' maps where they were, still drawn
+	lights = framePointLights(pointLights[1:])
+	shadows := slots.assign(&lights, 1)
+	if got, want := lightSlots(lights), []int{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("light slots %v after removing a light, want %v", got, want)
+	}
+	if shadows[0] != nil || shadows[1] == nil || shadows[2] == nil {
+		t.Errorf("shadows %v after removing a light", shadows)
+	}
+
+	// As does a light that stops casting shadows
+	pointLights[1].CastShadows = false
+	lights = framePointLights(pointLights[1:])
+	shadows = slots.assign(&lights, 1)
+	if got, want := lightSlots(lights), []int{-1, 2}; !slices.Equal(got, want) {
+		t.Errorf("light slots %v after a light stopped casting, want %v", got, want)
+	}
+	if shadows[1] != nil || shadows[2] == nil {
+		t.Errorf("shadows %v after a light stopped casting", shadows)
+	}
+}
+
+func TestRenderSystemRedrawsPointShadowBudget(t *testing.T) {
+	store := entities.NewEntityStore()
+	rs, device := newRecordingRenderSystem(t, store)
+	newRenderScene(store)
+
+	for _, light := range newShadowPointLights(3) {
+		store.AddComponent(store.NewEntity(), light)
+	}
+	if err := rs.LoadPointShadowShader("assets/shaders/point.shadow.vertex.glsl", "assets/shaders/point.shadow.fragment.glsl"); err != nil {
+		t.Fatal(err)
+	}
+	rs.PointShadowBudget = 1
+
+	// facesDrawn lists the cube faces drawn into this frame, by slot
+	facesDrawn := func() map[int][]int {
+		faces := make(map[int][]int)
+		for _, draw := range device.Draws {
+			if draw.Framebuffer == 0 {
+				continue
+			}
+			framebuffer := device.Framebuffers[draw.Framebuffer]
+			slot := slices.IndexFunc(rs.pointShadowMaps[:], func(shadowMap shadowMap) bool { return shadowMap.texture == framebuffer.Depth })
+			if !device.Textures[framebuffer.Depth].Cube || slot < 0 {
+				t.Fatalf("shadow drawn into framebuffer %d of texture %d", draw.Framebuffer, framebuffer.Depth)
+			}
+			if !slices.Contains(faces[slot], framebuffer.Layer) {
+				faces[slot] = append(faces[slot], framebuffer.Layer)
+			}
+		}
+		return faces
+	}
+
+	allFaces := []int{0, 1, 2, 3, 4, 5}
+	for frame, slot := range []int{0, 1, 2, 0, 1} {
+		device.Reset()
+		rs.Update(1.0 / 60)
+		if len(device.Errors) != 0 {
+			t.Fatalf("frame %d device errors %v", frame, device.Errors)
+		}
+
+		faces := facesDrawn()
+		if len(faces) != 1 || !slices.Equal(faces[slot], allFaces) {
+			t.Errorf("frame %d drew faces %v, want every face of slot %d", frame, faces, slot)
+		}
+	}
+
+	// Every map is bound for the scene, drawn this frame or not
+	for _, draw := range device.Draws {
+		if draw.Framebuffer != 0 {
+			continue
+		}
+		for slot := 0; slot < 3; slot++ {
+			if texture := draw.Textures[pointShadowUnit+slot]; texture != rs.pointShadowMaps[slot].texture || texture == 0 {
+				t.Errorf("slot %d bound texture %d, want %d", slot, texture, rs.pointShadowMaps[slot].texture)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// softwareVertex holds what vertex.glsl passes to the fragment stage
+type softwareVertex struct {
+	clip      mgl32.Vec4
+	fragPos   mgl32.Vec3
+	normal    mgl32.Vec3
+	texCoords mgl32.Vec2
+}
+
+// screenVertex is a vertex after the perspective divide, in pixels with y down
+type screenVertex struct {
+	x, y, z float32
+	invW    float32
+	vertex  softwareVertex
+}
+
+func (sr *SoftwareRenderer) drawMesh(meshComponent *components.MeshComponent, model mgl32.Mat4, normalMatrix mgl32.Mat3, viewProjection mgl32.Mat4, material softwareMaterial, receiveShadows bool) {
+	vertices := make([]softwareVertex, len(meshComponent.Vertices))
+	for i, vertex := range meshComponent.Vertices {
+		worldPosition := model.Mul4x1(vertex.Position.Vec4(1))
+		vertices[i] = softwareVertex{
+			clip:      viewProjection.Mul4x1(worldPosition),
+			fragPos:   worldPosition.Vec3(),
+			normal:    normalMatrix.Mul3x1(vertex.Normal),
+			texCoords: vertex.TexCoords,
+		}
+	}
+
+	width, height := sr.Image.Rect.Dx(), sr.Image.Rect.Dy()
+	eachTriangle(vertices, meshComponent.Indices, width, height, func(v0, v1, v2 screenVertex) {
+		scanTriangle(v0, v1, v2, width, height, func(px, py int, w0, w1, w2 float32) {
+			// Depth is linear in screen space
+			depth := w0*v0.z + w1*v1.z + w2*v2.z
+			index := py*width + px
+			if depth < 0 || depth > 1 || depth >= sr.depth[index] {
+				return
+			}
+			sr.depth[index] = depth
+
+			// Everything else is linear in world space, so weight by 1/w
+			p0, p1, p2 := w0*v0.invW, w1*v1.invW, w2*v2.invW
+			inverseSum := 1 / (p0 + p1 + p2)
+			p0, p1, p2 = p0*inverseSum, p1*inverseSum, p2*inverseSum
+
+			fragPos := v0.vertex.fragPos.Mul(p0).Add(v1.vertex.fragPos.Mul(p1)).Add(v2.vertex.fragPos.Mul(p2))
+			normal := v0.vertex.normal.Mul(p0).Add(v1.vertex.normal.Mul(p1)).Add(v2.vertex.normal.Mul(p2))
+			texCoords := v0.vertex.texCoords.Mul(p0).Add(v1.vertex.texCoords.Mul(p1)).Add(v2.vertex.texCoords.Mul(p2))
+
+			result := sr.frame.shade(fragPos, normal, texCoords, material, receiveShadows)
+
+			offset := sr.Image.PixOffset(px, py)
+			sr.Image.Pix[offset+0] = toByte(result.X())
+			sr.Image.Pix[offset+1] = toByte(result.Y())
+			sr.Image.Pix[offset+2] = toByte(result.Z())
+			sr.Image.Pix[offset+3] = 255
+		})
+	})
+}
+
+// drawShadowCaster draws a mesh's depth into one layer of a shadow map, or its
+// distance from a point light over the light's far plane.
+func drawShadowCaster(meshComponent *components.MeshComponent, model, lightSpace mgl32.Mat4, shadowMap *softwareShadowMap, layer int, point *pointShadow) {
+	vertices := make([]softwareVertex, len(meshComponent.Vertices))
+	for i, vertex := range meshComponent.Vertices {
+		worldPosition := model.Mul4x1(vertex.Position.Vec4(1))
+		vertices[i] = softwareVertex{clip: lightSpace.Mul4x1(worldPosition), fragPos: worldPosition.Vec3()}
+	}
+
+	resolution := shadowMap.resolution
+	depths := shadowMap.layer(layer)
+	eachTriangle(vertices, meshComponent.Indices, resolution, resolution, func(v0, v1, v2 screenVertex) {
+		scanTriangle(v0, v1, v2, resolution, resolution, func(px, py int, w0, w1, w2 float32) {
+			depth := w0*v0.z + w1*v1.z + w2*v2.z
+			if depth < 0 || depth > 1 {
+				return
+			}
+
+			// Written like gl_FragDepth in point.shadow.fragment.glsl
+			if point != nil {
+				p0, p1, p2 := w0*v0.invW, w1*v1.invW, w2*v2.invW
+				inverseSum := 1 / (p0 + p1 + p2)
+				fragPos := v0.vertex.fragPos.Mul(p0 * inverseSum).Add(v1.vertex.fragPos.Mul(p1 * inverseSum)).Add(v2.vertex.fragPos.Mul(p2 * inverseSum))
+				depth = fragPos.Sub(point.position).Len() / point.far
+			}
+
+			index := py*resolution + px
+			if depth >= depths[index] {
+				return
+			}
+			depths[index] = depth
+		})
+	})
+}
+
+// eachTriangle clips each triangle to the near plane and projects the pieces
+// onto a width by height target.
+func eachTriangle(vertices []softwareVertex, indices []uint32, width, height int, draw func(v0, v1, v2 screenVertex)) {
+	for i := 0; i+2 < len(indices); i += 3 {
+		polygon := clipNear([]softwareVertex{vertices[indices[i]], vertices[indices[i+1]], vertices[indices[i+2]]})
+		for j := 1; j+1 < len(polygon); j++ {
+			draw(toScreen(polygon[0], width, height), toScreen(polygon[j], width, height), toScreen(polygon[j+1], width, height))
+		}
+	}
+}
+
+// clipNear clips a polygon to the near plane, z >= -w in clip space, which also
+// keeps w positive for the perspective divide. The other planes are handled by
+// only scanning pixels inside the target and depths in range.
+func clipNear(polygon []softwareVertex) []softwareVertex {
+	clipped := make([]softwareVertex, 0, len(polygon)+1)
+	for i := range polygon {
+		current, next := polygon[i], polygon[(i+1)%len(polygon)]
+		currentDistance := current.clip.Z() + current.clip.W()
+		nextDistance := next.clip.Z() + next.clip.W()
+
+		if currentDistance >= 0 {
+			clipped = append(clipped, current)
+		}
+		if (currentDistance >= 0) != (nextDistance >= 0) {
+			t := currentDistance / (currentDistance - nextDistance)
+			clipped = append(clipped, lerpVertex(current, next, t))
+		}
+	}
+	return clipped
+}
+
+func lerpVertex(a, b softwareVertex, t float32) softwareVertex {
+	return softwareVertex{
+		clip:      a.clip.Add(b.clip.Sub(a.clip).Mul(t)),
+		fragPos:   a.fragPos.Add(b.fragPos.Sub(a.fragPos).Mul(t)),
+		normal:    a.normal.Add(b.normal.Sub(a.normal).Mul(t)),
+		texCoords: a.texCoords.Add(b.texCoords.Sub(a.texCoords).Mul(t)),
+	}
+}
+
+func toScreen(vertex softwareVertex, width, height int) screenVertex {
+	invW := 1 / vertex.clip.W()
+
+	return screenVertex{
+		x:      (vertex.clip.X()*invW + 1) * 0.5 * float32(width),
+		y:      (1 - vertex.clip.Y()*invW) * 0.5 * float32(height),
+		z:      vertex.clip.Z()*invW*0.5 + 0.5, // Depth range 0 to 1 like glDepthRange's default
+		invW:   invW,
+		vertex: vertex,
+	}
+}
+
+// edge is twice the signed area of the triangle a, b, (x, y)
+func edge(a, b screenVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// scanTriangle calls fragment for every pixel centre the triangle covers, with
+// the pixel's barycentric weights of v0, v1 and v2.
+func scanTriangle(v0, v1, v2 screenVertex, width, height int, fragment func(px, py int, w0, w1, w2 float32)) {
+	area := edge(v0, v1, v2.x, v2.y)
+	if area == 0 {
+		return
+	}
+	// Faces aren't culled, so both windings are drawn
+	flipped := area < 0
+	if flipped {
+		v1, v2 = v2, v1
+		area = -area
+	}
+
+	minX := max(0, int(math.Floor(float64(min(v0.x, v1.x, v2.x)))))
+	maxX := min(width-1, int(math.Ceil(float64(max(v0.x, v1.x, v2.x)))))
+	minY := max(0, int(math.Floor(float64(min(v0.y, v1.y, v2.y)))))
+	maxY := min(height-1, int(math.Ceil(float64(max(v0.y, v1.y, v2.y)))))
+
+	for py := minY; py <= maxY; py++ {
+		for px := minX; px <= maxX; px++ {
+			x, y := float32(px)+0.5, float32(py)+0.5
+
+			w0 := edge(v1, v2, x, y)
+			w1 := edge(v2, v0, x, y)
+			w2 := edge(v0, v1, x, y)
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+
+			if flipped {
+				w1, w2 = w2, w1
+			}
+			fragment(px, py, w0/area, w1/area, w2/area)
+		}
+	}
+}
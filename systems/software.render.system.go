@@ -0,0 +1,217 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"0xKowalski/game/graphics"
+	"0xKowalski/game/resources"
+	"image"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// SoftwareRenderer is a Renderer that draws the scene on the CPU with the same
+// lighting as fragment.glsl, so frames can be compared against golden images
+// without a GPU.
+type SoftwareRenderer struct {
+	EntityStore *entities.EntityStore
+	Image       *image.RGBA
+	ClearColor  mgl32.Vec3
+
+	depth    []float32
+	textures map[string]*image.RGBA // nil for textures that failed to load
+	frame    softwareFrame
+
+	PointShadowBudget int // Point lights whose shadow maps are redrawn each frame, 0 for all of them
+
+	directionalShadowMap softwareShadowMap
+	spotShadowMaps       softwareShadowMap
+	pointShadowSlots     pointShadowSlots
+	pointShadowMaps      [maxPointShadows]softwareShadowMap // Six layers each, one per cube face
+
+	cameras     entities.Query2[components.CameraComponent, components.TransformComponent]
+	renderables entities.Query1[components.RenderableComponent]
+	pointLights entities.Query1[components.PointLightComponent]
+	spotLights  entities.Query1[components.SpotLightComponent]
+}
+
+func NewSoftwareRenderer(entityStore *entities.EntityStore, width, height int) *SoftwareRenderer {
+	sr := &SoftwareRenderer{
+		EntityStore:       entityStore,
+		ClearColor:        mgl32.Vec3{0.0, 0.0, 0.1}, // Same background as the render system
+		PointShadowBudget: defaultPointShadowBudget,
+		textures:          make(map[string]*image.RGBA),
+		cameras:           entities.NewQuery2[components.CameraComponent, components.TransformComponent](entityStore),
+		renderables:       entities.NewQuery1[components.RenderableComponent](entityStore),
+		pointLights:       entities.NewQuery1[components.PointLightComponent](entityStore),
+		spotLights:        entities.NewQuery1[components.SpotLightComponent](entityStore),
+	}
+	sr.Resize(width, height)
+
+	return sr
+}
+
+// Resize changes the size of the image drawn from the next frame on
+func (sr *SoftwareRenderer) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	sr.Image = image.NewRGBA(image.Rect(0, 0, width, height))
+	sr.depth = make([]float32, width*height)
+}
+
+func (sr *SoftwareRenderer) Update(deltaTime float32) {
+	sr.clear()
+
+	// Without a camera there is nothing to draw but the background
+	var camera *components.CameraComponent
+	var view, projection mgl32.Mat4
+	var viewPos mgl32.Vec3
+	sr.cameras.Each(func(entity entities.Entity, cameraComponent *components.CameraComponent, transformComponent *components.TransformComponent) {
+		if camera != nil {
+			return
+		}
+		camera = cameraComponent
+
+		viewPos = transformComponent.GetWorldPosition()
+		view = cameraComponent.GetViewMatrix(viewPos)
+		projection = cameraComponent.GetProjectionMatrix()
+	})
+	if camera == nil {
+		return
+	}
+
+	lights := gatherLights(sr.EntityStore, sr.pointLights, sr.spotLights)
+	shadows := newFrameShadows(&lights, camera, view)
+	shadows.points = sr.pointShadowSlots.assign(&lights, sr.PointShadowBudget)
+	sr.frame = softwareFrame{
+		viewPos:              viewPos,
+		view:                 view,
+		lights:               lights,
+		shadows:              shadows,
+		directionalShadowMap: &sr.directionalShadowMap,
+		spotShadowMaps:       &sr.spotShadowMaps,
+		pointShadowMaps:      &sr.pointShadowMaps,
+	}
+	sr.drawShadowMaps(shadows)
+
+	viewProjection := projection.Mul4(view)
+
+	sr.renderables.Each(func(entity entities.Entity, renderableComponent *components.RenderableComponent) {
+		if renderableComponent.TransformComponent == nil || renderableComponent.ModelComponent == nil {
+			return
+		}
+
+		model := renderableComponent.TransformComponent.GetRenderMatrix()
+		normalMatrix := model.Mat3().Inv().Transpose()
+
+		modelComponent := renderableComponent.ModelComponent
+		if len(modelComponent.MaterialComponents) != len(modelComponent.MeshComponents) {
+			log.Printf("Model has %d meshes but %d materials, cannot render entity", len(modelComponent.MeshComponents), len(modelComponent.MaterialComponents))
+			return
+		}
+
+		for i, meshComponent := range modelComponent.MeshComponents {
+			materialComponent := modelComponent.MaterialComponents[i]
+			material := softwareMaterial{
+				diffuseMap:  sr.texture(materialComponent.DiffuseMap),
+				specularMap: sr.texture(materialComponent.SpecularMap),
+				shininess:   materialComponent.Shininess,
+			}
+
+			sr.drawMesh(meshComponent, model, normalMatrix, viewProjection, material, renderableComponent.ReceiveShadows)
+		}
+	})
+}
+
+func (sr *SoftwareRenderer) drawShadowMaps(shadows frameShadows) {
+	if shadows.directional != nil {
+		sr.directionalShadowMap.resize(shadows.directional.settings.Resolution, len(shadows.directional.matrices))
+		for cascade, matrix := range shadows.directional.matrices {
+			sr.drawShadowMap(&sr.directionalShadowMap, cascade, matrix, nil)
+		}
+	}
+
+	if len(shadows.spots) > 0 {
+		sr.spotShadowMaps.resize(shadows.spotResolution, len(shadows.spots))
+		for layer, spot := range shadows.spots {
+			sr.drawShadowMap(&sr.spotShadowMaps, layer, spot.matrix, nil)
+		}
+	}
+
+	for slot, point := range shadows.points {
+		if point == nil || !point.update {
+			continue
+		}
+
+		sr.pointShadowMaps[slot].resize(point.settings.Resolution, 6)
+		for face, matrix := range point.faces {
+			sr.drawShadowMap(&sr.pointShadowMaps[slot], face, matrix, point)
+		}
+	}
+}
+
+// drawShadowMap draws the casters' depth into a layer, or for a point light
+// their distance from it.
+func (sr *SoftwareRenderer) drawShadowMap(shadowMap *softwareShadowMap, layer int, lightSpace mgl32.Mat4, point *pointShadow) {
+	depths := shadowMap.layer(layer)
+	for i := range depths {
+		depths[i] = 1
+	}
+
+	sr.renderables.Each(func(entity entities.Entity, renderableComponent *components.RenderableComponent) {
+		if !renderableComponent.CastShadows || renderableComponent.TransformComponent == nil || renderableComponent.ModelComponent == nil {
+			return
+		}
+
+		model := renderableComponent.TransformComponent.GetRenderMatrix()
+		for _, meshComponent := range renderableComponent.ModelComponent.MeshComponents {
+			drawShadowCaster(meshComponent, model, lightSpace, shadowMap, layer, point)
+		}
+	})
+}
+
+func (sr *SoftwareRenderer) clear() {
+	background := color.RGBA{toByte(sr.ClearColor.X()), toByte(sr.ClearColor.Y()), toByte(sr.ClearColor.Z()), 255}
+	for i := 0; i < len(sr.Image.Pix); i += 4 {
+		sr.Image.Pix[i+0] = background.R
+		sr.Image.Pix[i+1] = background.G
+		sr.Image.Pix[i+2] = background.B
+		sr.Image.Pix[i+3] = background.A
+	}
+	for i := range sr.depth {
+		sr.depth[i] = 1
+	}
+}
+
+// texture loads a texture once, like the texture store. Missing textures sample
+// as black, as an unbound texture does on the GPU.
+func (sr *SoftwareRenderer) texture(texturePath string) *image.RGBA {
+	if texture, exists := sr.textures[texturePath]; exists {
+		return texture
+	}
+
+	img, err := loadImage(resources.Path(texturePath))
+	if err != nil {
+		log.Printf("Error loading texture: %v", err)
+		sr.textures[texturePath] = nil
+		return nil
+	}
+
+	texture := imageToRGBA(img)
+	sr.textures[texturePath] = texture
+	return texture
+}
+
+// SavePNG writes the last frame drawn
+func (sr *SoftwareRenderer) SavePNG(path string) error {
+	return graphics.SavePNG(path, sr.Image)
+}
+
+func toByte(value float32) uint8 {
+	return uint8(math.Round(float64(mgl32.Clamp(value, 0, 1)) * 255))
+}
@@ -0,0 +1,296 @@
+package systems
+
+import (
+	"image"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type softwareMaterial struct {
+	diffuseMap  *image.RGBA
+	specularMap *image.RGBA
+	shininess   float32
+}
+
+// softwareFrame holds the fragment shader's uniforms for one frame
+type softwareFrame struct {
+	viewPos mgl32.Vec3
+	view    mgl32.Mat4
+	lights  frameLights
+	shadows frameShadows
+
+	directionalShadowMap *softwareShadowMap
+	spotShadowMaps       *softwareShadowMap
+	pointShadowMaps      *[maxPointShadows]softwareShadowMap
+}
+
+// shade is main() of fragment.glsl, term for term, so both renderers light a
+// scene the same way. Changes to the shader's lighting belong here too.
+func (frame *softwareFrame) shade(fragPos, normal mgl32.Vec3, texCoords mgl32.Vec2, material softwareMaterial, receiveShadows bool) mgl32.Vec3 {
+	diffuseTexel := sampleTexture(material.diffuseMap, texCoords)
+	specularTexel := sampleTexture(material.specularMap, texCoords)
+
+	norm := normal.Normalize()
+	viewDir := frame.viewPos.Sub(fragPos).Normalize()
+	lights := frame.lights
+
+	var result mgl32.Vec3
+
+	// Ambient
+	if lights.ambient != nil {
+		result = mulVec3(lights.ambient.Color, diffuseTexel).Mul(lights.ambient.Intensity)
+	}
+
+	// Directional, an unset light has no direction and adds nothing
+	if lights.directional != nil && lights.directional.Direction.Len() > 0 {
+		light := lights.directional
+		lightDir := light.Direction.Mul(-1).Normalize()
+		diff := max(norm.Dot(lightDir), 0)
+		diffuse := mulVec3(diffuseTexel, light.Color).Mul(diff * light.Intensity)
+
+		spec := phongSpecular(viewDir, lightDir, norm, material.shininess)
+		specular := specularTexel.Mul(spec)
+
+		shadow := frame.directionalShadow(fragPos, norm, lightDir, receiveShadows)
+		result = result.Add(diffuse.Add(specular).Mul(1 - shadow))
+	}
+
+	// Point lights, each only reaches as far as its attenuation is visible. The
+	// shader only loops over the lights of the fragment's cluster, which are the
+	// ones in reach, so looping over every light here lights the same.
+	for _, pointLight := range lights.points {
+		light := pointLight.light
+		toLight := pointLight.position.Sub(fragPos)
+		lightDir := toLight.Normalize()
+		distance := toLight.Len()
+		if distance > lightRange(light.Constant, light.Linear, light.Quadratic) {
+			continue
+		}
+		attenuation := 1 / (light.Constant + light.Linear*distance + light.Quadratic*(distance*distance))
+
+		diff := max(norm.Dot(lightDir), 0)
+		diffuse := mulVec3(diffuseTexel, light.Color).Mul(diff * light.Intensity * attenuation)
+
+		spec := phongSpecular(viewDir, lightDir, norm, material.shininess)
+		specular := mulVec3(specularTexel, light.Color).Mul(spec)
+
+		shadow := frame.pointShadow(pointLight, fragPos, norm, lightDir, receiveShadows)
+		result = result.Add(diffuse.Add(specular).Mul(1 - shadow))
+	}
+
+	// Spot lights, with soft edges
+	for _, spotLight := range lights.spots {
+		light := spotLight.light
+		toLight := spotLight.position.Sub(fragPos)
+		if toLight.Len() > lightRange(light.Constant, light.Linear, light.Quadratic) {
+			continue
+		}
+		lightDir := toLight.Normalize()
+
+		diff := max(norm.Dot(lightDir), 0)
+		diffuse := diffuseTexel.Mul(diff)
+
+		spec := phongSpecular(viewDir, lightDir, norm, material.shininess)
+		specular := specularTexel.Mul(spec)
+
+		theta := lightDir.Dot(spotLight.direction.Mul(-1).Normalize())
+		epsilon := light.CutOff - light.OuterCutOff
+		intensity := mgl32.Clamp((theta-light.OuterCutOff)/epsilon, 0, 1)
+
+		distance := toLight.Len()
+		attenuation := 1 / (light.Constant + light.Linear*distance + light.Quadratic*(distance*distance))
+
+		lit := diffuse.Add(specular).Mul(intensity * attenuation)
+		if spotLight.shadowLayer >= 0 && receiveShadows {
+			shadow := frame.shadows.spots[spotLight.shadowLayer]
+			bias := slopeBias(shadow.settings.Bias, norm, lightDir)
+			lit = lit.Mul(1 - frame.spotShadowMaps.shadow(spotLight.shadowLayer, shadow.matrix, fragPos, bias, shadow.settings.PCFRadius))
+		}
+
+		result = result.Add(lit)
+	}
+
+	return result
+}
+
+// directionalShadow is calculateDirectionalShadow, picking the cascade by view depth
+func (frame *softwareFrame) directionalShadow(fragPos, norm, lightDir mgl32.Vec3, receiveShadows bool) float32 {
+	shadow := frame.shadows.directional
+	if shadow == nil || !receiveShadows {
+		return 0
+	}
+
+	viewDepth := -frame.view.Mul4x1(fragPos.Vec4(1)).Z()
+	for cascade, split := range shadow.splits {
+		if viewDepth < split {
+			bias := slopeBias(shadow.settings.Bias, norm, lightDir)
+			return frame.directionalShadowMap.shadow(cascade, shadow.matrices[cascade], fragPos, bias, shadow.settings.PCFRadius)
+		}
+	}
+	return 0 // Beyond the shadow distance
+}
+
+// pointShadow is calculatePointShadow, filtering over a cube of directions
+func (frame *softwareFrame) pointShadow(pointLight framePointLight, fragPos, norm, lightDir mgl32.Vec3, receiveShadows bool) float32 {
+	if pointLight.shadowSlot < 0 || !receiveShadows {
+		return 0
+	}
+
+	shadow := frame.shadows.points[pointLight.shadowSlot]
+	shadowMap := &frame.pointShadowMaps[pointLight.shadowSlot]
+
+	toFrag := fragPos.Sub(shadow.position)
+	distance := toFrag.Len()
+	if distance > shadow.far {
+		return 0
+	}
+
+	current := (distance - slopeBias(shadow.settings.Bias, norm, lightDir)) / shadow.far
+	texelSize := 2 * distance / float32(shadow.settings.Resolution)
+	radius := shadow.settings.PCFRadius
+
+	var blocked float32
+	for x := -radius; x <= radius; x++ {
+		for y := -radius; y <= radius; y++ {
+			for z := -radius; z <= radius; z++ {
+				direction := toFrag.Add(mgl32.Vec3{float32(x), float32(y), float32(z)}.Mul(texelSize))
+				if current > shadowMap.sampleCube(shadow, direction) {
+					blocked++
+				}
+			}
+		}
+	}
+
+	samples := float32((2*radius + 1) * (2*radius + 1) * (2*radius + 1))
+	return blocked / samples
+}
+
+func slopeBias(bias float32, normal, lightDir mgl32.Vec3) float32 {
+	return max(bias*10*(1-normal.Dot(lightDir)), bias)
+}
+
+// phongSpecular is pow(max(dot(viewDir, reflect(-lightDir, normal)), 0), shininess)
+func phongSpecular(viewDir, lightDir, normal mgl32.Vec3, shininess float32) float32 {
+	incident := lightDir.Mul(-1)
+	reflectDir := incident.Sub(normal.Mul(2 * normal.Dot(incident)))
+	return float32(math.Pow(float64(max(viewDir.Dot(reflectDir), 0)), float64(shininess)))
+}
+
+func mulVec3(a, b mgl32.Vec3) mgl32.Vec3 {
+	return mgl32.Vec3{a[0] * b[0], a[1] * b[1], a[2] * b[2]}
+}
+
+// sampleTexture filters bilinearly and repeats, matching the texture store's
+// wrap mode. Mipmaps aren't generated, which only matters for distant surfaces.
+func sampleTexture(texture *image.RGBA, texCoords mgl32.Vec2) mgl32.Vec3 {
+	if texture == nil {
+		return mgl32.Vec3{}
+	}
+
+	width, height := texture.Rect.Dx(), texture.Rect.Dy()
+	x := float64(texCoords.X())*float64(width) - 0.5
+	y := float64(texCoords.Y())*float64(height) - 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := float32(x-x0), float32(y-y0)
+
+	left, top := int(x0), int(y0)
+	topRow := texel(texture, left, top).Mul(1 - fx).Add(texel(texture, left+1, top).Mul(fx))
+	bottomRow := texel(texture, left, top+1).Mul(1 - fx).Add(texel(texture, left+1, top+1).Mul(fx))
+
+	return topRow.Mul(1 - fy).Add(bottomRow.Mul(fy))
+}
+
+func texel(texture *image.RGBA, x, y int) mgl32.Vec3 {
+	width, height := texture.Rect.Dx(), texture.Rect.Dy()
+	x = ((x % width) + width) % width
+	y = ((y % height) + height) % height
+
+	offset := texture.PixOffset(texture.Rect.Min.X+x, texture.Rect.Min.Y+y)
+	return mgl32.Vec3{
+		float32(texture.Pix[offset+0]) / 255,
+		float32(texture.Pix[offset+1]) / 255,
+		float32(texture.Pix[offset+2]) / 255,
+	}
+}
+
+// softwareShadowMap is a depth texture array, one square layer after another
+type softwareShadowMap struct {
+	resolution int
+	layers     int
+	depth      []float32
+}
+
+func (shadowMap *softwareShadowMap) resize(resolution, layers int) {
+	if shadowMap.resolution == resolution && shadowMap.layers >= layers {
+		return
+	}
+
+	shadowMap.resolution = resolution
+	shadowMap.layers = layers
+	shadowMap.depth = make([]float32, resolution*resolution*layers)
+}
+
+func (shadowMap *softwareShadowMap) layer(layer int) []float32 {
+	size := shadowMap.resolution * shadowMap.resolution
+	return shadowMap.depth[layer*size : (layer+1)*size]
+}
+
+// sample reads the nearest texel, outside the map reads as the far plane like
+// the GPU's border color.
+func (shadowMap *softwareShadowMap) sample(layer int, u, v float32) float32 {
+	x := int(math.Floor(float64(u * float32(shadowMap.resolution))))
+	y := int(math.Floor(float64(v * float32(shadowMap.resolution))))
+	if x < 0 || y < 0 || x >= shadowMap.resolution || y >= shadowMap.resolution {
+		return 1
+	}
+
+	// Texture rows start at the bottom, the rasterizer's at the top
+	y = shadowMap.resolution - 1 - y
+	return shadowMap.layer(layer)[y*shadowMap.resolution+x]
+}
+
+// sampleCube reads the face a direction from the light points at. The face is
+// found by projecting with the matrix it was drawn with, so it lines up however
+// the faces are oriented, and clamps to the face's edge like the GPU.
+func (shadowMap *softwareShadowMap) sampleCube(shadow *pointShadow, direction mgl32.Vec3) float32 {
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if math.Abs(float64(direction[i])) > math.Abs(float64(direction[axis])) {
+			axis = i
+		}
+	}
+	face := axis * 2
+	if direction[axis] < 0 {
+		face++
+	}
+
+	clip := shadow.faces[face].Mul4x1(shadow.position.Add(direction).Vec4(1))
+	edge := 1 - 0.5/float32(shadowMap.resolution)
+	u := mgl32.Clamp(clip.X()/clip.W()*0.5+0.5, 0, edge)
+	v := mgl32.Clamp(clip.Y()/clip.W()*0.5+0.5, 0, edge)
+	return shadowMap.sample(face, u, v)
+}
+
+// shadow is calculateShadow, how much of a light is blocked
+func (shadowMap *softwareShadowMap) shadow(layer int, lightSpace mgl32.Mat4, fragPos mgl32.Vec3, bias float32, pcfRadius int) float32 {
+	lightSpacePos := lightSpace.Mul4x1(fragPos.Vec4(1))
+	projCoords := lightSpacePos.Vec3().Mul(1 / lightSpacePos.W()).Mul(0.5).Add(mgl32.Vec3{0.5, 0.5, 0.5})
+	if projCoords.Z() > 1 {
+		return 0
+	}
+
+	texelSize := 1 / float32(shadowMap.resolution)
+	var blocked float32
+	for x := -pcfRadius; x <= pcfRadius; x++ {
+		for y := -pcfRadius; y <= pcfRadius; y++ {
+			closest := shadowMap.sample(layer, projCoords.X()+float32(x)*texelSize, projCoords.Y()+float32(y)*texelSize)
+			if projCoords.Z()-bias > closest {
+				blocked++
+			}
+		}
+	}
+
+	samples := float32((2*pcfRadius + 1) * (2*pcfRadius + 1))
+	return blocked / samples
+}
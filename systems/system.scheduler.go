@@ -0,0 +1,381 @@
+package systems
+
+import (
+	"0xKowalski/game/entities"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Stage groups systems within a frame. Stages run in the order declared here,
+// FixedUpdate runs zero or more times a frame, once per fixed step.
+type Stage int
+
+const (
+	PreUpdate Stage = iota
+	FixedUpdate
+	Update
+	PostUpdate
+	Render
+
+	stageCount
+)
+
+var stageNames = [stageCount]string{"PreUpdate", "FixedUpdate", "Update", "PostUpdate", "Render"}
+
+func (stage Stage) String() string {
+	if stage < 0 || stage >= stageCount {
+		return fmt.Sprintf("Stage(%d)", int(stage))
+	}
+	return stageNames[stage]
+}
+
+// System is anything the scheduler can run. deltaTime is the frame time, or the
+// fixed step in the FixedUpdate stage.
+type System interface {
+	Update(deltaTime float32)
+}
+
+// SystemFunc lets a plain function be used as a System
+type SystemFunc func(deltaTime float32)
+
+func (fn SystemFunc) Update(deltaTime float32) {
+	fn(deltaTime)
+}
+
+type SystemOption func(*scheduledSystem)
+
+// Before runs the system before the named system, which must already have been
+// added to the same stage
+func Before(name string) SystemOption {
+	return func(ss *scheduledSystem) {
+		ss.before = append(ss.before, name)
+	}
+}
+
+// After runs the system after the named system, which must already have been
+// added to the same stage
+func After(name string) SystemOption {
+	return func(ss *scheduledSystem) {
+		ss.after = append(ss.after, name)
+	}
+}
+
+// Reads declares that the system reads components of type T
+func Reads[T any]() SystemOption {
+	return func(ss *scheduledSystem) {
+		ss.declareAccess()
+		ss.reads[entities.ComponentIDOf[T]()] = true
+	}
+}
+
+// Writes declares that the system modifies components of type T
+func Writes[T any]() SystemOption {
+	return func(ss *scheduledSystem) {
+		ss.declareAccess()
+		ss.writes[entities.ComponentIDOf[T]()] = true
+	}
+}
+
+// MainThread keeps a system that declares its access on the main thread, for
+// systems that make GL calls.
+func MainThread() SystemOption {
+	return func(ss *scheduledSystem) {
+		ss.mainThread = true
+	}
+}
+
+type scheduledSystem struct {
+	name    string
+	stage   Stage
+	system  System
+	enabled bool
+
+	before []string
+	after  []string
+
+	// Systems that declare their component access can run alongside others
+	// they don't conflict with, nil reads/writes means exclusive access.
+	reads      map[entities.ComponentID]bool
+	writes     map[entities.ComponentID]bool
+	mainThread bool
+
+	dependencies map[*scheduledSystem]bool // Same stage systems that must run first
+}
+
+func (ss *scheduledSystem) declareAccess() {
+	if ss.reads == nil {
+		ss.reads = make(map[entities.ComponentID]bool)
+		ss.writes = make(map[entities.ComponentID]bool)
+	}
+}
+
+func (ss *scheduledSystem) parallel() bool {
+	return ss.reads != nil
+}
+
+// conflicts reports whether the two systems touch the same component type with
+// at least one of them writing it.
+func (ss *scheduledSystem) conflicts(other *scheduledSystem) bool {
+	for id := range ss.writes {
+		if other.reads[id] || other.writes[id] {
+			return true
+		}
+	}
+	for id := range other.writes {
+		if ss.reads[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// Scheduler runs systems stage by stage. Within a stage systems run in the
+// order they were added unless Before/After say otherwise.
+//
+// Neighbouring systems that declare their component access with Reads/Writes
+// and don't conflict run concurrently on worker goroutines. Systems that declare
+// nothing run alone on the calling (main) thread. Concurrent systems must only
+// touch the components they declared: no spawning, freeing, adding or removing
+// components (record those with a CommandBuffer) and no MarkChanged.
+type Scheduler struct {
+	systems []*scheduledSystem // Registration order
+	byName  map[string]*scheduledSystem
+
+	// Resolved run order per stage, and that order split into batches of
+	// systems that can run at the same time.
+	stages  [stageCount][]*scheduledSystem
+	batches [stageCount][][]*scheduledSystem
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		byName: make(map[string]*scheduledSystem),
+	}
+}
+
+// Add registers a system in a stage. Systems without a name can't be referred
+// to by Before/After or enabled and disabled later. Before/After naming a system
+// that hasn't been added, or one in another stage, is an error.
+func (s *Scheduler) Add(name string, stage Stage, system System, opts ...SystemOption) error {
+	if stage < 0 || stage >= stageCount {
+		return fmt.Errorf("cannot add system %q, unknown stage %v", name, stage)
+	}
+	if _, ok := s.byName[name]; ok && name != "" {
+		return fmt.Errorf("a system named %q already exists", name)
+	}
+
+	ss := &scheduledSystem{
+		name:    name,
+		stage:   stage,
+		system:  system,
+		enabled: true,
+	}
+	for _, opt := range opts {
+		opt(ss)
+	}
+	if err := s.checkDependencies(ss); err != nil {
+		return err
+	}
+
+	s.systems = append(s.systems, ss)
+	if name != "" {
+		s.byName[name] = ss
+	}
+
+	order, err := s.resolve(stage)
+	if err != nil {
+		s.systems = s.systems[:len(s.systems)-1]
+		delete(s.byName, name)
+		return err
+	}
+	s.stages[stage] = order
+	s.batches[stage] = batchSystems(order)
+
+	return nil
+}
+
+// checkDependencies makes sure every system named by Before/After exists in ss's stage
+func (s *Scheduler) checkDependencies(ss *scheduledSystem) error {
+	for _, names := range [][]string{ss.before, ss.after} {
+		for _, name := range names {
+			other, ok := s.byName[name]
+			if !ok {
+				return fmt.Errorf("cannot add system %q, no system named %q to order it against", ss.name, name)
+			}
+			if other.stage != ss.stage {
+				return fmt.Errorf("cannot add system %q to %v, %q is in %v", ss.name, ss.stage, name, other.stage)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) SetEnabled(name string, enabled bool) error {
+	ss, ok := s.byName[name]
+	if !ok {
+		return fmt.Errorf("no system named %q", name)
+	}
+
+	ss.enabled = enabled
+	return nil
+}
+
+func (s *Scheduler) Enabled(name string) bool {
+	ss, ok := s.byName[name]
+	return ok && ss.enabled
+}
+
+// Order returns the names of a stage's systems in the order they run
+func (s *Scheduler) Order(stage Stage) []string {
+	names := []string{}
+	for _, ss := range s.stages[stage] {
+		names = append(names, ss.name)
+	}
+	return names
+}
+
+// Run updates every enabled system in the stage, returning once all are done
+func (s *Scheduler) Run(stage Stage, deltaTime float32) {
+	for _, batch := range s.batches[stage] {
+		if len(batch) == 1 {
+			if batch[0].enabled {
+				batch[0].system.Update(deltaTime)
+			}
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, ss := range batch {
+			if !ss.enabled || ss.mainThread {
+				continue
+			}
+
+			wg.Add(1)
+			go func(system System) {
+				defer wg.Done()
+				system.Update(deltaTime)
+			}(ss.system)
+		}
+
+		for _, ss := range batch {
+			if ss.enabled && ss.mainThread {
+				ss.system.Update(deltaTime)
+			}
+		}
+
+		wg.Wait()
+	}
+}
+
+// batchSystems splits a stage's run order into groups that can run together.
+// A batch only ever holds consecutive systems, so a system can join the
+// current batch as long as it doesn't depend on or conflict with any member.
+func batchSystems(order []*scheduledSystem) [][]*scheduledSystem {
+	var batches [][]*scheduledSystem
+	var current []*scheduledSystem
+
+	for _, ss := range order {
+		if len(current) > 0 && canJoinBatch(ss, current) {
+			current = append(current, ss)
+			continue
+		}
+
+		if len(current) > 0 {
+			batches = append(batches, current)
+		}
+		current = []*scheduledSystem{ss}
+	}
+
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+
+	return batches
+}
+
+func canJoinBatch(ss *scheduledSystem, batch []*scheduledSystem) bool {
+	if !ss.parallel() {
+		return false
+	}
+
+	for _, member := range batch {
+		if !member.parallel() || ss.dependencies[member] || ss.conflicts(member) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resolve orders a stage's systems so every dependency is respected, keeping
+// registration order wherever the dependencies allow.
+func (s *Scheduler) resolve(stage Stage) ([]*scheduledSystem, error) {
+	var members []*scheduledSystem
+	for _, ss := range s.systems {
+		if ss.stage == stage {
+			members = append(members, ss)
+		}
+	}
+
+	// dependencies[ss] are the systems that have to run before ss
+	dependencies := make(map[*scheduledSystem]map[*scheduledSystem]bool, len(members))
+	for _, ss := range members {
+		dependencies[ss] = make(map[*scheduledSystem]bool)
+	}
+	for _, ss := range members {
+		for _, name := range ss.after {
+			if other, ok := s.byName[name]; ok && other.stage == stage && other != ss {
+				dependencies[ss][other] = true
+			}
+		}
+		for _, name := range ss.before {
+			if other, ok := s.byName[name]; ok && other.stage == stage && other != ss {
+				dependencies[other][ss] = true
+			}
+		}
+	}
+
+	order := make([]*scheduledSystem, 0, len(members))
+	placed := make(map[*scheduledSystem]bool, len(members))
+
+	for len(order) < len(members) {
+		progressed := false
+
+		for _, ss := range members {
+			if placed[ss] || !dependenciesPlaced(dependencies[ss], placed) {
+				continue
+			}
+
+			order = append(order, ss)
+			placed[ss] = true
+			progressed = true
+			break // Restart so earlier registered systems go first
+		}
+
+		if !progressed {
+			var cycle []string
+			for _, ss := range members {
+				if !placed[ss] {
+					cycle = append(cycle, fmt.Sprintf("%q", ss.name))
+				}
+			}
+			return nil, fmt.Errorf("systems in stage %v have circular dependencies: %s", stage, strings.Join(cycle, ", "))
+		}
+	}
+
+	for _, ss := range members {
+		ss.dependencies = dependencies[ss]
+	}
+
+	return order, nil
+}
+
+func dependenciesPlaced(dependencies map[*scheduledSystem]bool, placed map[*scheduledSystem]bool) bool {
+	for dependency := range dependencies {
+		if !placed[dependency] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,241 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Run with -race, systems in the same batch run on worker goroutines
+
+func batchNames(s *Scheduler, stage Stage) [][]string {
+	var names [][]string
+	for _, batch := range s.batches[stage] {
+		var batchNames []string
+		for _, ss := range batch {
+			batchNames = append(batchNames, ss.name)
+		}
+		names = append(names, batchNames)
+	}
+	return names
+}
+
+func goroutineID() string {
+	stack := make([]byte, 64)
+	stack = stack[:runtime.Stack(stack, false)]
+	return string(bytes.Fields(stack)[1])
+}
+
+func TestSchedulerRunsDisjointSystemsTogether(t *testing.T) {
+	s := NewScheduler()
+
+	// Each waits for the other to start, so they only finish if run concurrently
+	physicsStarted, lightsStarted := make(chan struct{}), make(chan struct{})
+	rendezvous := func(started, other chan struct{}) SystemFunc {
+		return func(deltaTime float32) {
+			close(started)
+			select {
+			case <-other:
+			case <-time.After(5 * time.Second):
+				t.Error("systems in the same batch did not run concurrently")
+			}
+		}
+	}
+
+	s.Add("physics", Update, rendezvous(physicsStarted, lightsStarted),
+		Writes[components.PhysicsComponent](), Reads[components.TransformComponent]())
+	s.Add("lights", Update, rendezvous(lightsStarted, physicsStarted),
+		Writes[components.PointLightComponent](), Reads[components.TransformComponent]())
+
+	if batches := batchNames(s, Update); len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("batches %q, want both systems in one", batches)
+	}
+
+	s.Run(Update, 1)
+}
+
+func TestSchedulerSerializesConflictingWriters(t *testing.T) {
+	s := NewScheduler()
+
+	// Unsynchronised on purpose, the race detector catches the systems overlapping
+	var order []string
+	writer := func(name string) SystemFunc {
+		return func(deltaTime float32) { order = append(order, name) }
+	}
+
+	s.Add("first", Update, writer("first"), Writes[components.TransformComponent]())
+	s.Add("second", Update, writer("second"), Writes[components.TransformComponent]())
+	s.Add("reader", Update, writer("reader"), Reads[components.TransformComponent]())
+	s.Add("exclusive", Update, writer("exclusive")) // Declares nothing, so runs alone
+
+	if batches := batchNames(s, Update); len(batches) != 4 {
+		t.Fatalf("batches %q, want every system alone", batches)
+	}
+
+	for i := 0; i < 100; i++ {
+		order = order[:0]
+		s.Run(Update, 1)
+		if strings.Join(order, ",") != "first,second,reader,exclusive" {
+			t.Fatalf("ran in order %q", order)
+		}
+	}
+}
+
+// Concurrent systems spawn through a CommandBuffer while others read the store
+func TestSchedulerSpawnsFromParallelSystems(t *testing.T) {
+	s := NewScheduler()
+	store := entities.NewEntityStore()
+	commands := entities.NewCommandBuffer(store)
+
+	for i := 0; i < 10; i++ {
+		entity := store.NewEntity()
+		store.AddComponent(entity, &components.TransformComponent{})
+	}
+
+	spawner := SystemFunc(func(deltaTime float32) {
+		for _, entity := range store.GetEntitiesWithComponentType(&components.TransformComponent{}) {
+			if _, ok := entities.Get[components.TransformComponent](store, entity); ok {
+				commands.Spawn(&components.PhysicsComponent{})
+			}
+		}
+	})
+
+	for _, name := range []string{"first", "second"} {
+		if err := s.Add(name, Update, spawner, Reads[components.TransformComponent]()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if batches := batchNames(s, Update); len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("batches %q, want both systems in one", batches)
+	}
+
+	s.Run(Update, 1)
+	commands.Flush()
+
+	if spawned := len(store.GetEntitiesWithComponentType(&components.PhysicsComponent{})); spawned != 20 {
+		t.Errorf("%d entities spawned, want 20", spawned)
+	}
+	if active := len(store.ActiveEntities()); active != 30 {
+		t.Errorf("%d active entities, want 30", active)
+	}
+}
+
+func TestSchedulerBeforeAndAfter(t *testing.T) {
+	s := NewScheduler()
+
+	var order []string
+	record := func(name string) SystemFunc {
+		return func(deltaTime float32) { order = append(order, name) }
+	}
+
+	s.Add("render", Update, record("render"))
+	s.Add("animation", Update, record("animation"), Before("render"))
+	s.Add("ui", Update, record("ui"), After("render"))
+	s.Add("input", Update, record("input"), Before("animation")) // Added late, still runs first
+	s.Add("late", Update, record("late"))
+
+	want := "input,animation,render,ui,late"
+	if got := strings.Join(s.Order(Update), ","); got != want {
+		t.Errorf("order %q, want %q", got, want)
+	}
+
+	s.Run(Update, 1)
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("ran in order %q, want %q", got, want)
+	}
+
+	// Disabled systems keep their place but don't run
+	order = order[:0]
+	if err := s.SetEnabled("render", false); err != nil {
+		t.Fatal(err)
+	}
+	s.Run(Update, 1)
+	if got := strings.Join(order, ","); got != "input,animation,ui,late" {
+		t.Errorf("ran %q with render disabled", got)
+	}
+}
+
+func TestSchedulerMainThreadSystem(t *testing.T) {
+	s := NewScheduler()
+	caller := goroutineID()
+
+	var mainThread, worker string
+	s.Add("gl", Render, SystemFunc(func(deltaTime float32) { mainThread = goroutineID() }),
+		Reads[components.MeshComponent](), MainThread())
+	s.Add("particles", Render, SystemFunc(func(deltaTime float32) { worker = goroutineID() }),
+		Writes[components.PhysicsComponent]())
+
+	if batches := batchNames(s, Render); len(batches) != 1 {
+		t.Fatalf("batches %q, want one", batches)
+	}
+
+	s.Run(Render, 1)
+
+	if mainThread != caller {
+		t.Errorf("main thread system ran on goroutine %s, caller is %s", mainThread, caller)
+	}
+	if worker == caller {
+		t.Error("worker system ran on the caller's goroutine")
+	}
+}
+
+func TestSchedulerReportsCycles(t *testing.T) {
+	s := NewScheduler()
+
+	noop := SystemFunc(func(deltaTime float32) {})
+	if err := s.Add("a", Update, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("b", Update, noop, After("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.Add("c", Update, noop, After("b"), Before("a"))
+	if err == nil || !strings.Contains(err.Error(), "circular") {
+		t.Fatalf("error %v, want a circular dependency", err)
+	}
+
+	// The rejected system isn't left behind
+	if got := strings.Join(s.Order(Update), ","); got != "a,b" {
+		t.Errorf("order %q after the cycle was rejected", got)
+	}
+	if err := s.Add("c", Update, noop); err != nil {
+		t.Errorf("adding c without the cycle: %v", err)
+	}
+}
+
+func TestSchedulerRejectsUnknownDependencies(t *testing.T) {
+	s := NewScheduler()
+
+	noop := SystemFunc(func(deltaTime float32) {})
+	if err := s.Add("physics", FixedUpdate, noop); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opt := range []struct {
+		name   string
+		option SystemOption
+	}{
+		{"After an unknown system", After("missing")},
+		{"Before an unknown system", Before("missing")},
+		{"After a system in another stage", After("physics")},
+		{"Before a system in another stage", Before("physics")},
+	} {
+		if err := s.Add("game", Update, noop, opt.option); err == nil {
+			t.Errorf("%s was accepted", opt.name)
+		}
+	}
+
+	// Nothing was left behind by the rejected systems
+	if order := s.Order(Update); len(order) != 0 {
+		t.Errorf("update order %q", order)
+	}
+	if err := s.Add("game", FixedUpdate, noop, Before("physics")); err != nil {
+		t.Errorf("adding game to physics' stage: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package systems
 
 import (
+	"0xKowalski/game/graphics"
+	"0xKowalski/game/resources"
 	"fmt"
 	"image"
 	"image/draw"
@@ -8,27 +10,27 @@ import (
 	"image/png"
 	"os"
 	"strings"
-
-	"github.com/go-gl/gl/v4.3-core/gl"
 )
 
 type TextureStore struct {
-	textures map[string]uint32
+	device   graphics.Device
+	textures map[string]graphics.Texture
 }
 
-func NewTextureStore() *TextureStore {
+func NewTextureStore(device graphics.Device) *TextureStore {
 	return &TextureStore{
-		textures: make(map[string]uint32),
+		device:   device,
+		textures: make(map[string]graphics.Texture),
 	}
 }
 
 // GetTexture ensures the texture is loaded only once and reused thereafter.
-func (ts *TextureStore) GetTexture(texturePath string) (uint32, error) {
+func (ts *TextureStore) GetTexture(texturePath string) (graphics.Texture, error) {
 	if texture, exists := ts.textures[texturePath]; exists {
 		return texture, nil
 	}
 
-	newTexture, err := prepareTexture(texturePath)
+	newTexture, err := ts.prepareTexture(texturePath)
 	if err != nil {
 		return 0, err
 	}
@@ -37,8 +39,8 @@ func (ts *TextureStore) GetTexture(texturePath string) (uint32, error) {
 	return newTexture, nil
 }
 
-func prepareTexture(texturePath string) (uint32, error) {
-	img, err := loadImage(texturePath)
+func (ts *TextureStore) prepareTexture(texturePath string) (graphics.Texture, error) {
+	img, err := loadImage(resources.Path(texturePath))
 	if err != nil {
 		return 0, err
 	}
@@ -46,20 +48,15 @@ func prepareTexture(texturePath string) (uint32, error) {
 	rgba := imageToRGBA(img)
 	//rgba := flipImageVertically(rgbaFlipped)
 
-	var textureID uint32
-	gl.GenTextures(1, &textureID)
-	gl.BindTexture(gl.TEXTURE_2D, textureID)
-
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-
-	width, height := rgba.Rect.Size().X, rgba.Rect.Size().Y
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(width), int32(height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
-	gl.GenerateMipmap(gl.TEXTURE_2D)
+	return ts.device.CreateTexture(rgba), nil
+}
 
-	return textureID, nil
+// Delete releases every loaded texture
+func (ts *TextureStore) Delete() {
+	for texturePath, texture := range ts.textures {
+		ts.device.DeleteTexture(texture)
+		delete(ts.textures, texturePath)
+	}
 }
 
 func loadImage(filename string) (image.Image, error) {
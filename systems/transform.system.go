@@ -0,0 +1,69 @@
+package systems
+
+import (
+	"0xKowalski/game/components"
+	"0xKowalski/game/entities"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// TransformSystem propagates world matrices down entity hierarchies, setting
+// each child's parent matrix from its parent's world matrix, and works out the
+// interpolated render matrix of every transform.
+type TransformSystem struct {
+	EntityStore *entities.EntityStore
+
+	transforms entities.Query1[components.TransformComponent]
+	unparented entities.Query1[components.TransformComponent]
+	roots      entities.Query1[entities.ChildrenComponent]
+}
+
+func NewTransformSystem(entityStore *entities.EntityStore) *TransformSystem {
+	return &TransformSystem{
+		EntityStore: entityStore,
+		transforms:  entities.NewQuery1[components.TransformComponent](entityStore),
+		unparented:  entities.NewQuery1[components.TransformComponent](entityStore, entities.Without[entities.ParentComponent]()),
+		roots:       entities.NewQuery1[entities.ChildrenComponent](entityStore, entities.Without[entities.ParentComponent]()),
+	}
+}
+
+// StorePrevious snapshots every transform before a fixed step
+func (ts *TransformSystem) StorePrevious() {
+	ts.transforms.Each(func(entity entities.Entity, transformComponent *components.TransformComponent) {
+		transformComponent.StorePrevious()
+	})
+}
+
+// Update resolves world matrices, alpha is how far the frame is between the
+// previous and current fixed step.
+func (ts *TransformSystem) Update(alpha float32) {
+	ts.unparented.Each(func(entity entities.Entity, transformComponent *components.TransformComponent) {
+		transformComponent.SetParentMatrix(mgl32.Ident4())
+		transformComponent.SetRenderMatrix(transformComponent.GetInterpolatedMatrix(alpha))
+	})
+
+	ts.roots.Each(func(root entities.Entity, childrenComponent *entities.ChildrenComponent) {
+		world, render := mgl32.Ident4(), mgl32.Ident4()
+		if transformComponent, ok := entities.Get[components.TransformComponent](ts.EntityStore, root); ok {
+			world, render = transformComponent.GetWorldMatrix(), transformComponent.GetRenderMatrix()
+		}
+
+		ts.propagate(childrenComponent, world, render, alpha)
+	})
+}
+
+func (ts *TransformSystem) propagate(childrenComponent *entities.ChildrenComponent, parentWorld, parentRender mgl32.Mat4, alpha float32) {
+	for _, child := range childrenComponent.Children {
+		world, render := parentWorld, parentRender
+		if transformComponent, ok := entities.Get[components.TransformComponent](ts.EntityStore, child); ok {
+			transformComponent.SetParentMatrix(parentWorld)
+			render = parentRender.Mul4(transformComponent.GetInterpolatedMatrix(alpha))
+			transformComponent.SetRenderMatrix(render)
+			world = transformComponent.GetWorldMatrix()
+		}
+
+		if grandChildren, ok := entities.Get[entities.ChildrenComponent](ts.EntityStore, child); ok {
+			ts.propagate(grandChildren, world, render, alpha)
+		}
+	}
+}
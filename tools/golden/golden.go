@@ -0,0 +1,72 @@
+package main
+
+import (
+	"0xKowalski/game/graphics"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Examples whose first frame is checked
+var examples = []string{"lighting", "rotating-cubes"}
+
+// goldenOptions are how examples are rendered and how close they must match
+type goldenOptions struct {
+	Width, Height int
+	Tolerance     uint8   // Allowed difference of each color channel
+	MaxDiffering  float64 // Fraction of pixels allowed to exceed the tolerance
+}
+
+func defaultGoldenOptions() goldenOptions {
+	return goldenOptions{Width: 320, Height: 240, Tolerance: 2, MaxDiffering: 0.001}
+}
+
+func goldenPath(root, example string) string {
+	return filepath.Join(root, "examples", example, "golden.png")
+}
+
+// render runs an example headless for one frame and saves its screenshot
+func render(root, example, output string, opts goldenOptions) error {
+	cmd := exec.Command("go", "run", "./examples/"+example,
+		"-headless", "-frames", "1",
+		"-width", fmt.Sprint(opts.Width), "-height", fmt.Sprint(opts.Height),
+		"-bindings", "",
+		"-screenshot", output,
+	)
+	cmd.Dir = root
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+func compare(output, golden string, opts goldenOptions) error {
+	got, err := graphics.LoadPNG(output)
+	if err != nil {
+		return err
+	}
+
+	want, err := graphics.LoadPNG(golden)
+	if err != nil {
+		return fmt.Errorf("error loading golden image: %w", err)
+	}
+
+	diff, err := graphics.CompareImages(got, want, opts.Tolerance)
+	if err != nil {
+		return err
+	}
+	if diff.Fraction() > opts.MaxDiffering {
+		return fmt.Errorf("%d of %d pixels differ by more than %d (max %d)", diff.Differing, diff.Pixels, opts.Tolerance, diff.MaxDelta)
+	}
+
+	return nil
+}
+
+func copyFile(source, destination string) error {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(destination, data, 0644)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"0xKowalski/game/resources"
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images instead of comparing")
+
+// Renders each example's first frame with the software renderer and compares it
+// against examples/<name>/golden.png
+func TestGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs every example")
+	}
+
+	root := resources.FindRoot()
+	opts := defaultGoldenOptions()
+
+	for _, example := range examples {
+		t.Run(example, func(t *testing.T) {
+			t.Parallel()
+
+			output := filepath.Join(t.TempDir(), example+".png")
+			golden := goldenPath(root, example)
+
+			if err := render(root, example, output, opts); err != nil {
+				t.Fatalf("error rendering: %v", err)
+			}
+
+			if *update {
+				if err := copyFile(output, golden); err != nil {
+					t.Fatalf("error updating golden image: %v", err)
+				}
+				t.Logf("updated %s", golden)
+				return
+			}
+
+			if err := compare(output, golden, opts); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Golden renders the example scenes with the software renderer and compares
+// them against the golden images committed next to each example, so lighting
+// changes can be checked without a GPU. The same check runs as a test:
+//
+//	go test ./tools/golden          compare against the golden images
+//	go test ./tools/golden -update  rewrite the golden images
+//
+// This command runs it with adjustable sizes and tolerances, keeping the
+// rendered images of failed examples for inspection.
+//
+//	go run ./tools/golden [-update] [-tolerance 2] [-max-differing 0.001]
+package main
+
+import (
+	"0xKowalski/game/resources"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	defaults := defaultGoldenOptions()
+	update := flag.Bool("update", false, "rewrite the golden images instead of comparing")
+	tolerance := flag.Int("tolerance", int(defaults.Tolerance), "allowed difference of each color channel, 0-255")
+	maxDiffering := flag.Float64("max-differing", defaults.MaxDiffering, "fraction of pixels allowed to exceed the tolerance")
+	width := flag.Int("width", defaults.Width, "image width")
+	height := flag.Int("height", defaults.Height, "image height")
+	flag.Parse()
+
+	opts := goldenOptions{Width: *width, Height: *height, Tolerance: uint8(*tolerance), MaxDiffering: *maxDiffering}
+	root := resources.FindRoot()
+
+	outputDir, err := os.MkdirTemp("", "golden")
+	if err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	failed := false
+	for _, example := range examples {
+		output := filepath.Join(outputDir, example+".png")
+		golden := goldenPath(root, example)
+
+		if err := render(root, example, output, opts); err != nil {
+			log.Printf("%s: error rendering: %v", example, err)
+			failed = true
+			continue
+		}
+
+		if *update {
+			if err := copyFile(output, golden); err != nil {
+				log.Printf("%s: error updating golden image: %v", example, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s: updated %s\n", example, golden)
+			continue
+		}
+
+		if err := compare(output, golden, opts); err != nil {
+			log.Printf("%s: %v, rendered image kept at %s", example, err, output)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: ok\n", example)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+	os.RemoveAll(outputDir)
+}
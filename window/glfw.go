@@ -1,19 +1,31 @@
 package window
 
 import (
-	"github.com/go-gl/glfw/v3.3/glfw"
+	"fmt"
 	"log"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
 )
 
 type WindowConfig struct {
-	Title  string
-	Width  int
-	Height int
+	Title   string
+	Width   int
+	Height  int
+	Mode    WindowMode
+	Monitor int // Index of the monitor used in fullscreen and borderless modes
+	VSync   bool
+	Samples int // MSAA samples per pixel, 0 disables multisampling
 }
 
 type Window struct {
 	GlfwWindow   *glfw.Window
 	WindowConfig WindowConfig
+
+	resizeHandlers []func(width, height int)
+
+	// Windowed position and size, restored when leaving fullscreen/borderless
+	windowedX, windowedY          int
+	windowedWidth, windowedHeight int
 }
 
 func InitWindow(windowConfig WindowConfig) (*Window, error) {
@@ -27,6 +39,7 @@ func InitWindow(windowConfig WindowConfig) (*Window, error) {
 	glfw.WindowHint(glfw.ContextVersionMinor, 3)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	glfw.WindowHint(glfw.Samples, windowConfig.Samples)
 
 	// Create a GLFW window.
 	glfwWindow, err := glfw.CreateWindow(windowConfig.Width, windowConfig.Height, windowConfig.Title, nil, nil)
@@ -43,6 +56,18 @@ func InitWindow(windowConfig WindowConfig) (*Window, error) {
 		WindowConfig: windowConfig,
 	}
 
+	glfwWindow.SetFramebufferSizeCallback(window.onFramebufferResize)
+
+	window.SetVSync(windowConfig.VSync)
+
+	// The window starts out windowed
+	window.WindowConfig.Mode = Windowed
+	if windowConfig.Mode != Windowed {
+		if err := window.SetMode(windowConfig.Mode, windowConfig.Monitor); err != nil {
+			log.Printf("Failed to set window mode: %v", err)
+		}
+	}
+
 	return window, nil
 }
 
@@ -55,3 +80,108 @@ func (w *Window) GetWidthAndHeight() (int, int) {
 
 	return width, height
 }
+
+// OnResize registers a handler called with the new framebuffer size whenever
+// the window is resized or changes mode.
+func (w *Window) OnResize(handler func(width, height int)) {
+	w.resizeHandlers = append(w.resizeHandlers, handler)
+}
+
+func (w *Window) onFramebufferResize(glfwWindow *glfw.Window, width, height int) {
+	for _, handler := range w.resizeHandlers {
+		handler(width, height)
+	}
+}
+
+// SetMode switches between windowed, fullscreen and borderless, monitor picks
+// the monitor for the latter two. The windowed size and position are restored
+// when going back to windowed.
+func (w *Window) SetMode(mode WindowMode, monitor int) error {
+	if mode == w.WindowConfig.Mode && (mode == Windowed || monitor == w.WindowConfig.Monitor) {
+		return nil
+	}
+
+	if w.WindowConfig.Mode == Windowed {
+		w.windowedX, w.windowedY = w.GlfwWindow.GetPos()
+		w.windowedWidth, w.windowedHeight = w.GlfwWindow.GetSize()
+	}
+
+	switch mode {
+	case Windowed:
+		w.GlfwWindow.SetAttrib(glfw.Decorated, glfw.True)
+		w.GlfwWindow.SetMonitor(nil, w.windowedX, w.windowedY, w.windowedWidth, w.windowedHeight, 0)
+
+	case Fullscreen, Borderless:
+		glfwMonitor, err := getMonitor(monitor)
+		if err != nil {
+			return err
+		}
+		videoMode := glfwMonitor.GetVideoMode()
+
+		if mode == Fullscreen {
+			w.GlfwWindow.SetMonitor(glfwMonitor, 0, 0, videoMode.Width, videoMode.Height, videoMode.RefreshRate)
+		} else {
+			monitorX, monitorY := glfwMonitor.GetPos()
+			w.GlfwWindow.SetAttrib(glfw.Decorated, glfw.False)
+			w.GlfwWindow.SetMonitor(nil, monitorX, monitorY, videoMode.Width, videoMode.Height, 0)
+		}
+
+	default:
+		return fmt.Errorf("unknown window mode %v", mode)
+	}
+
+	w.WindowConfig.Mode = mode
+	w.WindowConfig.Monitor = monitor
+
+	// Switching modes resets the swap interval on some platforms
+	w.SetVSync(w.WindowConfig.VSync)
+
+	return nil
+}
+
+// ToggleFullscreen switches between windowed and fullscreen on the configured monitor
+func (w *Window) ToggleFullscreen() error {
+	if w.WindowConfig.Mode == Windowed {
+		return w.SetMode(Fullscreen, w.WindowConfig.Monitor)
+	}
+	return w.SetMode(Windowed, w.WindowConfig.Monitor)
+}
+
+func (w *Window) SetVSync(enabled bool) {
+	w.WindowConfig.VSync = enabled
+
+	if enabled {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+}
+
+func (w *Window) PollEvents() {
+	glfw.PollEvents()
+}
+
+func (w *Window) SwapBuffers() {
+	w.GlfwWindow.SwapBuffers()
+}
+
+func (w *Window) ShouldClose() bool {
+	return w.GlfwWindow.ShouldClose()
+}
+
+func (w *Window) SetShouldClose(shouldClose bool) {
+	w.GlfwWindow.SetShouldClose(shouldClose)
+}
+
+// MonitorCount returns the number of connected monitors, for picking one in SetMode
+func MonitorCount() int {
+	return len(glfw.GetMonitors())
+}
+
+func getMonitor(index int) (*glfw.Monitor, error) {
+	monitors := glfw.GetMonitors()
+	if index < 0 || index >= len(monitors) {
+		return nil, fmt.Errorf("monitor %d does not exist, %d connected", index, len(monitors))
+	}
+	return monitors[index], nil
+}
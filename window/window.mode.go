@@ -0,0 +1,36 @@
+package window
+
+import "fmt"
+
+type WindowMode int
+
+const (
+	Windowed   WindowMode = iota
+	Fullscreen            // Exclusive fullscreen at the monitor's current video mode
+	Borderless            // Undecorated window covering the monitor
+)
+
+var windowModeNames = []string{"windowed", "fullscreen", "borderless"}
+
+func (mode WindowMode) String() string {
+	if mode < 0 || int(mode) >= len(windowModeNames) {
+		return fmt.Sprintf("WindowMode(%d)", int(mode))
+	}
+	return windowModeNames[mode]
+}
+
+// Window modes are written by name in config files and flags
+
+func (mode WindowMode) MarshalText() ([]byte, error) {
+	return []byte(mode.String()), nil
+}
+
+func (mode *WindowMode) UnmarshalText(text []byte) error {
+	for i, name := range windowModeNames {
+		if string(text) == name {
+			*mode = WindowMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown window mode %q, expected windowed, fullscreen or borderless", text)
+}
@@ -0,0 +1,44 @@
+package window
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWindowModeNames(t *testing.T) {
+	for _, mode := range []WindowMode{Windowed, Fullscreen, Borderless} {
+		text, err := mode.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parsed WindowMode
+		if err := parsed.UnmarshalText(text); err != nil || parsed != mode {
+			t.Errorf("%s parsed as %v, %v", text, parsed, err)
+		}
+	}
+
+	var mode WindowMode
+	if err := mode.UnmarshalText([]byte("maximised")); err == nil {
+		t.Error("unknown mode parsed without an error")
+	}
+}
+
+func TestWindowConfigJSON(t *testing.T) {
+	var config WindowConfig
+	if err := json.Unmarshal([]byte(`{"Mode": "borderless", "Monitor": 1, "VSync": false}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Mode != Borderless || config.Monitor != 1 || config.VSync {
+		t.Errorf("parsed %+v", config)
+	}
+
+	data, err := json.Marshal(WindowConfig{Mode: Fullscreen})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip map[string]any
+	if err := json.Unmarshal(data, &roundTrip); err != nil || roundTrip["Mode"] != "fullscreen" {
+		t.Errorf("marshalled as %s", data)
+	}
+}